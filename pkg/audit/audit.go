@@ -0,0 +1,105 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/repository"
+	"gorm.io/datatypes"
+)
+
+// auditedEntities are the models holding patient data whose reads and
+// changes are recorded in the audit log
+var auditedEntities = map[string]bool{
+	"Patient":                  true,
+	"PatientChart":             true,
+	"DiagnosticProcedureOrder": true,
+	"LabOrder":                 true,
+	"TreatmentOrder":           true,
+	"SurgicalOrder":            true,
+	"ReferralOrder":            true,
+	"FollowUpOrder":            true,
+	"MedicalPrescriptionOrder": true,
+	"EyewearPrescriptionOrder": true,
+}
+
+// IsAudited returns true if changes to the model named entityType are audited
+func IsAudited(entityType string) bool {
+	return auditedEntities[entityType]
+}
+
+// Actor identifies who performed an audited operation
+type Actor struct {
+	Email         string
+	OperationName string
+	ClientIP      string
+}
+
+// SystemActor is the actor of changes the server makes on its own, such as
+// those of scheduled jobs
+var SystemActor = Actor{Email: "system", OperationName: "scheduled job"}
+
+type actorContextKey struct{}
+
+// WithActor returns a copy of ctx carrying actor. The gorm callbacks read the
+// actor from the context of the statement, so repositories have a WithContext
+// method that passes the context of a request or job to their queries; writes
+// made without it are recorded with no actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx by WithActor
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	if ctx == nil {
+		return Actor{}, false
+	}
+
+	actor, ok := ctx.Value(actorContextKey{}).(Actor)
+	return actor, ok
+}
+
+// Record writes an audit log for an action that the gorm callbacks do not
+// capture, attributed to the actor in ctx
+func Record(ctx context.Context, repository repository.AuditLogRepository, action models.AuditAction, entityType string, entityID int, changes map[string]Change) error {
+	actor, _ := ActorFromContext(ctx)
+
+	auditLog := models.AuditLog{
+		UserEmail:     actor.Email,
+		OperationName: actor.OperationName,
+		ClientIP:      actor.ClientIP,
+		Action:        action,
+		EntityType:    entityType,
+		EntityID:      entityID,
+	}
+
+	if changes != nil {
+		value, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+
+		auditLog.Changes = datatypes.JSON(value)
+	}
+
+	return repository.Save(&auditLog)
+}
@@ -0,0 +1,309 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// beforeUpdateKey is the statement instance key holding the rows loaded
+// before an update
+const beforeUpdateKey = "audit:before_update"
+
+// ignoredColumns are not included in update diffs
+var ignoredColumns = map[string]bool{
+	"updated_at": true,
+	"document":   true,
+	"count":      true,
+}
+
+// RegisterCallbacks adds gorm callbacks that write an audit log for every
+// create, update and delete of an audited model. Updates are recorded with a
+// diff of the changed columns. Versioned models also get a ChartRevision.
+func RegisterCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Create().After("gorm:create").Register("audit:create", afterCreate); err != nil {
+		return err
+	}
+
+	if err := db.Callback().Update().Before("gorm:update").Register("audit:before_update", beforeUpdate); err != nil {
+		return err
+	}
+
+	if err := db.Callback().Update().After("gorm:update").Register("audit:after_update", afterUpdate); err != nil {
+		return err
+	}
+
+	if err := db.Callback().Delete().After("gorm:delete").Register("audit:delete", afterDelete); err != nil {
+		return err
+	}
+
+	return registerRevisionCallbacks(db)
+}
+
+func afterCreate(db *gorm.DB) {
+	if !isAuditable(db) {
+		return
+	}
+
+	for _, id := range entityIDs(db) {
+		record(db, models.CreateAuditAction, id, nil)
+	}
+}
+
+func beforeUpdate(db *gorm.DB) {
+	if !isAuditable(db) {
+		return
+	}
+
+	ids := entityIDs(db)
+	if len(ids) == 0 {
+		return
+	}
+
+	db.InstanceSet(beforeUpdateKey, snapshot(db, ids))
+}
+
+func afterUpdate(db *gorm.DB) {
+	if !isAuditable(db) {
+		return
+	}
+
+	value, ok := db.InstanceGet(beforeUpdateKey)
+	if !ok {
+		return
+	}
+
+	before := value.(map[int]map[string]interface{})
+
+	ids := make([]int, 0, len(before))
+	for id := range before {
+		ids = append(ids, id)
+	}
+
+	after := snapshot(db, ids)
+
+	for _, id := range ids {
+		changes := diff(before[id], after[id])
+		if len(changes) == 0 {
+			continue
+		}
+
+		record(db, models.UpdateAuditAction, id, changes)
+	}
+}
+
+func afterDelete(db *gorm.DB) {
+	if !isAuditable(db) {
+		return
+	}
+
+	for _, id := range entityIDs(db) {
+		record(db, models.DeleteAuditAction, id, nil)
+	}
+}
+
+// isAuditable returns true if the statement succeeded so far and operates on
+// an audited model
+func isAuditable(db *gorm.DB) bool {
+	return db.Error == nil && db.Statement.Schema != nil && IsAudited(db.Statement.Schema.Name)
+}
+
+// entityIDs returns the primary keys the statement operates on, taken from the
+// model values or, failing that, from an `id = ?` or `id IN ?` condition
+func entityIDs(db *gorm.DB) []int {
+	stmt := db.Statement
+	field := stmt.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return nil
+	}
+
+	var ids []int
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Struct:
+		if value, zero := field.ValueOf(stmt.ReflectValue); !zero {
+			ids = appendID(ids, value)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			if value, zero := field.ValueOf(reflect.Indirect(stmt.ReflectValue.Index(i))); !zero {
+				ids = appendID(ids, value)
+			}
+		}
+	}
+
+	if len(ids) > 0 {
+		return ids
+	}
+
+	c, ok := stmt.Clauses["WHERE"]
+	if !ok {
+		return nil
+	}
+
+	where, ok := c.Expression.(clause.Where)
+	if !ok {
+		return nil
+	}
+
+	for _, expression := range where.Exprs {
+		switch e := expression.(type) {
+		case clause.Eq:
+			if columnName(e.Column) == field.DBName {
+				ids = appendID(ids, e.Value)
+			}
+		case clause.IN:
+			if columnName(e.Column) == field.DBName {
+				for _, value := range e.Values {
+					ids = appendID(ids, value)
+				}
+			}
+		case clause.Expr:
+			sql := strings.Join(strings.Fields(e.SQL), " ")
+			if len(e.Vars) == 1 && (sql == field.DBName+" = ?" || sql == field.DBName+" IN ?") {
+				ids = appendID(ids, e.Vars[0])
+			}
+		}
+	}
+
+	return ids
+}
+
+func columnName(column interface{}) string {
+	switch c := column.(type) {
+	case string:
+		return c
+	case clause.Column:
+		return c.Name
+	}
+
+	return ""
+}
+
+// appendID appends value, or every element of value if it is a slice, to ids
+func appendID(ids []int, value interface{}) []int {
+	v := reflect.Indirect(reflect.ValueOf(value))
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		ids = append(ids, int(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		ids = append(ids, int(v.Uint()))
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			ids = appendID(ids, v.Index(i).Interface())
+		}
+	}
+
+	return ids
+}
+
+// snapshot loads the current column values of the rows with the given ids
+func snapshot(db *gorm.DB, ids []int) map[int]map[string]interface{} {
+	pk := db.Statement.Schema.PrioritizedPrimaryField.DBName
+
+	var rows []map[string]interface{}
+	db.Session(&gorm.Session{NewDB: true}).Table(db.Statement.Table).Where(pk+" IN ?", ids).Find(&rows)
+
+	result := make(map[int]map[string]interface{}, len(rows))
+	for _, row := range rows {
+		if id := appendID(nil, row[pk]); len(id) == 1 {
+			result[id[0]] = row
+		}
+	}
+
+	return result
+}
+
+// Change is the value of a column before and after an update
+type Change struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+func diff(before map[string]interface{}, after map[string]interface{}) map[string]Change {
+	changes := make(map[string]Change)
+
+	for column, newValue := range after {
+		if ignoredColumns[column] {
+			continue
+		}
+
+		oldValue := before[column]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+
+		if o, ok := oldValue.(time.Time); ok {
+			if n, ok := newValue.(time.Time); ok && o.Equal(n) {
+				continue
+			}
+		}
+
+		changes[column] = Change{Old: readable(oldValue), New: readable(newValue)}
+	}
+
+	return changes
+}
+
+// readable keeps raw column bytes from being base64 encoded in the diff
+func readable(value interface{}) interface{} {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+
+	return value
+}
+
+// record writes an audit log in the statement's transaction, so that the
+// change is rolled back if it cannot be audited
+func record(db *gorm.DB, action models.AuditAction, entityID int, changes map[string]Change) {
+	actor, _ := ActorFromContext(db.Statement.Context)
+
+	auditLog := models.AuditLog{
+		UserEmail:     actor.Email,
+		OperationName: actor.OperationName,
+		ClientIP:      actor.ClientIP,
+		Action:        action,
+		EntityType:    db.Statement.Schema.Name,
+		EntityID:      entityID,
+	}
+
+	if changes != nil {
+		value, err := json.Marshal(changes)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+
+		auditLog.Changes = datatypes.JSON(value)
+	}
+
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&auditLog).Error; err != nil {
+		db.AddError(err)
+	}
+}
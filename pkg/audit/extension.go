@@ -0,0 +1,145 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/tensoremr/server/pkg/middleware"
+	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/repository"
+)
+
+// maxReadDepth bounds how deep query results are searched for audited entities
+const maxReadDepth = 5
+
+var modelsPkgPath = reflect.TypeOf(models.AuditLog{}).PkgPath()
+
+// Extension is a gqlgen handler extension that attaches the requesting user to
+// the context of root Query and Mutation fields, and records every audited
+// entity returned by a query as a read
+type Extension struct {
+	AuditLogRepository repository.AuditLogRepository
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.FieldInterceptor
+} = Extension{}
+
+// ExtensionName ...
+func (e Extension) ExtensionName() string {
+	return "AuditLog"
+}
+
+// Validate ...
+func (e Extension) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptField ...
+func (e Extension) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || (fc.Object != "Query" && fc.Object != "Mutation") {
+		return next(ctx)
+	}
+
+	actor := Actor{OperationName: fc.Field.Name}
+	if oc := graphql.GetOperationContext(ctx); oc != nil && len(oc.OperationName) > 0 {
+		actor.OperationName = oc.OperationName
+	}
+
+	if gc, err := middleware.GinContextFromContext(ctx); err == nil {
+		actor.Email = gc.GetString("email")
+		actor.ClientIP = gc.ClientIP()
+	}
+
+	ctx = WithActor(ctx, actor)
+
+	res, err := next(ctx)
+	if err != nil || fc.Object != "Query" {
+		return res, err
+	}
+
+	var logs []models.AuditLog
+	seen := make(map[string]bool)
+
+	collectReads(reflect.ValueOf(res), 0, func(entityType string, entityID int) {
+		key := fmt.Sprintf("%s.%d", entityType, entityID)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		logs = append(logs, models.AuditLog{
+			UserEmail:     actor.Email,
+			OperationName: actor.OperationName,
+			ClientIP:      actor.ClientIP,
+			Action:        models.ReadAuditAction,
+			EntityType:    entityType,
+			EntityID:      entityID,
+		})
+	})
+
+	if len(logs) > 0 {
+		if err := e.AuditLogRepository.BatchSave(logs); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// collectReads walks v and calls found for every audited model in it
+func collectReads(v reflect.Value, depth int, found func(entityType string, entityID int)) {
+	if depth > maxReadDepth {
+		return
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			collectReads(v.Index(i), depth+1, found)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		if t.PkgPath() == modelsPkgPath && IsAudited(t.Name()) {
+			if id := v.FieldByName("ID"); id.IsValid() && id.Kind() == reflect.Int && id.Int() != 0 {
+				found(t.Name(), int(id.Int()))
+			}
+			return
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				collectReads(v.Field(i), depth+1, found)
+			}
+		}
+	}
+}
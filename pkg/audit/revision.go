@@ -0,0 +1,312 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package audit
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// revisionIDsKey is the statement instance key holding the ids of the rows an
+// update or delete is about to change
+const revisionIDsKey = "audit:revision_ids"
+
+// revisionRowsKey is the statement instance key holding the rows a delete is
+// about to remove, since hard deleted rows can't be read afterwards
+const revisionRowsKey = "audit:revision_rows"
+
+// versionedEntities are the patient chart models whose every saved state is
+// kept as a ChartRevision
+var versionedEntities = map[string]bool{
+	"PatientChart":        true,
+	"VitalSigns":          true,
+	"OpthalmologyExam":    true,
+	"PhysicalExamFinding": true,
+	"VisualAcuity":        true,
+	"AutoRefraction":      true,
+	"Iop":                 true,
+	"Pupils":              true,
+	"ExternalExam":        true,
+	"OcularMotility":      true,
+	"CoverTest":           true,
+	"SlitLampExam":        true,
+	"Funduscopy":          true,
+	"OpticDisc":           true,
+}
+
+// IsVersioned returns true if revisions of the model named entityType are kept
+func IsVersioned(entityType string) bool {
+	return versionedEntities[entityType]
+}
+
+// registerRevisionCallbacks adds the gorm callbacks that write a ChartRevision
+// after every create, update and delete of a versioned model
+func registerRevisionCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Create().After("gorm:create").Register("audit:revision_create", afterCreateRevision); err != nil {
+		return err
+	}
+
+	if err := db.Callback().Update().Before("gorm:update").Register("audit:revision_before_update", beforeRevision); err != nil {
+		return err
+	}
+
+	if err := db.Callback().Update().After("gorm:update").Register("audit:revision_update", func(db *gorm.DB) { afterRevision(db, false) }); err != nil {
+		return err
+	}
+
+	if err := db.Callback().Delete().Before("gorm:delete").Register("audit:revision_before_delete", beforeDeleteRevision); err != nil {
+		return err
+	}
+
+	return db.Callback().Delete().After("gorm:delete").Register("audit:revision_delete", func(db *gorm.DB) { afterRevision(db, true) })
+}
+
+func isVersionable(db *gorm.DB) bool {
+	return db.Error == nil && db.Statement.Schema != nil && IsVersioned(db.Statement.Schema.Name)
+}
+
+func afterCreateRevision(db *gorm.DB) {
+	if !isVersionable(db) {
+		return
+	}
+
+	ids := entityIDs(db)
+	writeRevisions(db, ids, snapshot(db, ids), false)
+}
+
+func beforeRevision(db *gorm.DB) {
+	if !isVersionable(db) {
+		return
+	}
+
+	ids := affectedIDs(db)
+	db.InstanceSet(revisionIDsKey, ids)
+
+	if len(ids) > 0 {
+		writeBaselines(db, ids, nil)
+	}
+}
+
+func beforeDeleteRevision(db *gorm.DB) {
+	if !isVersionable(db) {
+		return
+	}
+
+	ids := affectedIDs(db)
+	db.InstanceSet(revisionIDsKey, ids)
+
+	if len(ids) > 0 {
+		rows := snapshot(db, ids)
+		db.InstanceSet(revisionRowsKey, rows)
+		writeBaselines(db, ids, rows)
+	}
+}
+
+func afterRevision(db *gorm.DB, deleted bool) {
+	if !isVersionable(db) {
+		return
+	}
+
+	value, ok := db.InstanceGet(revisionIDsKey)
+	if !ok {
+		return
+	}
+
+	ids := value.([]int)
+	if len(ids) == 0 {
+		return
+	}
+
+	rows, ok := db.InstanceGet(revisionRowsKey)
+	if !ok {
+		rows = snapshot(db, ids)
+	}
+
+	writeRevisions(db, ids, rows.(map[int]map[string]interface{}), deleted)
+}
+
+// affectedIDs returns the primary keys of the rows an update or delete will
+// change. Unlike entityIDs, statements that only filter on other columns are
+// resolved by looking the rows up
+func affectedIDs(db *gorm.DB) []int {
+	if ids := entityIDs(db); len(ids) > 0 {
+		return ids
+	}
+
+	stmt := db.Statement
+	c, ok := stmt.Clauses["WHERE"]
+	if !ok || stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil
+	}
+
+	where, ok := c.Expression.(clause.Where)
+	if !ok || len(where.Exprs) == 0 {
+		return nil
+	}
+
+	query := db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table).Clauses(where)
+	if stmt.Schema.LookUpField("DeletedAt") != nil {
+		query = query.Where("deleted_at IS NULL")
+	}
+
+	var ids []int
+	query.Pluck(stmt.Schema.PrioritizedPrimaryField.DBName, &ids)
+
+	return ids
+}
+
+// writeBaselines stores the current state of the records that have no
+// revision yet, such as records saved before versioning was added, so that
+// their first change doesn't lose the original values. Baselines have no
+// author and are dated when the record was last saved
+func writeBaselines(db *gorm.DB, ids []int, rows map[int]map[string]interface{}) {
+	tx := db.Session(&gorm.Session{NewDB: true})
+	entityType := db.Statement.Schema.Name
+
+	var versioned []int
+	if err := tx.Model(&models.ChartRevision{}).Where("entity_type = ? AND entity_id IN ?", entityType, ids).Distinct().Pluck("entity_id", &versioned).Error; err != nil {
+		db.AddError(err)
+		return
+	}
+
+	isVersioned := make(map[int]bool, len(versioned))
+	for _, id := range versioned {
+		isVersioned[id] = true
+	}
+
+	var missing []int
+	for _, id := range ids {
+		if !isVersioned[id] {
+			missing = append(missing, id)
+		}
+	}
+
+	if len(missing) == 0 {
+		return
+	}
+
+	if rows == nil {
+		rows = snapshot(db, missing)
+	}
+
+	for _, id := range missing {
+		row, ok := rows[id]
+		if !ok {
+			continue
+		}
+
+		savedAt, _ := row["updated_at"].(time.Time)
+		if err := saveRevision(tx, entityType, id, row, false, "", nil, savedAt); err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+}
+
+// writeRevisions stores rows, the state of the records with the given ids, in
+// the statement's transaction
+func writeRevisions(db *gorm.DB, ids []int, rows map[int]map[string]interface{}, deleted bool) {
+	if len(ids) == 0 {
+		return
+	}
+
+	sort.Ints(ids)
+
+	tx := db.Session(&gorm.Session{NewDB: true})
+	entityType := db.Statement.Schema.Name
+
+	actor, _ := ActorFromContext(db.Statement.Context)
+
+	var userID *int
+	if len(actor.Email) > 0 {
+		var user models.User
+		if err := tx.Select("id").Where("email = ?", actor.Email).Take(&user).Error; err == nil {
+			userID = &user.ID
+		}
+	}
+
+	for _, id := range ids {
+		row, ok := rows[id]
+		if !ok {
+			continue
+		}
+
+		if err := saveRevision(tx, entityType, id, row, deleted, actor.Email, userID, time.Time{}); err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+}
+
+// saveRevision stores row as the next revision of a record. Revisions of a
+// record are numbered under a transaction-level advisory lock, so concurrent
+// saves of the same record get consecutive numbers. A zero createdAt is now
+func saveRevision(tx *gorm.DB, entityType string, id int, row map[string]interface{}, deleted bool, userEmail string, userID *int, createdAt time.Time) error {
+	patientChartID := id
+	if entityType != "PatientChart" {
+		chartIDs := appendID(nil, row["patient_chart_id"])
+		if len(chartIDs) != 1 {
+			return nil
+		}
+
+		patientChartID = chartIDs[0]
+	}
+
+	data := make(map[string]interface{}, len(row))
+	for column, value := range row {
+		if column == "document" || column == "count" {
+			continue
+		}
+
+		data[column] = readable(value)
+	}
+
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?), ?::int)", entityType, id).Error; err != nil {
+		return err
+	}
+
+	var revision int
+	if err := tx.Model(&models.ChartRevision{}).Select("COALESCE(MAX(revision), 0)").Where("entity_type = ? AND entity_id = ?", entityType, id).Scan(&revision).Error; err != nil {
+		return err
+	}
+
+	chartRevision := models.ChartRevision{
+		PatientChartID: patientChartID,
+		EntityType:     entityType,
+		EntityID:       id,
+		Revision:       revision + 1,
+		Data:           datatypes.JSON(value),
+		Deleted:        deleted,
+		UserEmail:      userEmail,
+		UserID:         userID,
+		CreatedAt:      createdAt,
+	}
+
+	return tx.Create(&chartRevision).Error
+}
@@ -21,17 +21,29 @@ package auth
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tensoremr/server/pkg/jwt"
+	"github.com/tensoremr/server/pkg/mailer"
 	"github.com/tensoremr/server/pkg/models"
 	"github.com/tensoremr/server/pkg/repository"
 )
 
 type AuthApi struct {
-	UserRepository repository.UserRepository
+	UserRepository         repository.UserRepository
+	RefreshTokenRepository repository.RefreshTokenRepository
+	Mailer                 mailer.Mailer
+	OIDC                   *OIDCProvider
 }
 
+// accessTokenMinutes is how long an access token is valid. Clients use their
+// refresh token to get a new one.
+const accessTokenMinutes = 15
+
+// refreshTokenDays is how long a refresh token is valid if it is not used
+const refreshTokenDays = 30
+
 // LoginPayload login body
 type LoginPayload struct {
 	Email    string `json:"email"`
@@ -45,9 +57,75 @@ type LegacyLoginPayload struct {
 	Password string `json:"password"`
 }
 
+// SignupPayload signup body. User types and the active flag are left out, since
+// only an admin may assign them
+type SignupPayload struct {
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+}
+
+// RefreshPayload refresh and logout body
+type RefreshPayload struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
 // LoginResponse token response
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+// NewSession starts a login session for the user and returns its first
+// access and refresh tokens
+func NewSession(refreshTokenRepository *repository.RefreshTokenRepository, user models.User) (*LoginResponse, error) {
+	sessionID, err := jwt.GenerateSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	return issueTokens(user, sessionID, func(refreshToken *models.RefreshToken) error {
+		return refreshTokenRepository.Save(refreshToken)
+	})
+}
+
+// issueTokens signs an access token and creates a refresh token for the
+// session, which save stores
+func issueTokens(user models.User, sessionID string, save func(refreshToken *models.RefreshToken) error) (*LoginResponse, error) {
+	jwtWrapper := jwt.Wrapper{
+		SecretKey:         os.Getenv("JWT_SECRET"),
+		Issuer:            os.Getenv("JWT_ISSUER"),
+		ExpirationMinutes: accessTokenMinutes,
+	}
+
+	signedToken, err := jwtWrapper.GenerateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, tokenHash, err := jwt.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := models.RefreshToken{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().AddDate(0, 0, refreshTokenDays),
+	}
+
+	if err := save(&refreshToken); err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Token:        signedToken,
+		RefreshToken: token,
+		ExpiresIn:    accessTokenMinutes * 60,
+	}, nil
 }
 
 // Login logs users in
@@ -75,10 +153,23 @@ func (s *AuthApi) Login() gin.HandlerFunc {
 			return
 		}
 
+		// Check if user is locked
+		if user.IsLocked() {
+			c.JSON(401, gin.H{
+				"message": "Your account is locked",
+			})
+			c.Abort()
+			return
+		}
+
 		// Check password validity
 		pErr := user.CheckPassword(user.Password, payload.Password)
 		if pErr != nil {
-			log.Println(err)
+			user.RecordFailedAttempt()
+			if err := s.UserRepository.UpdateLoginAttempts(&user); err != nil {
+				log.Println(err)
+			}
+
 			c.JSON(401, gin.H{
 				"message": "Invalid user credentials",
 			})
@@ -95,17 +186,15 @@ func (s *AuthApi) Login() gin.HandlerFunc {
 			return
 		}
 
-		jwtSecret := os.Getenv("JWT_SECRET")
-		jwtIssuer := os.Getenv("JWT_ISSUER")
-
-		// Generate JWT Token
-		jwtWrapper := jwt.Wrapper{
-			SecretKey:       jwtSecret,
-			Issuer:          jwtIssuer,
-			ExpirationHours: 24,
-		}
+		s.completeLogin(c, user)
+	}
+}
 
-		signedToken, err := jwtWrapper.GenerateToken(user)
+// completeLogin finishes signing in a user whose password has been checked.
+// Users with two-factor authentication get a challenge instead of tokens
+func (s *AuthApi) completeLogin(c *gin.Context, user models.User) {
+	if user.TOTPEnabled {
+		challenge, err := newTwoFactorToken(user)
 		if err != nil {
 			log.Println(err)
 			c.JSON(500, gin.H{
@@ -115,14 +204,29 @@ func (s *AuthApi) Login() gin.HandlerFunc {
 			return
 		}
 
-		tokenResponse := LoginResponse{
-			Token: signedToken,
-		}
+		c.JSON(200, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			TwoFactorToken:    challenge,
+		})
+		return
+	}
 
-		c.JSON(200, tokenResponse)
+	user.ResetAttempts()
+	if err := s.UserRepository.UpdateLoginAttempts(&user); err != nil {
+		log.Println(err)
+	}
 
+	tokenResponse, err := NewSession(&s.RefreshTokenRepository, user)
+	if err != nil {
+		log.Println(err)
+		c.JSON(500, gin.H{
+			"msg": "error signing token",
+		})
+		c.Abort()
 		return
 	}
+
+	c.JSON(200, tokenResponse)
 }
 
 // Legacy Login logs users in
@@ -160,20 +264,25 @@ func (s *AuthApi) LegacyLogin() gin.HandlerFunc {
 			return
 		}
 
-		// Check password validity
-		pErr := user.CheckPassword(user.Password, payload.Password)
-		if pErr != nil {
+		// Check if user is locked
+		if user.IsLocked() {
 			c.JSON(401, gin.H{
-				"message": "Invalid user credentials",
+				"message": "Your account is locked",
 			})
 			c.Abort()
 			return
 		}
 
-		user.Email = payload.Email
-		if err := s.UserRepository.Update(&user, nil); err != nil {
-			c.JSON(500, gin.H{
-				"message": "Sever error",
+		// Check password validity
+		pErr := user.CheckPassword(user.Password, payload.Password)
+		if pErr != nil {
+			user.RecordFailedAttempt()
+			if err := s.UserRepository.UpdateLoginAttempts(&user); err != nil {
+				log.Println(err)
+			}
+
+			c.JSON(401, gin.H{
+				"message": "Invalid user credentials",
 			})
 			c.Abort()
 			return
@@ -188,41 +297,24 @@ func (s *AuthApi) LegacyLogin() gin.HandlerFunc {
 			return
 		}
 
-		jwtSecret := os.Getenv("JWT_SECRET")
-		jwtIssuer := os.Getenv("JWT_ISSUER")
-
-		// Generate JWT Token
-		jwtWrapper := jwt.Wrapper{
-			SecretKey:       jwtSecret,
-			Issuer:          jwtIssuer,
-			ExpirationHours: 24,
-		}
-
-		signedToken, err := jwtWrapper.GenerateToken(user)
-		if err != nil {
-			log.Println(err)
+		user.Email = payload.Email
+		if err := s.UserRepository.Update(&user, nil); err != nil {
 			c.JSON(500, gin.H{
-				"msg": "error signing token",
+				"message": "Sever error",
 			})
 			c.Abort()
 			return
 		}
 
-		tokenResponse := LoginResponse{
-			Token: signedToken,
-		}
-
-		c.JSON(200, tokenResponse)
-
-		return
+		s.completeLogin(c, user)
 	}
 }
 
 // Signup creates a user in db
 func (s *AuthApi) Signup(c *gin.Context) {
-	var user models.User
+	var payload SignupPayload
 
-	err := c.ShouldBindJSON(&user)
+	err := c.ShouldBindJSON(&payload)
 	if err != nil {
 		log.Println(err)
 
@@ -234,6 +326,13 @@ func (s *AuthApi) Signup(c *gin.Context) {
 		return
 	}
 
+	user := models.User{
+		FirstName: payload.FirstName,
+		LastName:  payload.LastName,
+		Email:     payload.Email,
+		Password:  payload.Password,
+	}
+
 	err = user.HashPassword()
 	if err != nil {
 		log.Println(err.Error())
@@ -257,5 +356,116 @@ func (s *AuthApi) Signup(c *gin.Context) {
 		return
 	}
 
+	if err := SendConfirmation(&s.UserRepository, s.Mailer, &user); err != nil {
+		log.Println(err)
+	}
+
 	c.JSON(200, user)
 }
+
+// Refresh exchanges a refresh token for a new access token and refresh token.
+// Refresh tokens are single-use; presenting a used one revokes its session,
+// since it means the token has been stolen.
+func (s *AuthApi) Refresh(c *gin.Context) {
+	var payload RefreshPayload
+
+	if err := c.ShouldBindJSON(&payload); err != nil || len(payload.RefreshToken) == 0 {
+		c.JSON(400, gin.H{
+			"message": "invalid json",
+		})
+		c.Abort()
+		return
+	}
+
+	var refreshToken models.RefreshToken
+	if err := s.RefreshTokenRepository.GetByTokenHash(&refreshToken, jwt.HashRefreshToken(payload.RefreshToken)); err != nil {
+		c.JSON(401, gin.H{
+			"message": "Invalid refresh token",
+		})
+		c.Abort()
+		return
+	}
+
+	if refreshToken.RevokedAt != nil {
+		if err := s.RefreshTokenRepository.RevokeSession(refreshToken.SessionID); err != nil {
+			log.Println(err)
+		}
+
+		c.JSON(401, gin.H{
+			"message": "Invalid refresh token",
+		})
+		c.Abort()
+		return
+	}
+
+	if refreshToken.ExpiresAt.Before(time.Now()) {
+		c.JSON(401, gin.H{
+			"message": "Refresh token is expired",
+		})
+		c.Abort()
+		return
+	}
+
+	user := refreshToken.User
+	if !user.Active || user.IsLocked() {
+		if err := s.RefreshTokenRepository.RevokeSession(refreshToken.SessionID); err != nil {
+			log.Println(err)
+		}
+
+		c.JSON(401, gin.H{
+			"message": "Your account is inactive",
+		})
+		c.Abort()
+		return
+	}
+
+	tokenResponse, err := issueTokens(user, refreshToken.SessionID, func(m *models.RefreshToken) error {
+		return s.RefreshTokenRepository.Rotate(&refreshToken, m)
+	})
+	if err != nil {
+		log.Println(err)
+		c.JSON(401, gin.H{
+			"message": "Invalid refresh token",
+		})
+		c.Abort()
+		return
+	}
+
+	c.JSON(200, tokenResponse)
+}
+
+// Logout revokes the session of a refresh token, which also invalidates the
+// access tokens issued for it
+func (s *AuthApi) Logout(c *gin.Context) {
+	var payload RefreshPayload
+
+	if err := c.ShouldBindJSON(&payload); err != nil || len(payload.RefreshToken) == 0 {
+		c.JSON(400, gin.H{
+			"message": "invalid json",
+		})
+		c.Abort()
+		return
+	}
+
+	var refreshToken models.RefreshToken
+	if err := s.RefreshTokenRepository.GetByTokenHash(&refreshToken, jwt.HashRefreshToken(payload.RefreshToken)); err != nil {
+		c.JSON(401, gin.H{
+			"message": "Invalid refresh token",
+		})
+		c.Abort()
+		return
+	}
+
+	if err := s.RefreshTokenRepository.RevokeSession(refreshToken.SessionID); err != nil {
+		log.Println(err)
+		c.JSON(500, gin.H{
+			"message": "Sever error",
+		})
+		c.Abort()
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": "Logged out",
+	})
+}
@@ -0,0 +1,539 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	tensorJwt "github.com/tensoremr/server/pkg/jwt"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+// oidcCookie holds the state of a sign in between the redirect to the
+// identity provider and the callback
+const oidcCookie = "oidc_state"
+
+// oidcStateMinutes is how long users have to sign in at the identity provider
+const oidcStateMinutes = 10
+
+// OIDCProvider signs users in through an OpenID Connect identity provider
+// using the authorization code flow with PKCE
+type OIDCProvider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// audience is the aud claim, which may be a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+
+	*a = multiple
+	return nil
+}
+
+type idTokenClaims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	ExpiresAt     int64    `json:"exp"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+}
+
+// Valid ...
+func (c *idTokenClaims) Valid() error {
+	if c.ExpiresAt < time.Now().Unix() {
+		return errors.New("ID token is expired")
+	}
+
+	return nil
+}
+
+// oidcStateClaims are kept in an HTTP-only cookie, so that the PKCE verifier
+// never appears in a URL
+type oidcStateClaims struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"codeVerifier"`
+	jwt.StandardClaims
+}
+
+// NewOIDCProvider returns the provider configured by the OIDC_* environment
+// variables, or nil if OIDC_ISSUER is not set
+func NewOIDCProvider() *OIDCProvider {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if len(issuer) == 0 {
+		return nil
+	}
+
+	scopes := strings.Fields(os.Getenv("OIDC_SCOPES"))
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &OIDCProvider{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       scopes,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// getJSON decodes the JSON response of a GET request
+func (p *OIDCProvider) getJSON(url string, v interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s returned %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// getDiscovery fetches the provider's configuration the first time it is
+// needed, so that the server starts even if the provider is unreachable
+func (p *OIDCProvider) getDiscovery() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := p.getJSON(p.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.Issuer {
+		return nil, errors.New("oidc: issuer does not match the discovery document")
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// getKey returns the provider's signing key with the given ID. The key set is
+// fetched again when an unknown key is asked for, to pick up key rotation.
+func (p *OIDCProvider) getKey(discovery *oidcDiscovery, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+
+	if err := p.getJSON(discovery.JwksURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	p.keys = keys
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, errors.New("oidc: unknown signing key")
+	}
+
+	return key, nil
+}
+
+// exchange trades an authorization code for the provider's tokens
+func (p *OIDCProvider) exchange(discovery *oidcDiscovery, code string, codeVerifier string) (*oidcTokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	if len(p.ClientSecret) > 0 {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	resp, err := p.client.PostForm(discovery.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token endpoint returned %s", resp.Status)
+	}
+
+	var token oidcTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token
+func (p *OIDCProvider) verifyIDToken(discovery *oidcDiscovery, idToken string, nonce string) (*idTokenClaims, error) {
+	var claims idTokenClaims
+
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, errors.New("oidc: unexpected signing method")
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		return p.getKey(discovery, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(claims.Issuer, "/") != p.Issuer {
+		return nil, errors.New("oidc: unexpected issuer")
+	}
+
+	validAudience := false
+	for _, aud := range claims.Audience {
+		if aud == p.ClientID {
+			validAudience = true
+		}
+	}
+
+	if !validAudience {
+		return nil, errors.New("oidc: unexpected audience")
+	}
+
+	if len(claims.Nonce) == 0 || claims.Nonce != nonce {
+		return nil, errors.New("oidc: unexpected nonce")
+	}
+
+	if len(claims.Subject) == 0 {
+		return nil, errors.New("oidc: missing subject")
+	}
+
+	return &claims, nil
+}
+
+// randomString returns a random URL safe string
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func oidcStateWrapper() tensorJwt.Wrapper {
+	return tensorJwt.Wrapper{
+		SecretKey: os.Getenv("JWT_SECRET"),
+		Issuer:    os.Getenv("JWT_ISSUER"),
+	}
+}
+
+// OIDCLogin redirects to the identity provider's sign in page
+func (s *AuthApi) OIDCLogin(c *gin.Context) {
+	discovery, err := s.OIDC.getDiscovery()
+	if err != nil {
+		log.Println(err)
+		c.JSON(502, gin.H{
+			"message": "Identity provider is unavailable",
+		})
+		c.Abort()
+		return
+	}
+
+	state, err := randomString()
+	if err != nil {
+		c.AbortWithStatus(500)
+		return
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		c.AbortWithStatus(500)
+		return
+	}
+
+	codeVerifier, err := randomString()
+	if err != nil {
+		c.AbortWithStatus(500)
+		return
+	}
+
+	stateWrapper := oidcStateWrapper()
+	cookie, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &oidcStateClaims{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Minute * oidcStateMinutes).Unix(),
+			Issuer:    stateWrapper.Issuer,
+		},
+	}).SignedString([]byte(stateWrapper.SecretKey))
+	if err != nil {
+		c.AbortWithStatus(500)
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", s.OIDC.ClientID)
+	query.Set("redirect_uri", s.OIDC.RedirectURL)
+	query.Set("scope", strings.Join(s.OIDC.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	secure := strings.HasPrefix(s.OIDC.RedirectURL, "https://")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcCookie, cookie, oidcStateMinutes*60, "/oidc", "", secure, true)
+
+	c.Redirect(http.StatusFound, discovery.AuthorizationEndpoint+"?"+query.Encode())
+}
+
+// OIDCCallback completes a sign in at the identity provider. The provider's
+// subject is mapped to a user, linking it by verified email the first time,
+// and the usual tokens, or a two-factor challenge, are passed to the web client
+// in the URL fragment. Users are not created here; an admin has to create their account first.
+func (s *AuthApi) OIDCCallback(c *gin.Context) {
+	fail := func(message string) {
+		c.Redirect(http.StatusFound, AppURL()+"/oidc/callback#error="+url.QueryEscape(message))
+		c.Abort()
+	}
+
+	cookie, err := c.Cookie(oidcCookie)
+	if err != nil {
+		fail("Sign in has expired")
+		return
+	}
+
+	c.SetCookie(oidcCookie, "", -1, "/oidc", "", false, true)
+
+	stateWrapper := oidcStateWrapper()
+
+	var stateClaims oidcStateClaims
+	if _, err := jwt.ParseWithClaims(cookie, &stateClaims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+
+		return []byte(stateWrapper.SecretKey), nil
+	}); err != nil {
+		fail("Sign in has expired")
+		return
+	}
+
+	if len(c.Query("error")) > 0 {
+		fail(c.Query("error"))
+		return
+	}
+
+	if c.Query("state") != stateClaims.State {
+		fail("Invalid sign in state")
+		return
+	}
+
+	discovery, err := s.OIDC.getDiscovery()
+	if err != nil {
+		log.Println(err)
+		fail("Identity provider is unavailable")
+		return
+	}
+
+	token, err := s.OIDC.exchange(discovery, c.Query("code"), stateClaims.CodeVerifier)
+	if err != nil {
+		log.Println(err)
+		fail("Could not sign in with the identity provider")
+		return
+	}
+
+	claims, err := s.OIDC.verifyIDToken(discovery, token.IDToken, stateClaims.Nonce)
+	if err != nil {
+		log.Println(err)
+		fail("Could not sign in with the identity provider")
+		return
+	}
+
+	var user models.User
+	if err := s.UserRepository.GetByOAuth2(&user, s.OIDC.Issuer, claims.Subject); err != nil {
+		// Link the identity to an existing account the first time, but only
+		// on an email the provider has verified
+		if len(claims.Email) == 0 || !claims.EmailVerified {
+			fail("No account is linked to this identity")
+			return
+		}
+
+		if err := s.UserRepository.GetByEmail(&user, claims.Email); err != nil {
+			fail("No account is linked to this identity")
+			return
+		}
+
+		if len(user.OAuth2UID) > 0 {
+			fail("The account is linked to another identity")
+			return
+		}
+	}
+
+	if !user.Active {
+		fail("Your account is inactive")
+		return
+	}
+
+	if user.IsLocked() {
+		fail("Your account is locked")
+		return
+	}
+
+	user.OAuth2UID = claims.Subject
+	user.OAuth2Provider = s.OIDC.Issuer
+	user.OAuth2AccessToken = token.AccessToken
+	user.OAuth2RefreshToken = token.RefreshToken
+
+	if token.ExpiresIn > 0 {
+		expiry := time.Now().Add(time.Second * time.Duration(token.ExpiresIn))
+		user.OAuth2Expiry = &expiry
+	}
+
+	if err := s.UserRepository.UpdateOAuth2(&user); err != nil {
+		log.Println(err)
+		fail("Sever error")
+		return
+	}
+
+	// Accounts with two-factor authentication get the same challenge as a
+	// password sign in, which the web client completes with a code
+	if user.TOTPEnabled {
+		challenge, err := newTwoFactorToken(user)
+		if err != nil {
+			log.Println(err)
+			fail("Sever error")
+			return
+		}
+
+		fragment := url.Values{}
+		fragment.Set("twoFactorRequired", "true")
+		fragment.Set("twoFactorToken", challenge)
+
+		c.Redirect(http.StatusFound, AppURL()+"/oidc/callback#"+fragment.Encode())
+		return
+	}
+
+	tokenResponse, err := NewSession(&s.RefreshTokenRepository, user)
+	if err != nil {
+		log.Println(err)
+		fail("Sever error")
+		return
+	}
+
+	fragment := url.Values{}
+	fragment.Set("token", tokenResponse.Token)
+	fragment.Set("refreshToken", tokenResponse.RefreshToken)
+	fragment.Set("expiresIn", fmt.Sprint(tokenResponse.ExpiresIn))
+
+	c.Redirect(http.StatusFound, AppURL()+"/oidc/callback#"+fragment.Encode())
+}
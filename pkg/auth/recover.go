@@ -0,0 +1,336 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tensoremr/server/pkg/mailer"
+	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/repository"
+)
+
+// recoverTokenHours is how long a password reset link is valid
+const recoverTokenHours = 1
+
+// confirmTokenHours is how long an email confirmation link is valid
+const confirmTokenHours = 48
+
+// RecoverPayload password recovery body
+type RecoverPayload struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordPayload password reset body
+type ResetPasswordPayload struct {
+	Token           string `json:"token"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirmPassword"`
+}
+
+// ConfirmPayload email confirmation body
+type ConfirmPayload struct {
+	Token string `json:"token"`
+}
+
+// newSelectorVerifier returns a token to email to a user, along with its
+// selector, which is stored as is to look the token up, and the hash of its
+// verifier, which is stored so that database rows cannot be used as tokens
+func newSelectorVerifier() (token string, selector string, verifierHash string, err error) {
+	s := make([]byte, 16)
+	if _, err = rand.Read(s); err != nil {
+		return
+	}
+
+	v := make([]byte, 32)
+	if _, err = rand.Read(v); err != nil {
+		return
+	}
+
+	selector = base64.RawURLEncoding.EncodeToString(s)
+	verifier := base64.RawURLEncoding.EncodeToString(v)
+
+	token = selector + "." + verifier
+	verifierHash = hashVerifier(verifier)
+
+	return
+}
+
+// splitSelectorVerifier splits a token made by newSelectorVerifier
+func splitSelectorVerifier(token string) (selector string, verifier string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+func hashVerifier(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkVerifier compares a verifier with the stored hash in constant time
+func checkVerifier(verifier string, verifierHash string) bool {
+	return len(verifierHash) > 0 && subtle.ConstantTimeCompare([]byte(hashVerifier(verifier)), []byte(verifierHash)) == 1
+}
+
+// checkToken returns true if verifier matches the stored hash and the token
+// has not expired at now
+func checkToken(verifier string, verifierHash string, expiry *time.Time, now time.Time) bool {
+	return checkVerifier(verifier, verifierHash) && expiry != nil && expiry.After(now)
+}
+
+// AppURL returns the address of the web client, which serves the pages the
+// emailed links and printed verification codes point to
+func AppURL() string {
+	url := os.Getenv("APP_URL")
+	if len(url) == 0 {
+		url = "http://localhost:3000"
+	}
+
+	return strings.TrimSuffix(url, "/")
+}
+
+// SendConfirmation marks the user's email address as unconfirmed and emails
+// them a link that confirms it
+func SendConfirmation(userRepository *repository.UserRepository, m mailer.Mailer, user *models.User) error {
+	token, selector, verifierHash, err := newSelectorVerifier()
+	if err != nil {
+		return err
+	}
+
+	expiry := time.Now().Add(time.Hour * confirmTokenHours)
+
+	user.ConfirmSelector = selector
+	user.ConfirmVerifier = verifierHash
+	user.ConfirmTokenExpiry = &expiry
+	user.Confirmed = false
+
+	if err := userRepository.UpdateConfirmation(user); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\nPlease confirm your email address by opening the link below within %d hours.\n\n%s/confirm-email?token=%s\n", user.FirstName, confirmTokenHours, AppURL(), token)
+
+	return m.Send(user.Email, "Confirm your email address", body)
+}
+
+// Recover emails a password reset link to the user. It responds the same
+// whether or not the email belongs to a user, so that it cannot be used to
+// find out who has an account.
+func (s *AuthApi) Recover(c *gin.Context) {
+	var payload RecoverPayload
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(400, gin.H{
+			"message": "invalid json",
+		})
+		c.Abort()
+		return
+	}
+
+	response := gin.H{
+		"message": "If the email belongs to an account, a password reset link has been sent to it",
+	}
+
+	var user models.User
+	if err := s.UserRepository.GetByEmail(&user, payload.Email); err != nil || !user.Active {
+		c.JSON(200, response)
+		return
+	}
+
+	// Failures past this point are logged but not reported, since they only
+	// happen for existing accounts
+	token, selector, verifierHash, err := newSelectorVerifier()
+	if err != nil {
+		log.Println(err)
+		c.JSON(200, response)
+		return
+	}
+
+	expiry := time.Now().Add(time.Hour * recoverTokenHours)
+
+	user.RecoverSelector = selector
+	user.RecoverVerifier = verifierHash
+	user.RecoverTokenExpiry = &expiry
+
+	if err := s.UserRepository.UpdateRecovery(&user); err != nil {
+		log.Println(err)
+		c.JSON(200, response)
+		return
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\nA password reset was requested for your account. Open the link below within %d hour to choose a new password.\n\n%s/reset-password?token=%s\n\nIf you did not request this, you can ignore this email.\n", user.FirstName, recoverTokenHours, AppURL(), token)
+
+	if err := s.Mailer.Send(user.Email, "Reset your password", body); err != nil {
+		log.Println(err)
+	}
+
+	c.JSON(200, response)
+}
+
+// ResetPassword sets a new password using a token emailed by Recover. It
+// also unlocks the account and signs the user out of every session.
+func (s *AuthApi) ResetPassword(c *gin.Context) {
+	var payload ResetPasswordPayload
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(400, gin.H{
+			"message": "invalid json",
+		})
+		c.Abort()
+		return
+	}
+
+	if len(payload.Password) == 0 || payload.Password != payload.ConfirmPassword {
+		c.JSON(400, gin.H{
+			"message": "Passwords do no match",
+		})
+		c.Abort()
+		return
+	}
+
+	invalid := gin.H{
+		"message": "Invalid or expired password reset link",
+	}
+
+	selector, verifier, ok := splitSelectorVerifier(payload.Token)
+	if !ok {
+		c.JSON(401, invalid)
+		c.Abort()
+		return
+	}
+
+	var user models.User
+	if err := s.UserRepository.GetByRecoverSelector(&user, selector); err != nil {
+		c.JSON(401, invalid)
+		c.Abort()
+		return
+	}
+
+	if !checkToken(verifier, user.RecoverVerifier, user.RecoverTokenExpiry, time.Now()) {
+		c.JSON(401, invalid)
+		c.Abort()
+		return
+	}
+
+	user.Password = payload.Password
+	if err := user.HashPassword(); err != nil {
+		log.Println(err)
+		c.JSON(500, gin.H{
+			"msg": "error hashing password",
+		})
+		c.Abort()
+		return
+	}
+
+	user.RecoverSelector = ""
+	user.RecoverVerifier = ""
+	user.RecoverTokenExpiry = nil
+
+	if err := s.UserRepository.UpdateRecovery(&user); err != nil {
+		log.Println(err)
+		c.JSON(500, gin.H{
+			"message": "Sever error",
+		})
+		c.Abort()
+		return
+	}
+
+	user.ResetAttempts()
+	if err := s.UserRepository.UpdateLoginAttempts(&user); err != nil {
+		log.Println(err)
+	}
+
+	if err := s.RefreshTokenRepository.RevokeAllForUser(user.ID); err != nil {
+		log.Println(err)
+	}
+
+	c.JSON(200, gin.H{
+		"message": "Your password has been reset",
+	})
+}
+
+// Confirm confirms a user's email address using a token emailed by
+// SendConfirmation
+func (s *AuthApi) Confirm(c *gin.Context) {
+	var payload ConfirmPayload
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(400, gin.H{
+			"message": "invalid json",
+		})
+		c.Abort()
+		return
+	}
+
+	invalid := gin.H{
+		"message": "Invalid or expired email confirmation link",
+	}
+
+	selector, verifier, ok := splitSelectorVerifier(payload.Token)
+	if !ok {
+		c.JSON(401, invalid)
+		c.Abort()
+		return
+	}
+
+	var user models.User
+	if err := s.UserRepository.GetByConfirmSelector(&user, selector); err != nil {
+		c.JSON(401, invalid)
+		c.Abort()
+		return
+	}
+
+	if !checkToken(verifier, user.ConfirmVerifier, user.ConfirmTokenExpiry, time.Now()) {
+		c.JSON(401, invalid)
+		c.Abort()
+		return
+	}
+
+	user.ConfirmSelector = ""
+	user.ConfirmVerifier = ""
+	user.ConfirmTokenExpiry = nil
+	user.Confirmed = true
+
+	if err := s.UserRepository.UpdateConfirmation(&user); err != nil {
+		log.Println(err)
+		c.JSON(500, gin.H{
+			"message": "Sever error",
+		})
+		c.Abort()
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": "Your email address has been confirmed",
+	})
+}
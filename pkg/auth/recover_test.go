@@ -0,0 +1,110 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelectorVerifier(t *testing.T) {
+	token, selector, verifierHash, err := newSelectorVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotSelector, verifier, ok := splitSelectorVerifier(" " + token + " ")
+	if !ok || gotSelector != selector {
+		t.Fatalf("splitSelectorVerifier() = (%s, %v), want (%s, true)", gotSelector, ok, selector)
+	}
+
+	if verifierHash == verifier || strings.Contains(verifierHash, verifier) {
+		t.Errorf("newSelectorVerifier() stores the verifier in the clear")
+	}
+
+	if !checkVerifier(verifier, verifierHash) {
+		t.Errorf("checkVerifier() rejected the verifier of the token")
+	}
+
+	other, _, _, err := newSelectorVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if other == token {
+		t.Errorf("newSelectorVerifier() returned the same token twice")
+	}
+}
+
+func TestSplitSelectorVerifier(t *testing.T) {
+	for _, token := range []string{"", "abc", ".abc", "abc.", "a.b.c", "."} {
+		if _, _, ok := splitSelectorVerifier(token); ok {
+			t.Errorf("splitSelectorVerifier(%q) accepted a malformed token", token)
+		}
+	}
+}
+
+func TestCheckVerifier(t *testing.T) {
+	hash := hashVerifier("verifier")
+
+	cases := []struct {
+		name     string
+		verifier string
+		hash     string
+		want     bool
+	}{
+		{"matching", "verifier", hash, true},
+		{"tampered verifier", "verifieR", hash, false},
+		{"hash used as verifier", hash, hash, false},
+		{"no stored hash", "", "", false},
+		{"empty verifier", "", hash, false},
+	}
+
+	for _, c := range cases {
+		if got := checkVerifier(c.verifier, c.hash); got != c.want {
+			t.Errorf("%s: checkVerifier() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	now := time.Now()
+	future := now.Add(time.Hour)
+	past := now.Add(-time.Second)
+	hash := hashVerifier("verifier")
+
+	cases := []struct {
+		name     string
+		verifier string
+		expiry   *time.Time
+		want     bool
+	}{
+		{"valid", "verifier", &future, true},
+		{"expired", "verifier", &past, false},
+		{"expires now", "verifier", &now, false},
+		{"no expiry", "verifier", nil, false},
+		{"wrong verifier", "other", &future, false},
+	}
+
+	for _, c := range cases {
+		if got := checkToken(c.verifier, hash, c.expiry, now); got != c.want {
+			t.Errorf("%s: checkToken() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package auth
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tensoremr/server/pkg/jwt"
+	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/totp"
+)
+
+// twoFactorTokenMinutes is how long users have to enter their second factor
+// after their password
+const twoFactorTokenMinutes = 5
+
+// TwoFactorChallengeResponse is returned by Login instead of tokens when the
+// user has two-factor authentication enabled
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	TwoFactorToken    string `json:"twoFactorToken"`
+}
+
+// TwoFactorPayload second sign in step body
+type TwoFactorPayload struct {
+	TwoFactorToken string `json:"twoFactorToken"`
+	Code           string `json:"code"`
+}
+
+func newTwoFactorToken(user models.User) (string, error) {
+	jwtWrapper := jwt.Wrapper{
+		SecretKey:         os.Getenv("JWT_SECRET"),
+		Issuer:            os.Getenv("JWT_ISSUER"),
+		ExpirationMinutes: twoFactorTokenMinutes,
+	}
+
+	return jwtWrapper.GenerateTwoFactorToken(user)
+}
+
+// VerifySecondFactor checks a TOTP code or an unused recovery code of the
+// user. Used recovery codes are removed and the time step of used TOTP codes
+// is kept, so that neither can be used twice; callers must save the user's
+// two-factor settings when it returns true.
+func VerifySecondFactor(user *models.User, code string) bool {
+	if step, ok := totp.Validate(user.TOTPSecretKey, code, time.Now()); ok {
+		if step <= user.TOTPLastStep {
+			return false
+		}
+
+		user.TOTPLastStep = step
+		return true
+	}
+
+	if len(user.RecoveryCodes) == 0 {
+		return false
+	}
+
+	hash := totp.HashRecoveryCode(code)
+	recoveryCodes := strings.Split(user.RecoveryCodes, ",")
+
+	for i, e := range recoveryCodes {
+		if e == hash {
+			user.RecoveryCodes = strings.Join(append(recoveryCodes[:i], recoveryCodes[i+1:]...), ",")
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoginTwoFactor completes a sign in started by Login with a TOTP code or a
+// recovery code
+func (s *AuthApi) LoginTwoFactor(c *gin.Context) {
+	var payload TwoFactorPayload
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(400, gin.H{
+			"message": "invalid json",
+		})
+		c.Abort()
+		return
+	}
+
+	jwtWrapper := jwt.Wrapper{
+		SecretKey: os.Getenv("JWT_SECRET"),
+		Issuer:    os.Getenv("JWT_ISSUER"),
+	}
+
+	claims, err := jwtWrapper.ValidateToken(payload.TwoFactorToken)
+	if err != nil || claims.Subject != jwt.TwoFactorSubject {
+		c.JSON(401, gin.H{
+			"message": "Invalid two-factor token",
+		})
+		c.Abort()
+		return
+	}
+
+	var user models.User
+	if err := s.UserRepository.GetByEmail(&user, claims.Email); err != nil {
+		c.JSON(401, gin.H{
+			"message": "Invalid user credentials",
+		})
+		c.Abort()
+		return
+	}
+
+	if user.IsLocked() {
+		c.JSON(401, gin.H{
+			"message": "Your account is locked",
+		})
+		c.Abort()
+		return
+	}
+
+	if !user.Active {
+		c.JSON(401, gin.H{
+			"message": "Your account is inactive",
+		})
+		c.Abort()
+		return
+	}
+
+	if !user.TOTPEnabled || !VerifySecondFactor(&user, payload.Code) {
+		user.RecordFailedAttempt()
+		if err := s.UserRepository.UpdateLoginAttempts(&user); err != nil {
+			log.Println(err)
+		}
+
+		c.JSON(401, gin.H{
+			"message": "Invalid two-factor code",
+		})
+		c.Abort()
+		return
+	}
+
+	if err := s.UserRepository.UpdateTwoFactor(&user); err != nil {
+		log.Println(err)
+		c.JSON(500, gin.H{
+			"message": "Sever error",
+		})
+		c.Abort()
+		return
+	}
+
+	user.ResetAttempts()
+	if err := s.UserRepository.UpdateLoginAttempts(&user); err != nil {
+		log.Println(err)
+	}
+
+	tokenResponse, err := NewSession(&s.RefreshTokenRepository, user)
+	if err != nil {
+		log.Println(err)
+		c.JSON(500, gin.H{
+			"msg": "error signing token",
+		})
+		c.Abort()
+		return
+	}
+
+	c.JSON(200, tokenResponse)
+}
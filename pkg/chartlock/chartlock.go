@@ -0,0 +1,219 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package chartlock
+
+import (
+	"reflect"
+
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrorCode is the GraphQL error extension code of Error
+const ErrorCode = "CHART_LOCKED"
+
+// bypassKey is the gorm setting that lets a statement write to a locked chart
+const bypassKey = "chartlock:bypass"
+
+// exemptEntities can be written after their patient chart is locked. Amendments
+// are the only way to change a signed chart, documents are printed from it and
+// revisions record the changes amendments make
+var exemptEntities = map[string]bool{
+	"Amendment":     true,
+	"ChartDocument": true,
+	"ChartRevision": true,
+}
+
+// orderColumns link entities that don't have a patient_chart_id column to the
+// order they belong to
+var orderColumns = map[string]struct {
+	Column string
+	Table  string
+}{
+	"MedicalPrescription": {Column: "medical_prescription_order_id", Table: "medical_prescription_orders"},
+	"EyewearPrescription": {Column: "eyewear_prescription_order_id", Table: "eyewear_prescription_orders"},
+}
+
+// Error is returned for writes to an entity of a signed and locked patient chart
+type Error struct {
+	PatientChartID int
+}
+
+func (e *Error) Error() string {
+	return "This patient chart has been signed and locked, it can only be changed through an amendment"
+}
+
+// Bypass returns a session whose writes are not checked against chart locks.
+// It is meant for the amendment flow, for fulfilling orders, which confirms
+// payments and records results after the visit is signed, and for maintenance
+// such as patient merges
+func Bypass(db *gorm.DB) *gorm.DB {
+	return db.Set(bypassKey, true)
+}
+
+// RegisterCallbacks adds gorm callbacks that reject every create, update and
+// delete of an entity belonging to a locked patient chart. Raw SQL and rows of
+// many2many join tables are not checked, so chart entities should not be
+// written that way
+func RegisterCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("chartlock:create", func(db *gorm.DB) { guard(db, true) }); err != nil {
+		return err
+	}
+
+	if err := db.Callback().Update().Before("gorm:update").Register("chartlock:update", func(db *gorm.DB) { guard(db, false) }); err != nil {
+		return err
+	}
+
+	return db.Callback().Delete().Before("gorm:delete").Register("chartlock:delete", func(db *gorm.DB) { guard(db, false) })
+}
+
+func guard(db *gorm.DB, create bool) {
+	if db.Error != nil || db.Statement.Schema == nil || exemptEntities[db.Statement.Schema.Name] {
+		return
+	}
+
+	if bypass, ok := db.Get(bypassKey); ok && bypass == true {
+		return
+	}
+
+	ids := patientChartIDs(db, create)
+	if len(ids) == 0 {
+		return
+	}
+
+	var locked []int
+	if err := session(db).Model(&models.PatientChart{}).Where("id IN ? AND locked = ?", ids, true).Pluck("id", &locked).Error; err != nil {
+		db.AddError(err)
+		return
+	}
+
+	if len(locked) > 0 {
+		db.AddError(&Error{PatientChartID: locked[0]})
+	}
+}
+
+// patientChartIDs returns the patient charts the rows written by the statement belong to
+func patientChartIDs(db *gorm.DB, create bool) []int {
+	stmt := db.Statement
+
+	if stmt.Schema.Name == "PatientChart" {
+		if create {
+			return nil
+		}
+
+		return rowValues(db, stmt.Schema.PrioritizedPrimaryField.DBName)
+	}
+
+	column := "patient_chart_id"
+	order, viaOrder := orderColumns[stmt.Schema.Name]
+
+	if viaOrder {
+		column = order.Column
+	} else if stmt.Schema.LookUpField(column) == nil {
+		return nil
+	}
+
+	values := structValues(stmt, column)
+	if !create {
+		values = append(values, rowValues(db, column)...)
+	}
+
+	if !viaOrder || len(values) == 0 {
+		return values
+	}
+
+	var ids []int
+	session(db).Table(order.Table).Where("id IN ?", values).Pluck("patient_chart_id", &ids)
+
+	return ids
+}
+
+// structValues returns the non-zero values of column in the model values of the statement
+func structValues(stmt *gorm.Statement, column string) []int {
+	field := stmt.Schema.LookUpField(column)
+	if field == nil {
+		return nil
+	}
+
+	var values []int
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Struct:
+		if value, zero := field.ValueOf(stmt.ReflectValue); !zero {
+			values = appendInt(values, value)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			if value, zero := field.ValueOf(reflect.Indirect(stmt.ReflectValue.Index(i))); !zero {
+				values = appendInt(values, value)
+			}
+		}
+	}
+
+	return values
+}
+
+// rowValues loads column of the existing rows an update or delete will touch, matched
+// by the primary keys of the model values and the statement's conditions
+func rowValues(db *gorm.DB, column string) []int {
+	stmt := db.Statement
+	query := session(db).Table(stmt.Table)
+	conditions := false
+
+	if pk := stmt.Schema.PrioritizedPrimaryField; pk != nil {
+		if ids := structValues(stmt, pk.DBName); len(ids) > 0 {
+			query = query.Where(pk.DBName+" IN ?", ids)
+			conditions = true
+		}
+	}
+
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+			query = query.Clauses(where)
+			conditions = true
+		}
+	}
+
+	// Without conditions gorm refuses the statement anyway
+	if !conditions {
+		return nil
+	}
+
+	var values []int
+	query.Distinct(column).Pluck(column, &values)
+
+	return values
+}
+
+func session(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true})
+}
+
+func appendInt(values []int, value interface{}) []int {
+	v := reflect.Indirect(reflect.ValueOf(value))
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		values = append(values, int(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		values = append(values, int(v.Uint()))
+	}
+
+	return values
+}
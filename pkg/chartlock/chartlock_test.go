@@ -0,0 +1,170 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package chartlock
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+func statement(t *testing.T, value interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := db.Model(value)
+	if err := tx.Statement.Parse(value); err != nil {
+		t.Fatal(err)
+	}
+
+	tx.Statement.ReflectValue = reflect.Indirect(reflect.ValueOf(value))
+
+	return tx
+}
+
+func TestPatientChartIDsOnCreate(t *testing.T) {
+	cases := []struct {
+		name  string
+		value interface{}
+		want  []int
+	}{
+		{"struct", &models.PatientDiagnosis{PatientChartID: 7}, []int{7}},
+		{"zero value", &models.PatientDiagnosis{}, nil},
+		{"slice", &[]models.PatientDiagnosis{{PatientChartID: 3}, {}, {PatientChartID: 4}}, []int{3, 4}},
+		{"slice of pointers", &[]*models.PatientDiagnosis{{PatientChartID: 5}}, []int{5}},
+		{"new patient chart", &models.PatientChart{}, nil},
+		{"entity without a chart", &models.User{}, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := patientChartIDs(statement(t, c.value), true)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("patientChartIDs() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestStructValuesOfOrderColumn(t *testing.T) {
+	orderID := 12
+	db := statement(t, &[]models.MedicalPrescription{{MedicalPrescriptionOrderID: &orderID}, {}})
+
+	got := structValues(db.Statement, orderColumns["MedicalPrescription"].Column)
+	if want := []int{12}; !reflect.DeepEqual(got, want) {
+		t.Errorf("structValues() = %v, want %v", got, want)
+	}
+
+	if got := structValues(db.Statement, "missing_column"); got != nil {
+		t.Errorf("structValues() of a missing column = %v, want nil", got)
+	}
+}
+
+func TestAppendInt(t *testing.T) {
+	n := 9
+	var nilPointer *int
+
+	got := appendInt(nil, 1)
+	got = appendInt(got, uint(2))
+	got = appendInt(got, &n)
+	got = appendInt(got, nilPointer)
+	got = appendInt(got, "3")
+
+	if want := []int{1, 2, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("appendInt() = %v, want %v", got, want)
+	}
+}
+
+func TestBypass(t *testing.T) {
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bypass, ok := Bypass(db).Get(bypassKey); !ok || bypass != true {
+		t.Errorf("Bypass() did not mark the session")
+	}
+
+	if _, ok := db.Get(bypassKey); ok {
+		t.Errorf("Bypass() marked the original session")
+	}
+}
+
+// lockedDB returns a session whose chart lock lookups report chartID as locked
+// and whose writes are not executed
+func lockedDB(t *testing.T, chartID int) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RegisterCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+
+	noop := func(db *gorm.DB) {}
+	db.Callback().Create().Replace("gorm:create", noop)
+	db.Callback().Update().Replace("gorm:update", noop)
+	db.Callback().Query().Replace("gorm:query", func(db *gorm.DB) {
+		if ids, ok := db.Statement.Dest.(*[]int); ok && db.Statement.Table == "patient_charts" {
+			*ids = []int{chartID}
+		}
+	})
+
+	return db
+}
+
+func TestAmendLockedChart(t *testing.T) {
+	db := lockedDB(t, 1)
+
+	var lockErr *Error
+	if err := db.Create(&models.VitalSigns{PatientChartID: 1}).Error; !errors.As(err, &lockErr) || lockErr.PatientChartID != 1 {
+		t.Fatalf("Create() on a locked chart = %v, want a chart lock error", err)
+	}
+
+	// Applying an amendment writes the entity through a bypassed session and
+	// its revision through a new session of the same connection
+	amendment := Bypass(db)
+	if err := amendment.Model(&models.VitalSigns{ID: 2, PatientChartID: 1}).Update("temperature", 37.5).Error; err != nil {
+		t.Errorf("Update() through Bypass = %v, want nil", err)
+	}
+
+	revision := models.ChartRevision{PatientChartID: 1, EntityType: "VitalSigns", EntityID: 2, Revision: 2}
+	if err := amendment.Session(&gorm.Session{NewDB: true}).Create(&revision).Error; err != nil {
+		t.Errorf("Create() of the revision of an amended record = %v, want nil", err)
+	}
+}
+
+func TestError(t *testing.T) {
+	var err error = &Error{PatientChartID: 4}
+
+	var lockErr *Error
+	if !errors.As(err, &lockErr) || lockErr.PatientChartID != 4 {
+		t.Errorf("errors.As() did not return the locked patient chart")
+	}
+}
@@ -0,0 +1,80 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/repository"
+)
+
+type ChartDocumentApi struct {
+	ChartDocumentRepository repository.ChartDocumentRepository
+}
+
+// VerifyChartDocument confirms a printed document was issued. It only returns
+// what is already printed on the document, along with the checksum of the PDF
+func (s *ChartDocumentApi) VerifyChartDocument(c *gin.Context) {
+	var chartDocument models.ChartDocument
+
+	if err := s.ChartDocumentRepository.GetByVerificationCode(&chartDocument, c.Param("code")); err != nil {
+		c.JSON(404, gin.H{
+			"valid": false,
+		})
+
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"valid":    true,
+		"type":     chartDocument.Type,
+		"issuedAt": chartDocument.CreatedAt,
+		"issuedBy": chartDocument.IssuedBy.FirstName + " " + chartDocument.IssuedBy.LastName,
+		"checksum": chartDocument.Checksum,
+	})
+}
+
+// GetChartDocument serves the PDF of a chart document to a signed in user
+func (s *ChartDocumentApi) GetChartDocument(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"msg": "Invalid document id",
+		})
+		c.Abort()
+
+		return
+	}
+
+	var chartDocument models.ChartDocument
+	if err := s.ChartDocumentRepository.Get(&chartDocument, id); err != nil {
+		c.JSON(404, gin.H{
+			"msg": "Document not found",
+		})
+		c.Abort()
+
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", "inline; filename=\""+chartDocument.File.FileName+"."+chartDocument.File.Extension+"\"")
+	c.File(chartDocument.Path())
+}
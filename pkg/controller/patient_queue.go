@@ -19,17 +19,26 @@
 package controller
 
 import (
-	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tensoremr/server/pkg/graphql/graph"
 	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
 	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/pubsub"
 	"github.com/tensoremr/server/pkg/repository"
 )
 
+// displayKeepAlive is how often an idle display stream is pinged so proxies don't close it
+const displayKeepAlive = 30 * time.Second
+
 type PatientQueueApi struct {
 	PatientQueueRepository repository.PatientQueueRepository
-	AppointmentRepository repository.AppointmentRepository
+	AppointmentRepository  repository.AppointmentRepository
+	PubSub                 *pubsub.Broker
 }
 
 // GetPatientQueues ...
@@ -48,9 +57,8 @@ func (s *PatientQueueApi) GetPatientQueues(c *gin.Context) {
 	var result []*graph_models.PatientQueueWithAppointment
 
 	for _, patientQueue := range patientQueues {
-		var ids []int
-
-		if err := json.Unmarshal([]byte(patientQueue.Queue.String()), &ids); err != nil {
+		ids, err := s.PatientQueueRepository.GetAppointmentIDs(patientQueue.ID)
+		if err != nil {
 			c.JSON(400, gin.H{
 				"msg": err,
 			})
@@ -88,3 +96,89 @@ func (s *PatientQueueApi) GetPatientQueues(c *gin.Context) {
 
 	c.JSON(200, result)
 }
+
+// StreamDisplay feeds the screens in waiting rooms over Server-Sent Events. It sends a
+// "display" event with every requested queue on connect and a "queue" event whenever
+// one of them changes. Queues are picked with ?queueIds=1,2 and must be of a
+// type in models.DisplayQueueTypes
+func (s *PatientQueueApi) StreamDisplay(c *gin.Context) {
+	ids := c.Query("queueIds")
+	if len(ids) == 0 {
+		c.JSON(400, gin.H{
+			"msg": "queueIds is required",
+		})
+		c.Abort()
+
+		return
+	}
+
+	queueIDs := map[int]bool{}
+	for _, v := range strings.Split(ids, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			c.JSON(400, gin.H{
+				"msg": "Invalid queue id",
+			})
+			c.Abort()
+
+			return
+		}
+
+		queueIDs[id] = true
+	}
+
+	var topics []string
+	var patientQueueIDs []int
+	for id := range queueIDs {
+		patientQueueIDs = append(patientQueueIDs, id)
+		topics = append(topics, graph.PatientQueueIDTopic(id))
+	}
+
+	displays, err := s.PatientQueueRepository.GetDisplay(patientQueueIDs)
+	if err != nil {
+		c.JSON(500, "Something went wrong")
+		return
+	}
+
+	if len(displays) != len(patientQueueIDs) {
+		c.JSON(400, gin.H{
+			"msg": "Queue cannot be displayed",
+		})
+		c.Abort()
+
+		return
+	}
+
+	messages := s.PubSub.Subscribe(c.Request.Context(), topics...)
+	keepAlive := time.NewTicker(displayKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("display", displays)
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				return false
+			}
+
+			patientQueue, ok := message.(*graph_models.PatientQueueWithAppointment)
+			if !ok {
+				return true
+			}
+
+			display, err := s.PatientQueueRepository.GetDisplay([]int{patientQueue.ID})
+			if err != nil || len(display) == 0 {
+				return true
+			}
+
+			c.SSEvent("queue", display[0])
+		case <-keepAlive.C:
+			c.SSEvent("ping", time.Now().Unix())
+		}
+
+		return true
+	})
+}
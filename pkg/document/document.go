@@ -0,0 +1,282 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// Page layout in millimeters
+const (
+	margin         = 15.0
+	logoHeight     = 22.0
+	signatureWidth = 45.0
+	qrSize         = 30.0
+)
+
+// Letterhead is the organization header printed at the top of every document
+type Letterhead struct {
+	Name  string
+	Lines []string
+	// Logo is the path of a JPEG, PNG or GIF image
+	Logo string
+}
+
+// Field is a labelled value
+type Field struct {
+	Label string
+	Value string
+}
+
+// Table is a grid of values with a header row
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// Section is a titled part of a document body. Fields, then the table, then
+// the text are printed under the heading
+type Section struct {
+	Heading string
+	Fields  []Field
+	Table   *Table
+	Text    string
+}
+
+// Document is a printable clinical document
+type Document struct {
+	Title      string
+	Letterhead Letterhead
+	Patient    []Field
+	Sections   []Section
+	IssuedAt   time.Time
+	SignerName string
+	// Signature is the path of a JPEG, PNG or GIF image of the signer's signature
+	Signature string
+	// VerificationURL is encoded in a QR code, VerificationCode printed below it
+	VerificationURL  string
+	VerificationCode string
+}
+
+// Render writes the document as an A4 PDF to w
+func Render(w io.Writer, d Document) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin+10)
+	pdf.AliasNbPages("")
+
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-margin)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 5, fmt.Sprintf("%s - Page %d of {nb}", tr(d.Title), pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	pdf.AddPage()
+
+	writeLetterhead(pdf, tr, d.Letterhead)
+
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 8, tr(d.Title), "", 1, "C", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(0, 5, "Date: "+d.IssuedAt.Format("Jan 2, 2006"), "", 1, "R", false, 0, "")
+	pdf.Ln(2)
+
+	writeFields(pdf, tr, d.Patient)
+
+	for _, section := range d.Sections {
+		pdf.Ln(4)
+		writeSection(pdf, tr, section)
+	}
+
+	if err := writeSignature(pdf, tr, d); err != nil {
+		return err
+	}
+
+	return pdf.Output(w)
+}
+
+func writeLetterhead(pdf *fpdf.Fpdf, tr func(string) string, l Letterhead) {
+	top := pdf.GetY()
+	left := margin
+
+	if name, ok := registerImage(pdf, "logo", l.Logo); ok {
+		pdf.ImageOptions(name, margin, top, 0, logoHeight, false, fpdf.ImageOptions{}, 0, "")
+		left += pdf.GetImageInfo(name).Width()*logoHeight/pdf.GetImageInfo(name).Height() + 5
+	}
+
+	pdf.SetXY(left, top)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, tr(l.Name), "", 2, "L", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range l.Lines {
+		pdf.CellFormat(0, 4.5, tr(line), "", 2, "L", false, 0, "")
+	}
+
+	y := pdf.GetY()
+	if y < top+logoHeight {
+		y = top + logoHeight
+	}
+
+	pageWidth, _ := pdf.GetPageSize()
+
+	pdf.SetDrawColor(160, 160, 160)
+	pdf.Line(margin, y+2, pageWidth-margin, y+2)
+	pdf.SetXY(margin, y+5)
+}
+
+func writeFields(pdf *fpdf.Fpdf, tr func(string) string, fields []Field) {
+	for _, field := range fields {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(40, 6, tr(field.Label), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, 6, tr(field.Value), "", "L", false)
+	}
+}
+
+func writeSection(pdf *fpdf.Fpdf, tr func(string) string, s Section) {
+	if len(s.Heading) > 0 {
+		pdf.SetFont("Helvetica", "B", 11)
+		pdf.CellFormat(0, 7, tr(s.Heading), "B", 1, "L", false, 0, "")
+		pdf.Ln(1)
+	}
+
+	writeFields(pdf, tr, s.Fields)
+
+	if s.Table != nil && len(s.Table.Header) > 0 {
+		pageWidth, _ := pdf.GetPageSize()
+		width := (pageWidth - 2*margin) / float64(len(s.Table.Header))
+
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.SetFillColor(235, 235, 235)
+		for _, title := range s.Table.Header {
+			pdf.CellFormat(width, 6, tr(title), "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(-1)
+
+		pdf.SetFont("Helvetica", "", 9)
+		for _, row := range s.Table.Rows {
+			for i := range s.Table.Header {
+				var value string
+				if i < len(row) {
+					value = row[i]
+				}
+
+				pdf.CellFormat(width, 6, tr(value), "1", 0, "C", false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+
+	if len(strings.TrimSpace(s.Text)) > 0 {
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, 5.5, tr(s.Text), "", "L", false)
+	}
+}
+
+// writeSignature prints the signer's signature and name on the left and the
+// verification QR code on the right, keeping them together on one page
+func writeSignature(pdf *fpdf.Fpdf, tr func(string) string, d Document) error {
+	pageWidth, pageHeight := pdf.GetPageSize()
+	if pdf.GetY()+qrSize+25 > pageHeight-margin-10 {
+		pdf.AddPage()
+	}
+
+	pdf.Ln(10)
+	top := pdf.GetY()
+
+	if name, ok := registerImage(pdf, "signature", d.Signature); ok {
+		pdf.ImageOptions(name, margin, top, signatureWidth, 0, false, fpdf.ImageOptions{}, 0, "")
+		info := pdf.GetImageInfo(name)
+		pdf.SetY(top + info.Height()*signatureWidth/info.Width())
+	} else {
+		pdf.SetY(top + 15)
+	}
+
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.Line(margin, pdf.GetY()+1, margin+60, pdf.GetY()+1)
+	pdf.Ln(2)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(60, 5, tr(d.SignerName), "", 2, "L", false, 0, "")
+
+	if len(d.VerificationURL) == 0 {
+		return nil
+	}
+
+	png, err := qrcode.Encode(d.VerificationURL, qrcode.Medium, 256)
+	if err != nil {
+		return err
+	}
+
+	pdf.RegisterImageOptionsReader("qr", fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+	pdf.ImageOptions("qr", pageWidth-margin-qrSize, top, qrSize, qrSize, false, fpdf.ImageOptions{}, 0, "")
+
+	pdf.SetXY(pageWidth-margin-qrSize-20, top+qrSize)
+	pdf.SetFont("Helvetica", "", 7)
+	pdf.CellFormat(qrSize+20, 4, "Scan to verify", "", 2, "R", false, 0, "")
+	pdf.CellFormat(qrSize+20, 4, d.VerificationCode, "", 2, "R", false, 0, "")
+
+	return nil
+}
+
+// registerImage adds the image at path to the document under name. Missing
+// files and unsupported formats are skipped
+func registerImage(pdf *fpdf.Fpdf, name string, path string) (string, bool) {
+	if len(path) == 0 {
+		return "", false
+	}
+
+	var imageType string
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")) {
+	case "jpg", "jpeg":
+		imageType = "JPG"
+	case "png":
+		imageType = "PNG"
+	case "gif":
+		imageType = "GIF"
+	default:
+		return "", false
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(content))
+	if !pdf.Ok() {
+		pdf.ClearError()
+		return "", false
+	}
+
+	return name, true
+}
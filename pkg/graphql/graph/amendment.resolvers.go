@@ -5,17 +5,53 @@ package graph
 
 import (
 	"context"
+	"errors"
 
 	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/middleware"
 	"github.com/tensoremr/server/pkg/models"
 	deepCopy "github.com/ulule/deepcopier"
 )
 
 func (r *mutationResolver) CreateAmendment(ctx context.Context, input graph_models.AmendmentInput) (*models.Amendment, error) {
-	var entity models.Amendment
-	deepCopy.Copy(&input).To(&entity)
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
 
-	if err := r.AmendmentRepository.Create(&entity); err != nil {
+	entity := models.Amendment{
+		PatientChartID: input.PatientChartID,
+		EntityType:     input.EntityType,
+		EntityID:       input.EntityID,
+		Field:          input.Field,
+		NewValue:       input.NewValue,
+		Note:           input.Note,
+		AuthorID:       &user.ID,
+	}
+
+	if input.Reason != nil {
+		entity.Reason = *input.Reason
+	}
+
+	if input.RequireCosign != nil {
+		entity.RequiresCosign = *input.RequireCosign
+	}
+
+	if err := r.AmendmentRepository.WithContext(ctx).Create(&entity); err != nil {
+		return nil, err
+	}
+
+	if err := r.AmendmentRepository.Get(&entity, entity.ID); err != nil {
 		return nil, err
 	}
 
@@ -26,7 +62,7 @@ func (r *mutationResolver) UpdateAmendment(ctx context.Context, input graph_mode
 	var entity models.Amendment
 	deepCopy.Copy(&input).To(&entity)
 
-	if err := r.AmendmentRepository.Update(&entity); err != nil {
+	if err := r.AmendmentRepository.WithContext(ctx).Update(&entity); err != nil {
 		return nil, err
 	}
 
@@ -34,13 +70,48 @@ func (r *mutationResolver) UpdateAmendment(ctx context.Context, input graph_mode
 }
 
 func (r *mutationResolver) DeleteAmendment(ctx context.Context, id int) (bool, error) {
-	if err := r.AmendmentRepository.Delete(id); err != nil {
+	if err := r.AmendmentRepository.WithContext(ctx).Delete(id); err != nil {
 		return false, err
 	}
 
 	return true, nil
 }
 
+func (r *mutationResolver) CosignAmendment(ctx context.Context, id int) (*models.Amendment, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	isPhysician := false
+	for _, e := range user.UserTypes {
+		if e.Title == "Physician" {
+			isPhysician = true
+		}
+	}
+
+	if !isPhysician {
+		return nil, errors.New("Amendments can only be co-signed by a physician")
+	}
+
+	var entity models.Amendment
+	if err := r.AmendmentRepository.WithContext(ctx).Cosign(&entity, id, user.ID); err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
 func (r *queryResolver) Amendment(ctx context.Context, id int) (*models.Amendment, error) {
 	var entity models.Amendment
 	if err := r.AmendmentRepository.Get(&entity, id); err != nil {
@@ -5,10 +5,10 @@ package graph
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"time"
 
+	"github.com/tensoremr/server/pkg/audit"
 	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
 	"github.com/tensoremr/server/pkg/middleware"
 	"github.com/tensoremr/server/pkg/models"
@@ -19,10 +19,18 @@ func (r *mutationResolver) NewAppointment(ctx context.Context, input graph_model
 	var appointment models.Appointment
 	deepCopy.Copy(&input).To(&appointment)
 
-	if err := r.AppointmentRepository.CreateNewAppointment(&appointment, input.BillingID, input.InvoiceNo); err != nil {
+	overrideCapacity := input.OverrideCapacity != nil && *input.OverrideCapacity
+
+	if err := r.AppointmentRepository.WithContext(ctx).CreateNewAppointment(&appointment, input.BillingID, input.InvoiceNo, overrideCapacity); err != nil {
 		return nil, err
 	}
 
+	if appointment.CapacityOverride {
+		if err := audit.Record(ctx, r.AuditLogRepository, models.CreateAuditAction, "Appointment", appointment.ID, map[string]audit.Change{"capacityOverride": {Old: false, New: true}}); err != nil {
+			return nil, err
+		}
+	}
+
 	return &appointment, nil
 }
 
@@ -79,7 +87,7 @@ func (r *mutationResolver) UpdateAppointment(ctx context.Context, input graph_mo
 		appointment.ProviderName = *input.ProviderName
 	}
 
-	if existing.UserID != *input.UserID {
+	if input.UserID != nil && existing.UserID != *input.UserID {
 		var user models.User
 		if err := r.UserRepository.Get(&user, *input.UserID); err != nil {
 			return nil, err
@@ -90,15 +98,68 @@ func (r *mutationResolver) UpdateAppointment(ctx context.Context, input graph_mo
 		appointment.UserID = user.ID
 	}
 
-	if err := r.AppointmentRepository.Update(&appointment); err != nil {
+	if input.AppointmentStatusID != nil && *input.AppointmentStatusID != existing.AppointmentStatusID {
+		var cancelled models.AppointmentStatus
+		if err := r.AppointmentStatusRepository.GetByTitle(&cancelled, "Cancelled"); err == nil && cancelled.ID == *input.AppointmentStatusID {
+			if err := r.AppointmentRepository.WithContext(ctx).Update(&appointment); err != nil {
+				return nil, err
+			}
+
+			if err := r.WaitlistRepository.ReleaseSlot(existing); err != nil {
+				return nil, err
+			}
+
+			return &appointment, nil
+		}
+	}
+
+	rescheduled := appointment.UserID != 0 || (input.CheckInTime != nil && !input.CheckInTime.Equal(existing.CheckInTime))
+	if !rescheduled {
+		if err := r.AppointmentRepository.WithContext(ctx).Update(&appointment); err != nil {
+			return nil, err
+		}
+
+		return &appointment, nil
+	}
+
+	if appointment.UserID == 0 {
+		appointment.UserID = existing.UserID
+	}
+
+	if appointment.CheckInTime.IsZero() {
+		appointment.CheckInTime = existing.CheckInTime
+	}
+
+	if appointment.Emergency == nil {
+		appointment.Emergency = existing.Emergency
+	}
+
+	overrideCapacity := input.OverrideCapacity != nil && *input.OverrideCapacity
+
+	if err := r.AppointmentRepository.WithContext(ctx).Reschedule(&appointment, overrideCapacity); err != nil {
 		return nil, err
 	}
 
+	if appointment.CapacityOverride && !existing.CapacityOverride {
+		if err := audit.Record(ctx, r.AuditLogRepository, models.UpdateAuditAction, "Appointment", appointment.ID, map[string]audit.Change{"capacityOverride": {Old: false, New: true}}); err != nil {
+			return nil, err
+		}
+	}
+
 	return &appointment, nil
 }
 
 func (r *mutationResolver) DeleteAppointment(ctx context.Context, id int) (bool, error) {
-	if err := r.AppointmentRepository.Delete(id); err != nil {
+	var appointment models.Appointment
+	if err := r.AppointmentRepository.Get(&appointment, id); err != nil {
+		return false, err
+	}
+
+	if err := r.AppointmentRepository.WithContext(ctx).Delete(id); err != nil {
+		return false, err
+	}
+
+	if err := r.WaitlistRepository.ReleaseSlot(appointment); err != nil {
 		return false, err
 	}
 
@@ -329,9 +390,8 @@ func (r *queryResolver) GetUserAppointments(ctx context.Context, page models.Pag
 		patientQueues := queueSubscription.Subscriptions
 
 		for _, patientQueue := range patientQueues {
-			var ids []int
-
-			if err := json.Unmarshal([]byte(patientQueue.Queue.String()), &ids); err != nil {
+			ids, err := r.PatientQueueRepository.GetAppointmentIDs(patientQueue.ID)
+			if err != nil {
 				return nil, err
 			}
 
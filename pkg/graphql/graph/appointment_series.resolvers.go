@@ -0,0 +1,113 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+
+	"github.com/tensoremr/server/pkg/audit"
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+func (r *mutationResolver) NewAppointmentSeries(ctx context.Context, input graph_models.AppointmentSeriesInput) (*models.AppointmentSeries, error) {
+	series := models.AppointmentSeries{
+		PatientID:   input.PatientID,
+		UserID:      input.UserID,
+		RoomID:      input.RoomID,
+		VisitTypeID: input.VisitTypeID,
+		StartTime:   input.StartTime,
+		Frequency:   input.Frequency,
+		Interval:    input.Interval,
+		Occurrences: input.Occurrences,
+		Until:       input.Until,
+	}
+
+	if input.MedicalDepartment != nil {
+		series.MedicalDepartment = *input.MedicalDepartment
+	}
+
+	if input.Credit != nil {
+		series.Credit = *input.Credit
+	}
+
+	overrideCapacity := input.OverrideCapacity != nil && *input.OverrideCapacity
+
+	if err := r.AppointmentSeriesRepository.WithContext(ctx).Save(&series, overrideCapacity); err != nil {
+		return nil, err
+	}
+
+	for _, appointment := range series.Appointments {
+		if appointment.CapacityOverride {
+			if err := audit.Record(ctx, r.AuditLogRepository, models.CreateAuditAction, "Appointment", appointment.ID, map[string]audit.Change{"capacityOverride": {Old: false, New: true}}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := r.AppointmentSeriesRepository.Get(&series, series.ID); err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+}
+
+func (r *mutationResolver) UpdateFollowingAppointments(ctx context.Context, input graph_models.AppointmentSeriesUpdateInput) (*models.AppointmentSeries, error) {
+	var changes models.Appointment
+
+	if input.CheckInTime != nil {
+		changes.CheckInTime = *input.CheckInTime
+	}
+
+	if input.UserID != nil {
+		changes.UserID = *input.UserID
+	}
+
+	if input.RoomID != nil {
+		changes.RoomID = *input.RoomID
+	}
+
+	if input.VisitTypeID != nil {
+		changes.VisitTypeID = *input.VisitTypeID
+	}
+
+	overrideCapacity := input.OverrideCapacity != nil && *input.OverrideCapacity
+
+	series, err := r.AppointmentSeriesRepository.WithContext(ctx).UpdateFollowing(input.AppointmentID, changes, overrideCapacity)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, appointment := range series.Appointments {
+		if appointment.CapacityOverride {
+			if err := audit.Record(ctx, r.AuditLogRepository, models.UpdateAuditAction, "Appointment", appointment.ID, map[string]audit.Change{"capacityOverride": {Old: false, New: true}}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := r.AppointmentSeriesRepository.Get(series, series.ID); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+func (r *mutationResolver) CancelFollowingAppointments(ctx context.Context, appointmentID int) (bool, error) {
+	if err := r.AppointmentSeriesRepository.WithContext(ctx).CancelFollowing(appointmentID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *queryResolver) AppointmentSeries(ctx context.Context, id int) (*models.AppointmentSeries, error) {
+	var series models.AppointmentSeries
+
+	if err := r.AppointmentSeriesRepository.Get(&series, id); err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+}
@@ -0,0 +1,75 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"time"
+
+	"github.com/tensoremr/server/pkg/graphql/graph/generated"
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+func (r *auditLogResolver) Changes(ctx context.Context, obj *models.AuditLog) (*string, error) {
+	if len(obj.Changes) == 0 {
+		return nil, nil
+	}
+
+	changes := obj.Changes.String()
+	return &changes, nil
+}
+
+func (r *queryResolver) AuditLog(ctx context.Context, page models.PaginationInput, filter *graph_models.AuditLogFilter) (*graph_models.AuditLogConnection, error) {
+	var f models.AuditLog
+	var startDate, endDate *time.Time
+
+	if filter != nil {
+		if filter.UserEmail != nil {
+			f.UserEmail = *filter.UserEmail
+		}
+
+		if filter.OperationName != nil {
+			f.OperationName = *filter.OperationName
+		}
+
+		if filter.Action != nil {
+			f.Action = *filter.Action
+		}
+
+		if filter.EntityType != nil {
+			f.EntityType = *filter.EntityType
+		}
+
+		if filter.EntityID != nil {
+			f.EntityID = *filter.EntityID
+		}
+
+		startDate = filter.StartDate
+		endDate = filter.EndDate
+	}
+
+	entities, count, err := r.AuditLogRepository.GetAll(page, &f, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := make([]*graph_models.AuditLogEdge, len(entities))
+
+	for i, entity := range entities {
+		e := entity
+
+		edges[i] = &graph_models.AuditLogEdge{
+			Node: &e,
+		}
+	}
+
+	pageInfo, totalCount := GetPageInfo(entities, count, page)
+	return &graph_models.AuditLogConnection{PageInfo: pageInfo, Edges: edges, TotalCount: totalCount}, nil
+}
+
+// AuditLog returns generated.AuditLogResolver implementation.
+func (r *Resolver) AuditLog() generated.AuditLogResolver { return &auditLogResolver{r} }
+
+type auditLogResolver struct{ *Resolver }
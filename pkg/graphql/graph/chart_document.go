@@ -0,0 +1,387 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package graph
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tensoremr/server/pkg/auth"
+	"github.com/tensoremr/server/pkg/document"
+	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/util"
+)
+
+// chartDocumentTitles are the titles printed on each type of chart document
+var chartDocumentTitles = map[models.ChartDocumentType]string{
+	models.ChartDocumentPrescription:          "Prescription",
+	models.ChartDocumentEyewearPrescription:   "Eyewear Prescription",
+	models.ChartDocumentSickLeave:             "Sick Leave Certificate",
+	models.ChartDocumentMedicalRecommendation: "Medical Recommendation",
+	models.ChartDocumentReferral:              "Referral Letter",
+}
+
+// generateChartDocument renders a document of the patient chart signed by the
+// issuer, stores the PDF and attaches it to the chart's appointment
+func (r *Resolver) generateChartDocument(documentType models.ChartDocumentType, patientChartID int, referralID *int, issuer models.User) (*models.ChartDocument, error) {
+	title, ok := chartDocumentTitles[documentType]
+	if !ok {
+		return nil, errors.New("Unknown document type")
+	}
+
+	if issuer.Signature == nil {
+		return nil, errors.New("Add a signature to your profile before generating documents")
+	}
+
+	var patientChart models.PatientChart
+	if err := r.PatientChartRepository.GetWithDetails(&patientChart, patientChartID); err != nil {
+		return nil, err
+	}
+
+	var appointment models.Appointment
+	if err := r.AppointmentRepository.GetWithDetails(&appointment, patientChart.AppointmentID); err != nil {
+		return nil, err
+	}
+
+	var organizationDetails models.OrganizationDetails
+	if err := r.OrganizationDetailsRepository.Get(&organizationDetails); err != nil {
+		return nil, err
+	}
+
+	sections, err := r.chartDocumentSections(documentType, patientChart, referralID)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := verificationCode()
+	if err != nil {
+		return nil, err
+	}
+
+	d := document.Document{
+		Title:            title,
+		Letterhead:       letterhead(organizationDetails),
+		Patient:          patientFields(appointment),
+		Sections:         sections,
+		IssuedAt:         time.Now(),
+		SignerName:       "Dr. " + issuer.FirstName + " " + issuer.LastName,
+		Signature:        filePath(issuer.Signature),
+		VerificationURL:  auth.AppURL() + "/documents/verify/" + code,
+		VerificationCode: code,
+	}
+
+	var buf bytes.Buffer
+	if err := document.Render(&buf, d); err != nil {
+		return nil, err
+	}
+
+	checksum := sha256.Sum256(buf.Bytes())
+	size := int64(buf.Len())
+
+	fileName, _, hash, ext := HashFileName(fmt.Sprintf("%s_%d.pdf", strings.ToLower(string(documentType)), patientChartID))
+
+	entity := models.ChartDocument{
+		Type:           documentType,
+		PatientChartID: patientChartID,
+		AppointmentID:  appointment.ID,
+		ReferralID:     referralID,
+		File: models.File{
+			ContentType: "application/pdf",
+			Size:        size,
+			FileName:    fileName,
+			Extension:   ext,
+			Hash:        hash,
+		},
+		Checksum:         hex.EncodeToString(checksum[:]),
+		VerificationCode: code,
+		IssuedByID:       issuer.ID,
+	}
+
+	if err := os.MkdirAll(models.ChartDocumentDir, 0750); err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(entity.Path(), buf.Bytes(), 0640); err != nil {
+		return nil, err
+	}
+
+	if err := r.ChartDocumentRepository.Save(&entity); err != nil {
+		return nil, err
+	}
+
+	if err := r.ChartDocumentRepository.Get(&entity, entity.ID); err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+// chartDocumentSections returns the body of a document of the patient chart
+func (r *Resolver) chartDocumentSections(documentType models.ChartDocumentType, patientChart models.PatientChart, referralID *int) ([]document.Section, error) {
+	switch documentType {
+	case models.ChartDocumentPrescription:
+		var order models.MedicalPrescriptionOrder
+		if err := r.MedicalPrescriptionOrderRepository.GetByPatientChartID(&order, patientChart.ID); err != nil || len(order.MedicalPrescriptions) == 0 {
+			return nil, errors.New("There are no medical prescriptions to print")
+		}
+
+		table := document.Table{Header: []string{"Medication", "Sig", "Refills", "Generic", "Substitution"}}
+		var directions []string
+		for _, e := range order.MedicalPrescriptions {
+			table.Rows = append(table.Rows, []string{e.Medication, stringValue(e.Sig), intValue(e.Refill), yesNo(e.Generic), yesNo(e.SubstitutionAllowed)})
+
+			if e.DirectionToPatient != nil && len(*e.DirectionToPatient) > 0 {
+				directions = append(directions, e.Medication+": "+*e.DirectionToPatient)
+			}
+		}
+
+		return []document.Section{
+			{Heading: "Rx", Table: &table},
+			{Heading: "Directions", Text: strings.Join(directions, "\n")},
+		}, nil
+
+	case models.ChartDocumentEyewearPrescription:
+		var refraction models.DiagnosticProcedure
+		if err := r.DiagnosticProcedureRepository.GetRefraction(&refraction, patientChart.ID); err != nil {
+			return nil, errors.New("There is no refraction to print")
+		}
+
+		sections := []document.Section{
+			{
+				Heading: "Refraction",
+				Table: &document.Table{
+					Header: []string{"", "Eye", "Sph", "Cyl", "Axis"},
+					Rows: [][]string{
+						{"Distance", "Right", stringValue(refraction.RightDistanceFinalSph), stringValue(refraction.RightDistanceFinalCyl), stringValue(refraction.RightDistanceFinalAxis)},
+						{"Distance", "Left", stringValue(refraction.LeftDistanceFinalSph), stringValue(refraction.LeftDistanceFinalCyl), stringValue(refraction.LeftDistanceFinalAxis)},
+						{"Near", "Right", stringValue(refraction.RightNearFinalSph), stringValue(refraction.RightNearFinalCyl), stringValue(refraction.RightNearFinalAxis)},
+						{"Near", "Left", stringValue(refraction.LeftNearFinalSph), stringValue(refraction.LeftNearFinalCyl), stringValue(refraction.LeftNearFinalAxis)},
+					},
+				},
+				Fields: []document.Field{
+					{Label: "Far PD", Value: stringValue(refraction.FarPd)},
+					{Label: "Near PD", Value: stringValue(refraction.NearPd)},
+				},
+			},
+		}
+
+		var order models.EyewearPrescriptionOrder
+		if err := r.EyewearPrescriptionOrderRepository.GetByPatientChartID(&order, patientChart.ID); err == nil {
+			for _, e := range order.EyewearPrescriptions {
+				sections = append(sections, document.Section{Heading: "Lens", Text: lensOptions(e)})
+			}
+		}
+
+		return sections, nil
+
+	case models.ChartDocumentSickLeave:
+		if patientChart.SickLeave == nil || len(strings.TrimSpace(*patientChart.SickLeave)) == 0 {
+			return nil, errors.New("The patient chart has no sick leave")
+		}
+
+		return []document.Section{{Text: *patientChart.SickLeave}}, nil
+
+	case models.ChartDocumentMedicalRecommendation:
+		if patientChart.MedicalRecommendation == nil || len(strings.TrimSpace(*patientChart.MedicalRecommendation)) == 0 {
+			return nil, errors.New("The patient chart has no medical recommendation")
+		}
+
+		return []document.Section{
+			{Heading: "Diagnosis", Text: diagnosesText(patientChart.Diagnoses)},
+			{Heading: "Recommendation", Text: *patientChart.MedicalRecommendation},
+		}, nil
+
+	case models.ChartDocumentReferral:
+		if referralID == nil {
+			return nil, errors.New("A referral is required")
+		}
+
+		var referral models.Referral
+		if err := r.ReferralRepository.Get(&referral, *referralID); err != nil {
+			return nil, err
+		}
+
+		if referral.PatientChartID != patientChart.ID {
+			return nil, errors.New("The referral does not belong to this patient chart")
+		}
+
+		return []document.Section{
+			{Fields: []document.Field{{Label: "Referred to", Value: referral.ReferredToName}}},
+			{Heading: "Reason for referral", Text: referral.Reason},
+			{Heading: "Diagnosis", Text: diagnosesText(patientChart.Diagnoses)},
+			{Heading: "Summary", Text: stringValue(patientChart.SummaryNote)},
+		}, nil
+	}
+
+	return nil, errors.New("Unknown document type")
+}
+
+func letterhead(organizationDetails models.OrganizationDetails) document.Letterhead {
+	l := document.Letterhead{
+		Name: stringValue(organizationDetails.Name),
+		Logo: filePath(organizationDetails.Logo),
+	}
+
+	for _, line := range []*string{organizationDetails.Address, organizationDetails.Address2} {
+		if len(stringValue(line)) > 0 {
+			l.Lines = append(l.Lines, *line)
+		}
+	}
+
+	var phones []string
+	for _, phone := range []*string{organizationDetails.PhoneNo, organizationDetails.PhoneNo2} {
+		if len(stringValue(phone)) > 0 {
+			phones = append(phones, *phone)
+		}
+	}
+
+	if len(phones) > 0 {
+		l.Lines = append(l.Lines, "Tel: "+strings.Join(phones, " / "))
+	}
+
+	for _, line := range []*string{organizationDetails.Email, organizationDetails.Website} {
+		if len(stringValue(line)) > 0 {
+			l.Lines = append(l.Lines, *line)
+		}
+	}
+
+	return l
+}
+
+func patientFields(appointment models.Appointment) []document.Field {
+	patient := appointment.Patient
+
+	fields := []document.Field{
+		{Label: "Patient", Value: patient.FirstName + " " + patient.LastName},
+		{Label: "Patient ID", Value: strconv.Itoa(patient.ID)},
+	}
+
+	if !patient.DateOfBirth.IsZero() {
+		fields = append(fields, document.Field{Label: "Age", Value: strconv.Itoa(util.Age(patient.DateOfBirth))})
+	}
+
+	if len(patient.Gender) > 0 {
+		fields = append(fields, document.Field{Label: "Gender", Value: patient.Gender})
+	}
+
+	return append(fields, document.Field{Label: "Visit date", Value: appointment.CheckInTime.Format("Jan 2, 2006")})
+}
+
+func diagnosesText(diagnoses []models.PatientDiagnosis) string {
+	var lines []string
+	for _, e := range diagnoses {
+		if e.Differential {
+			continue
+		}
+
+		line := e.FullDescription
+		if len(e.Location) > 0 {
+			line += " (" + e.Location + ")"
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func lensOptions(e models.EyewearPrescription) string {
+	options := []struct {
+		name  string
+		value *bool
+	}{
+		{"Glass", e.Glass},
+		{"Plastic", e.Plastic},
+		{"Single vision", e.SingleVision},
+		{"Photochromatic", e.PhotoChromatic},
+		{"Glare free", e.GlareFree},
+		{"Scratch resistant", e.ScratchResistant},
+		{"Bifocal", e.Bifocal},
+		{"Progressive", e.Progressive},
+		{"Two separate glasses", e.TwoSeparateGlasses},
+		{"High index", e.HighIndex},
+		{"Tint", e.Tint},
+		{"Blue cut", e.BlueCut},
+	}
+
+	var selected []string
+	for _, option := range options {
+		if option.value != nil && *option.value {
+			selected = append(selected, option.name)
+		}
+	}
+
+	return strings.Join(selected, ", ")
+}
+
+// filePath returns the path of an uploaded file on disk
+func filePath(file *models.File) string {
+	if file == nil {
+		return ""
+	}
+
+	return "files/" + file.FileName + "_" + file.Hash + "." + file.Extension
+}
+
+// verificationCode returns a random code that identifies a chart document
+func verificationCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.EncodeToString(b), nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+func intValue(i *int) string {
+	if i == nil {
+		return ""
+	}
+
+	return strconv.Itoa(*i)
+}
+
+func yesNo(b *bool) string {
+	if b == nil {
+		return ""
+	}
+
+	if *b {
+		return "Yes"
+	}
+
+	return "No"
+}
@@ -0,0 +1,62 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tensoremr/server/pkg/graphql/graph/generated"
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/middleware"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+func (r *chartDocumentResolver) URL(ctx context.Context, obj *models.ChartDocument) (string, error) {
+	return fmt.Sprintf("/chartDocuments/%d", obj.ID), nil
+}
+
+func (r *mutationResolver) GenerateChartDocument(ctx context.Context, input graph_models.ChartDocumentInput) (*models.ChartDocument, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	isPhysician := false
+	for _, e := range user.UserTypes {
+		if e.Title == "Physician" {
+			isPhysician = true
+		}
+	}
+
+	if !isPhysician {
+		return nil, errors.New("You are not authorized to perform this action")
+	}
+
+	if err := r.UserRepository.Get(&user, user.ID); err != nil {
+		return nil, err
+	}
+
+	return r.generateChartDocument(input.Type, input.PatientChartID, input.ReferralID, user)
+}
+
+func (r *queryResolver) ChartDocuments(ctx context.Context, patientChartID int) ([]*models.ChartDocument, error) {
+	return r.ChartDocumentRepository.GetByPatientChart(patientChartID)
+}
+
+// ChartDocument returns generated.ChartDocumentResolver implementation.
+func (r *Resolver) ChartDocument() generated.ChartDocumentResolver { return &chartDocumentResolver{r} }
+
+type chartDocumentResolver struct{ *Resolver }
@@ -0,0 +1,29 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"time"
+
+	"github.com/tensoremr/server/pkg/graphql/graph/generated"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+func (r *chartRevisionResolver) Data(ctx context.Context, obj *models.ChartRevision) (string, error) {
+	return obj.Data.String(), nil
+}
+
+func (r *queryResolver) ChartRevisions(ctx context.Context, patientChartID int, entityType *string) ([]*models.ChartRevision, error) {
+	return r.ChartRevisionRepository.GetByPatientChart(patientChartID, entityType)
+}
+
+func (r *queryResolver) ChartAsOf(ctx context.Context, patientChartID int, timestamp time.Time) ([]*models.ChartRevision, error) {
+	return r.ChartRevisionRepository.AsOf(patientChartID, timestamp)
+}
+
+// ChartRevision returns generated.ChartRevisionResolver implementation.
+func (r *Resolver) ChartRevision() generated.ChartRevisionResolver { return &chartRevisionResolver{r} }
+
+type chartRevisionResolver struct{ *Resolver }
@@ -79,6 +79,12 @@ func (r *mutationResolver) CreateChat(ctx context.Context, input graph_models.Ch
 		return nil, err
 	}
 
+	r.PublishNotification(graph_models.Notification{
+		ID:      chat.ID,
+		Type:    graph_models.NotifTypeChat,
+		Message: chatMessage.Body,
+	}, recipient.ID)
+
 	return &chat, nil
 }
 
@@ -136,6 +142,12 @@ func (r *mutationResolver) SendMessage(ctx context.Context, input graph_models.C
 		return nil, err
 	}
 
+	r.PublishNotification(graph_models.Notification{
+		ID:      input.ChatID,
+		Type:    graph_models.NotifTypeChat,
+		Message: chatMessage.Body,
+	}, recipient.UserID)
+
 	return &chatMessage, nil
 }
 
@@ -342,7 +354,43 @@ func (r *queryResolver) GetCommonChat(ctx context.Context, recipientID int) (*mo
 }
 
 func (r *subscriptionResolver) Notification(ctx context.Context) (<-chan *graph_models.Notification, error) {
-	panic(fmt.Errorf("not implemented"))
+	// Get current user
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	messages := r.PubSub.Subscribe(ctx, notificationTopic, userNotificationTopic(user.ID))
+	notifications := make(chan *graph_models.Notification, 1)
+
+	go func() {
+		defer close(notifications)
+
+		for message := range messages {
+			notification, ok := message.(*graph_models.Notification)
+			if !ok {
+				continue
+			}
+
+			select {
+			case notifications <- notification:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return notifications, nil
 }
 
 // Subscription returns generated.SubscriptionResolver implementation.
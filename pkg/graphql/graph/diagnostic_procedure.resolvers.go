@@ -35,10 +35,16 @@ func (r *mutationResolver) OrderDiagnosticProcedure(ctx context.Context, input g
 
 	// Save diagnostic procedure
 	var diagnosticProcedureOrder models.DiagnosticProcedureOrder
-	if err := r.DiagnosticProcedureOrderRepository.Save(&diagnosticProcedureOrder, input.DiagnosticProcedureTypeID, input.PatientChartID, input.PatientID, input.BillingID, user, input.OrderNote, input.ReceptionNote); err != nil {
+	if err := r.DiagnosticProcedureOrderRepository.WithContext(ctx).Save(&diagnosticProcedureOrder, input.DiagnosticProcedureTypeID, input.PatientChartID, input.PatientID, input.BillingID, user, input.OrderNote, input.ReceptionNote); err != nil {
 		return nil, err
 	}
 
+	r.PublishNotification(graph_models.Notification{
+		ID:      diagnosticProcedureOrder.ID,
+		Type:    graph_models.NotifTypeDiagnosticProcedureOrder,
+		Message: "New diagnostic procedure order",
+	})
+
 	return &diagnosticProcedureOrder, nil
 }
 
@@ -70,21 +76,27 @@ func (r *mutationResolver) OrderAndConfirmDiagnosticProcedure(ctx context.Contex
 	}
 
 	var diagnosticProcedureOrder models.DiagnosticProcedureOrder
-	if err := r.DiagnosticProcedureOrderRepository.Save(&diagnosticProcedureOrder, input.DiagnosticProcedureTypeID, patientChart.ID, appointment.PatientID, input.BillingID, user, input.OrderNote, ""); err != nil {
+	if err := r.DiagnosticProcedureOrderRepository.WithContext(ctx).Save(&diagnosticProcedureOrder, input.DiagnosticProcedureTypeID, patientChart.ID, appointment.PatientID, input.BillingID, user, input.OrderNote, ""); err != nil {
 		return nil, err
 	}
 
-	if err := r.DiagnosticProcedureOrderRepository.Confirm(&diagnosticProcedureOrder, diagnosticProcedureOrder.ID, input.InvoiceNo); err != nil {
+	if err := r.DiagnosticProcedureOrderRepository.WithContext(ctx).Confirm(&diagnosticProcedureOrder, diagnosticProcedureOrder.ID, input.InvoiceNo); err != nil {
 		return nil, err
 	}
 
+	r.PublishNotification(graph_models.Notification{
+		ID:      diagnosticProcedureOrder.ID,
+		Type:    graph_models.NotifTypeDiagnosticProcedureOrder,
+		Message: "New diagnostic procedure order",
+	})
+
 	return &diagnosticProcedureOrder, nil
 }
 
 func (r *mutationResolver) ConfirmDiagnosticProcedureOrder(ctx context.Context, id int, invoiceNo string) (*models.DiagnosticProcedureOrder, error) {
 	var entity models.DiagnosticProcedureOrder
 
-	if err := r.DiagnosticProcedureOrderRepository.Confirm(&entity, id, invoiceNo); err != nil {
+	if err := r.DiagnosticProcedureOrderRepository.WithContext(ctx).Confirm(&entity, id, invoiceNo); err != nil {
 		return nil, err
 	}
 
@@ -99,7 +111,7 @@ func (r *mutationResolver) UpdateDiagnosticProcedureOrder(ctx context.Context, i
 		entity.Status = models.DiagnosticProcedureOrderStatus(*input.Status)
 	}
 
-	if err := r.DiagnosticProcedureOrderRepository.Update(&entity); err != nil {
+	if err := r.DiagnosticProcedureOrderRepository.WithContext(ctx).Update(&entity); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,55 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/casbin/casbin/v2"
+	"github.com/tensoremr/server/pkg/middleware"
+)
+
+// HasPermission implements the @hasPermission directive. It checks the casbin
+// policy for the user of the request's JWT before resolving the field.
+func HasPermission(enforcer *casbin.Enforcer) func(ctx context.Context, obj interface{}, next graphql.Resolver, object string, action string) (interface{}, error) {
+	return func(ctx context.Context, obj interface{}, next graphql.Resolver, object string, action string) (interface{}, error) {
+		gc, err := middleware.GinContextFromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		email := gc.GetString("email")
+		if len(email) == 0 {
+			return nil, errors.New("Cannot find user")
+		}
+
+		ok, err := enforcer.Enforce(email, object, action)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, errors.New("You are not authorized to perform this action")
+		}
+
+		return next(ctx)
+	}
+}
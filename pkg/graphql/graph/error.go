@@ -0,0 +1,45 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/tensoremr/server/pkg/chartlock"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ErrorPresenter adds a machine readable code to the errors clients are expected
+// to handle, such as writes to a locked patient chart
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	presented := graphql.DefaultErrorPresenter(ctx, err)
+
+	var chartLocked *chartlock.Error
+	if errors.As(err, &chartLocked) {
+		if presented.Extensions == nil {
+			presented.Extensions = map[string]interface{}{}
+		}
+
+		presented.Extensions["code"] = chartlock.ErrorCode
+		presented.Extensions["patientChartId"] = chartLocked.PatientChartID
+	}
+
+	return presented
+}
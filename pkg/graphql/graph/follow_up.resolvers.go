@@ -33,17 +33,23 @@ func (r *mutationResolver) OrderFollowUp(ctx context.Context, input graph_models
 	}
 
 	var entity models.FollowUpOrder
-	if err := r.FollowUpOrderRepository.Save(&entity, input.PatientChartID, input.PatientID, user, input.ReceptionNote); err != nil {
+	if err := r.FollowUpOrderRepository.WithContext(ctx).Save(&entity, input.PatientChartID, input.PatientID, user, input.ReceptionNote); err != nil {
 		return nil, err
 	}
 
+	r.PublishNotification(graph_models.Notification{
+		ID:      entity.ID,
+		Type:    graph_models.NotifTypeFollowUpOrder,
+		Message: "New follow-up order",
+	})
+
 	return &entity, nil
 }
 
 func (r *mutationResolver) ConfirmFollowUpOrder(ctx context.Context, input graph_models.ConfirmFollowUpOrderInput) (*graph_models.ConfirmFollowUpOrderResult, error) {
 	var entity models.FollowUpOrder
 
-	if err := r.FollowUpOrderRepository.ConfirmOrder(&entity, input.FollowUpOrderID, input.FollowUpID, input.BillingID, input.InvoiceNo, input.RoomID, input.CheckInTime); err != nil {
+	if err := r.FollowUpOrderRepository.WithContext(ctx).ConfirmOrder(&entity, input.FollowUpOrderID, input.FollowUpID, input.BillingID, input.InvoiceNo, input.RoomID, input.CheckInTime); err != nil {
 		return nil, err
 	}
 
@@ -192,17 +192,23 @@ func (r *mutationResolver) OrderLab(ctx context.Context, input graph_models.Orde
 
 	// Save lab order
 	var labOrder models.LabOrder
-	if err := r.LabOrderRepository.Save(&labOrder, input.LabTypeID, input.PatientChartID, input.PatientID, input.BillingIds, user, input.OrderNote, input.ReceptionNote); err != nil {
+	if err := r.LabOrderRepository.WithContext(ctx).Save(&labOrder, input.LabTypeID, input.PatientChartID, input.PatientID, input.BillingIds, user, input.OrderNote, input.ReceptionNote); err != nil {
 		return nil, err
 	}
 
+	r.PublishNotification(graph_models.Notification{
+		ID:      labOrder.ID,
+		Type:    graph_models.NotifTypeLabOrder,
+		Message: "New lab order",
+	})
+
 	return &labOrder, nil
 }
 
 func (r *mutationResolver) ConfirmLabOrder(ctx context.Context, id int, invoiceNo string) (*models.LabOrder, error) {
 	var entity models.LabOrder
 
-	if err := r.LabOrderRepository.Confirm(&entity, id, invoiceNo); err != nil {
+	if err := r.LabOrderRepository.WithContext(ctx).Confirm(&entity, id, invoiceNo); err != nil {
 		return nil, err
 	}
 
@@ -217,7 +223,7 @@ func (r *mutationResolver) UpdateLabOrder(ctx context.Context, input graph_model
 		entity.Status = models.LabOrderStatus(*input.Status)
 	}
 
-	if err := r.LabOrderRepository.Update(&entity); err != nil {
+	if err := r.LabOrderRepository.WithContext(ctx).Update(&entity); err != nil {
 		return nil, err
 	}
 
@@ -252,14 +258,20 @@ func (r *mutationResolver) OrderAndConfirmLab(ctx context.Context, input graph_m
 	}
 
 	var labOrder models.LabOrder
-	if err := r.LabOrderRepository.Save(&labOrder, input.LabTypeID, patientChart.ID, input.PatientID, input.BillingIds, user, input.OrderNote, ""); err != nil {
+	if err := r.LabOrderRepository.WithContext(ctx).Save(&labOrder, input.LabTypeID, patientChart.ID, input.PatientID, input.BillingIds, user, input.OrderNote, ""); err != nil {
 		return nil, err
 	}
 
-	if err := r.LabOrderRepository.Confirm(&labOrder, labOrder.ID, input.InvoiceNo); err != nil {
+	if err := r.LabOrderRepository.WithContext(ctx).Confirm(&labOrder, labOrder.ID, input.InvoiceNo); err != nil {
 		return nil, err
 	}
 
+	r.PublishNotification(graph_models.Notification{
+		ID:      labOrder.ID,
+		Type:    graph_models.NotifTypeLabOrder,
+		Message: "New lab order",
+	})
+
 	return &labOrder, nil
 }
 
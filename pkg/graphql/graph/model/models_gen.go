@@ -60,8 +60,14 @@ type AmendmentFilter struct {
 }
 
 type AmendmentInput struct {
-	PatientChartID int    `json:"patientChartId"`
-	Note           string `json:"note"`
+	PatientChartID int     `json:"patientChartId"`
+	Note           string  `json:"note"`
+	EntityType     *string `json:"entityType"`
+	EntityID       *int    `json:"entityId"`
+	Field          *string `json:"field"`
+	NewValue       *string `json:"newValue"`
+	Reason         *string `json:"reason"`
+	RequireCosign  *bool   `json:"requireCosign"`
 }
 
 type AmendmentUpdateInput struct {
@@ -111,6 +117,31 @@ type AppointmentInput struct {
 	PatientChartID    *int       `json:"patientChartId"`
 	InvoiceNo         *string    `json:"invoiceNo"`
 	BillingID         *int       `json:"billingId"`
+	OverrideCapacity  *bool      `json:"overrideCapacity"`
+}
+
+type AppointmentSeriesInput struct {
+	PatientID         int                        `json:"patientId"`
+	UserID            int                        `json:"userId"`
+	RoomID            int                        `json:"roomId"`
+	VisitTypeID       int                        `json:"visitTypeId"`
+	MedicalDepartment *string                    `json:"medicalDepartment"`
+	Credit            *bool                      `json:"credit"`
+	StartTime         time.Time                  `json:"startTime"`
+	Frequency         models.RecurrenceFrequency `json:"frequency"`
+	Interval          int                        `json:"interval"`
+	Occurrences       *int                       `json:"occurrences"`
+	Until             *time.Time                 `json:"until"`
+	OverrideCapacity  *bool                      `json:"overrideCapacity"`
+}
+
+type AppointmentSeriesUpdateInput struct {
+	AppointmentID    int        `json:"appointmentId"`
+	CheckInTime      *time.Time `json:"checkInTime"`
+	UserID           *int       `json:"userId"`
+	RoomID           *int       `json:"roomId"`
+	VisitTypeID      *int       `json:"visitTypeId"`
+	OverrideCapacity *bool      `json:"overrideCapacity"`
 }
 
 type AppointmentStatusConnection struct {
@@ -146,6 +177,29 @@ type AppointmentUpdateInput struct {
 	UserID              *int       `json:"userId"`
 	PatientChartID      *int       `json:"patientChartId"`
 	ProviderName        *string    `json:"providerName"`
+	OverrideCapacity    *bool      `json:"overrideCapacity"`
+}
+
+type AuditLogConnection struct {
+	TotalCount int             `json:"totalCount"`
+	PageInfo   *PageInfo       `json:"pageInfo"`
+	Edges      []*AuditLogEdge `json:"edges"`
+}
+
+func (AuditLogConnection) IsConnection() {}
+
+type AuditLogEdge struct {
+	Node *models.AuditLog `json:"node"`
+}
+
+type AuditLogFilter struct {
+	UserEmail     *string             `json:"userEmail"`
+	OperationName *string             `json:"operationName"`
+	Action        *models.AuditAction `json:"action"`
+	EntityType    *string             `json:"entityType"`
+	EntityID      *int                `json:"entityId"`
+	StartDate     *time.Time          `json:"startDate"`
+	EndDate       *time.Time          `json:"endDate"`
 }
 
 type BillingConnection struct {
@@ -179,6 +233,12 @@ type ChangePasswordInput struct {
 	ConfirmPassword  string `json:"confirmPassword"`
 }
 
+type ChartDocumentInput struct {
+	PatientChartID int                      `json:"patientChartId"`
+	Type           models.ChartDocumentType `json:"type"`
+	ReferralID     *int                     `json:"referralId"`
+}
+
 type ChatInput struct {
 	RecipientID int    `json:"recipientId"`
 	Message     string `json:"message"`
@@ -1509,6 +1569,12 @@ type LoginInput struct {
 	Password string `json:"password"`
 }
 
+type LoginResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"`
+}
+
 type MedicalPrescriptionConnection struct {
 	TotalCount int                        `json:"totalCount"`
 	PageInfo   *PageInfo                  `json:"pageInfo"`
@@ -1608,6 +1674,27 @@ type NewTodo struct {
 	UserID string `json:"userId"`
 }
 
+type NoteTemplateFilter struct {
+	Section   *models.NoteTemplateSection `json:"section"`
+	Specialty *string                     `json:"specialty"`
+}
+
+type NoteTemplateInput struct {
+	Title     string                     `json:"title"`
+	Section   models.NoteTemplateSection `json:"section"`
+	Specialty *string                    `json:"specialty"`
+	Body      string                     `json:"body"`
+	Global    *bool                      `json:"global"`
+}
+
+type NoteTemplateUpdateInput struct {
+	ID        int                         `json:"id"`
+	Title     *string                     `json:"title"`
+	Section   *models.NoteTemplateSection `json:"section"`
+	Specialty *string                     `json:"specialty"`
+	Body      *string                     `json:"body"`
+}
+
 // Copyright 2021 Kidus Tiliksew
 //
 // This file is part of Tensor EMR.
@@ -2190,6 +2277,18 @@ type PatientInputV2 struct {
 	Memo                   *string       `json:"memo"`
 }
 
+type PatientMergeConnection struct {
+	TotalCount int                 `json:"totalCount"`
+	PageInfo   *PageInfo           `json:"pageInfo"`
+	Edges      []*PatientMergeEdge `json:"edges"`
+}
+
+func (PatientMergeConnection) IsConnection() {}
+
+type PatientMergeEdge struct {
+	Node *models.PatientMerge `json:"node"`
+}
+
 type PatientQueueInput struct {
 	QueueName string           `json:"queueName"`
 	Queue     []string         `json:"queue"`
@@ -2287,6 +2386,12 @@ type PaymentWaiverUpdateInput struct {
 	Approved  *bool `json:"approved"`
 }
 
+type PermissionInput struct {
+	Role   string `json:"role"`
+	Object string `json:"object"`
+	Action string `json:"action"`
+}
+
 type PharmacyConnection struct {
 	TotalCount int             `json:"totalCount"`
 	PageInfo   *PageInfo       `json:"pageInfo"`
@@ -2352,6 +2457,15 @@ type PhysicalExamFindingUpdateInput struct {
 	Note           *string `json:"note"`
 }
 
+type PostOpProtocolStepInput struct {
+	Delay        int                       `json:"delay"`
+	DelayUnit    models.PostOpDelayUnit    `json:"delayUnit"`
+	VisitTypeID  int                       `json:"visitTypeId"`
+	RoomID       int                       `json:"roomId"`
+	ProviderRule models.PostOpProviderRule `json:"providerRule"`
+	UserID       *int                      `json:"userId"`
+}
+
 type PrescriptionOrdersFilter struct {
 	OrderedByID *int    `json:"orderedById"`
 	Status      *string `json:"status"`
@@ -2362,6 +2476,29 @@ type ProgressNote struct {
 	Appointments   []*models.Appointment  `json:"appointments"`
 }
 
+type ProviderScheduleBreakInput struct {
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+}
+
+type ProviderScheduleInput struct {
+	UserID    int                           `json:"userId"`
+	Weekday   int                           `json:"weekday"`
+	StartTime string                        `json:"startTime"`
+	EndTime   string                        `json:"endTime"`
+	RoomID    *int                          `json:"roomId"`
+	Breaks    []*ProviderScheduleBreakInput `json:"breaks"`
+}
+
+type ProviderScheduleUpdateInput struct {
+	ID        int                           `json:"id"`
+	Weekday   *int                          `json:"weekday"`
+	StartTime *string                       `json:"startTime"`
+	EndTime   *string                       `json:"endTime"`
+	RoomID    *int                          `json:"roomId"`
+	Breaks    []*ProviderScheduleBreakInput `json:"breaks"`
+}
+
 type ReferralConnection struct {
 	TotalCount int             `json:"totalCount"`
 	PageInfo   *PageInfo       `json:"pageInfo"`
@@ -2466,6 +2603,26 @@ type RoomOrder struct {
 //
 // You should have received a copy of the GNU General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
+type ScheduleExceptionConnection struct {
+	TotalCount int                      `json:"totalCount"`
+	PageInfo   *PageInfo                `json:"pageInfo"`
+	Edges      []*ScheduleExceptionEdge `json:"edges"`
+}
+
+func (ScheduleExceptionConnection) IsConnection() {}
+
+type ScheduleExceptionEdge struct {
+	Node *models.ScheduleException `json:"node"`
+}
+
+type ScheduleExceptionInput struct {
+	UserID    *int                         `json:"userId"`
+	Type      models.ScheduleExceptionType `json:"type"`
+	StartTime time.Time                    `json:"startTime"`
+	EndTime   time.Time                    `json:"endTime"`
+	Reason    *string                      `json:"reason"`
+}
+
 type SearchResult struct {
 	Patients  []*models.Patient `json:"patients"`
 	Providers []*models.User    `json:"providers"`
@@ -3061,6 +3218,11 @@ type Todo struct {
 	Done bool   `json:"done"`
 }
 
+type TotpEnrollment struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+}
+
 type TreatmentConnection struct {
 	TotalCount int              `json:"totalCount"`
 	PageInfo   *PageInfo        `json:"pageInfo"`
@@ -3221,7 +3383,8 @@ type VisitTypeEdge struct {
 }
 
 type VisitTypeInput struct {
-	Title *string `json:"title"`
+	Title        *string `json:"title"`
+	SlotDuration *int    `json:"slotDuration"`
 }
 
 type VisitTypeOrder struct {
@@ -3340,6 +3503,35 @@ type VitalSignsUpdateInput struct {
 	LeftLensMeterCyl         *string  `json:"leftLensMeterCyl"`
 }
 
+type WaitlistEntryConnection struct {
+	TotalCount int                  `json:"totalCount"`
+	PageInfo   *PageInfo            `json:"pageInfo"`
+	Edges      []*WaitlistEntryEdge `json:"edges"`
+}
+
+func (WaitlistEntryConnection) IsConnection() {}
+
+type WaitlistEntryEdge struct {
+	Node *models.WaitlistEntry `json:"node"`
+}
+
+type WaitlistEntryFilter struct {
+	PatientID   *int                        `json:"patientId"`
+	UserID      *int                        `json:"userId"`
+	VisitTypeID *int                        `json:"visitTypeId"`
+	Status      *models.WaitlistEntryStatus `json:"status"`
+}
+
+type WaitlistEntryInput struct {
+	PatientID     int       `json:"patientId"`
+	UserID        *int      `json:"userId"`
+	VisitTypeID   *int      `json:"visitTypeId"`
+	PreferredFrom time.Time `json:"preferredFrom"`
+	PreferredTo   time.Time `json:"preferredTo"`
+	Priority      *int      `json:"priority"`
+	Note          *string   `json:"note"`
+}
+
 type DateOfBirthInputType string
 
 const (
@@ -3431,16 +3623,30 @@ func (e Destination) MarshalGQL(w io.Writer) {
 type NotifType string
 
 const (
-	NotifTypeChat NotifType = "CHAT"
+	NotifTypeChat                     NotifType = "CHAT"
+	NotifTypeDiagnosticProcedureOrder NotifType = "DIAGNOSTIC_PROCEDURE_ORDER"
+	NotifTypeLabOrder                 NotifType = "LAB_ORDER"
+	NotifTypeTreatmentOrder           NotifType = "TREATMENT_ORDER"
+	NotifTypeSurgicalOrder            NotifType = "SURGICAL_ORDER"
+	NotifTypeReferralOrder            NotifType = "REFERRAL_ORDER"
+	NotifTypeFollowUpOrder            NotifType = "FOLLOW_UP_ORDER"
+	NotifTypePaymentWaiver            NotifType = "PAYMENT_WAIVER"
 )
 
 var AllNotifType = []NotifType{
 	NotifTypeChat,
+	NotifTypeDiagnosticProcedureOrder,
+	NotifTypeLabOrder,
+	NotifTypeTreatmentOrder,
+	NotifTypeSurgicalOrder,
+	NotifTypeReferralOrder,
+	NotifTypeFollowUpOrder,
+	NotifTypePaymentWaiver,
 }
 
 func (e NotifType) IsValid() bool {
 	switch e {
-	case NotifTypeChat:
+	case NotifTypeChat, NotifTypeDiagnosticProcedureOrder, NotifTypeLabOrder, NotifTypeTreatmentOrder, NotifTypeSurgicalOrder, NotifTypeReferralOrder, NotifTypeFollowUpOrder, NotifTypePaymentWaiver:
 		return true
 	}
 	return false
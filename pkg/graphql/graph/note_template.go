@@ -0,0 +1,46 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package graph
+
+import "github.com/tensoremr/server/pkg/models"
+
+// canEditNoteTemplate returns true if the user may change the template. Only
+// admins may change global templates, and only the owner may change the others
+func canEditNoteTemplate(user models.User, template models.NoteTemplate) bool {
+	if template.UserID == nil {
+		return isAdmin(user)
+	}
+
+	return *template.UserID == user.ID
+}
+
+// canUseNoteTemplate returns true if the template is global or the user's own
+func canUseNoteTemplate(user models.User, template models.NoteTemplate) bool {
+	return template.UserID == nil || *template.UserID == user.ID
+}
+
+func isAdmin(user models.User) bool {
+	for _, e := range user.UserTypes {
+		if e.Title == "Admin" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,191 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"errors"
+
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/middleware"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+func (r *mutationResolver) SaveNoteTemplate(ctx context.Context, input graph_models.NoteTemplateInput) (*models.NoteTemplate, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	entity := models.NoteTemplate{
+		Title:     input.Title,
+		Section:   input.Section,
+		Specialty: input.Specialty,
+		Body:      input.Body,
+		UserID:    &user.ID,
+	}
+
+	if input.Global != nil && *input.Global {
+		if !isAdmin(user) {
+			return nil, errors.New("You are not authorized to perform this action")
+		}
+
+		entity.UserID = nil
+	}
+
+	if err := r.NoteTemplateRepository.Save(&entity); err != nil {
+		return nil, err
+	}
+
+	if err := r.NoteTemplateRepository.Get(&entity, entity.ID); err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+func (r *mutationResolver) UpdateNoteTemplate(ctx context.Context, input graph_models.NoteTemplateUpdateInput) (*models.NoteTemplate, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	var entity models.NoteTemplate
+	if err := r.NoteTemplateRepository.Get(&entity, input.ID); err != nil {
+		return nil, err
+	}
+
+	if !canEditNoteTemplate(user, entity) {
+		return nil, errors.New("You are not authorized to perform this action")
+	}
+
+	if input.Title != nil {
+		entity.Title = *input.Title
+	}
+
+	if input.Section != nil {
+		entity.Section = *input.Section
+	}
+
+	if input.Specialty != nil {
+		entity.Specialty = input.Specialty
+	}
+
+	if input.Body != nil {
+		entity.Body = *input.Body
+	}
+
+	if err := r.NoteTemplateRepository.Update(&entity); err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+func (r *mutationResolver) DeleteNoteTemplate(ctx context.Context, id int) (*int, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	var entity models.NoteTemplate
+	if err := r.NoteTemplateRepository.Get(&entity, id); err != nil {
+		return nil, err
+	}
+
+	if !canEditNoteTemplate(user, entity) {
+		return nil, errors.New("You are not authorized to perform this action")
+	}
+
+	if err := r.NoteTemplateRepository.Delete(id); err != nil {
+		return nil, err
+	}
+
+	return &id, nil
+}
+
+func (r *queryResolver) NoteTemplates(ctx context.Context, filter *graph_models.NoteTemplateFilter) ([]*models.NoteTemplate, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	var section *models.NoteTemplateSection
+	var specialty *string
+
+	if filter != nil {
+		section = filter.Section
+		specialty = filter.Specialty
+	}
+
+	return r.NoteTemplateRepository.GetForUser(user.ID, section, specialty)
+}
+
+func (r *queryResolver) ApplyTemplate(ctx context.Context, patientChartID int, templateID int) (string, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return "", errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return "", err
+	}
+
+	var template models.NoteTemplate
+	if err := r.NoteTemplateRepository.Get(&template, templateID); err != nil {
+		return "", err
+	}
+
+	if !canUseNoteTemplate(user, template) {
+		return "", errors.New("You are not authorized to perform this action")
+	}
+
+	return r.NoteTemplateRepository.Render(&template, patientChartID)
+}
@@ -52,7 +52,7 @@ func (r *mutationResolver) SavePatient(ctx context.Context, input graph_models.P
 	}
 
 	// Save
-	if err := r.PatientRepository.Save(&patient); err != nil {
+	if err := r.PatientRepository.WithContext(ctx).Save(&patient); err != nil {
 		return nil, err
 	}
 
@@ -113,7 +113,7 @@ func (r *mutationResolver) SavePatientV2(ctx context.Context, input graph_models
 		})
 	}
 	// Save
-	if err := r.PatientRepository.Save(&patient); err != nil {
+	if err := r.PatientRepository.WithContext(ctx).Save(&patient); err != nil {
 		return nil, err
 	}
 
@@ -159,7 +159,7 @@ func (r *mutationResolver) UpdatePatient(ctx context.Context, input graph_models
 		})
 	}
 
-	if err := r.PatientRepository.Update(&patient); err != nil {
+	if err := r.PatientRepository.WithContext(ctx).Update(&patient); err != nil {
 		return nil, err
 	}
 
@@ -167,7 +167,7 @@ func (r *mutationResolver) UpdatePatient(ctx context.Context, input graph_models
 }
 
 func (r *mutationResolver) DeletePatient(ctx context.Context, id int) (bool, error) {
-	if err := r.PatientRepository.Delete(id); err != nil {
+	if err := r.PatientRepository.WithContext(ctx).Delete(id); err != nil {
 		return false, err
 	}
 
@@ -333,3 +333,12 @@ func (r *queryResolver) FindSimilarPatients(ctx context.Context, input graph_mod
 		ByPhone: byPhone,
 	}, nil
 }
+
+func (r *queryResolver) NoShowStats(ctx context.Context, patientID int) (*models.NoShowStats, error) {
+	stats, err := r.AppointmentRepository.NoShowStats(patientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -18,7 +18,7 @@ func (r *mutationResolver) SavePatientChart(ctx context.Context, input graph_mod
 	var entity models.PatientChart
 	deepCopy.Copy(&input).To(&entity)
 
-	if err := r.PatientChartRepository.Save(&entity); err != nil {
+	if err := r.PatientChartRepository.WithContext(ctx).Save(&entity); err != nil {
 		return nil, err
 	}
 
@@ -29,7 +29,7 @@ func (r *mutationResolver) UpdatePatientChart(ctx context.Context, input graph_m
 	var entity models.PatientChart
 	deepCopy.Copy(&input).To(&entity)
 
-	if err := r.PatientChartRepository.Update(&entity); err != nil {
+	if err := r.PatientChartRepository.WithContext(ctx).Update(&entity); err != nil {
 		return nil, err
 	}
 
@@ -69,7 +69,7 @@ func (r *mutationResolver) LockPatientChart(ctx context.Context, id int) (*model
 	}
 
 	var entity models.PatientChart
-	if err := r.PatientChartRepository.SignAndLock(&entity, id, &user.ID); err != nil {
+	if err := r.PatientChartRepository.WithContext(ctx).SignAndLock(&entity, id, &user.ID); err != nil {
 		return nil, err
 	}
 
@@ -80,7 +80,7 @@ func (r *mutationResolver) SaveVitalSigns(ctx context.Context, input graph_model
 	var entity models.VitalSigns
 	deepCopy.Copy(&input).To(&entity)
 
-	if err := r.VitalSignsRepository.Save(&entity); err != nil {
+	if err := r.VitalSignsRepository.WithContext(ctx).Save(&entity); err != nil {
 		return nil, err
 	}
 
@@ -91,7 +91,7 @@ func (r *mutationResolver) UpdateVitalSigns(ctx context.Context, input graph_mod
 	var entity models.VitalSigns
 	deepCopy.Copy(&input).To(&entity)
 
-	if err := r.VitalSignsRepository.Update(&entity); err != nil {
+	if err := r.VitalSignsRepository.WithContext(ctx).Update(&entity); err != nil {
 		return nil, err
 	}
 
@@ -102,7 +102,7 @@ func (r *mutationResolver) SaveOphthalmologyExam(ctx context.Context, input grap
 	var entity models.OpthalmologyExam
 	deepCopy.Copy(&input).To(&entity)
 
-	if err := r.OpthalmologyExamRepository.Save(&entity); err != nil {
+	if err := r.OpthalmologyExamRepository.WithContext(ctx).Save(&entity); err != nil {
 		return nil, err
 	}
 
@@ -113,7 +113,7 @@ func (r *mutationResolver) UpdateOphthalmologyExam(ctx context.Context, input gr
 	var entity models.OpthalmologyExam
 	deepCopy.Copy(&input).To(&entity)
 
-	if err := r.OpthalmologyExamRepository.Update(&entity); err != nil {
+	if err := r.OpthalmologyExamRepository.WithContext(ctx).Update(&entity); err != nil {
 		return nil, err
 	}
 
@@ -5,6 +5,7 @@ package graph
 
 import (
 	"context"
+	"time"
 
 	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
 	"github.com/tensoremr/server/pkg/models"
@@ -83,3 +84,12 @@ func (r *queryResolver) PatientEncounterLimitByUser(ctx context.Context, userID
 
 	return &entity, nil
 }
+
+func (r *queryResolver) ProviderAvailability(ctx context.Context, userID int, from time.Time, to time.Time) ([]*models.ProviderAvailability, error) {
+	availability, err := r.AppointmentRepository.ProviderAvailability(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return availability, nil
+}
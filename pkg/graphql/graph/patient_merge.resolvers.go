@@ -0,0 +1,98 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"errors"
+
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/middleware"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+func (r *mutationResolver) MergePatients(ctx context.Context, survivorID int, mergedID int) (*models.PatientMerge, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	merge, err := r.PatientMergeRepository.WithContext(ctx).Merge(survivorID, mergedID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.PatientMergeRepository.Get(merge, merge.ID); err != nil {
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+func (r *mutationResolver) UndoPatientMerge(ctx context.Context, id int) (*models.PatientMerge, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	merge, err := r.PatientMergeRepository.WithContext(ctx).Undo(id, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.PatientMergeRepository.Get(merge, merge.ID); err != nil {
+		return nil, err
+	}
+
+	return merge, nil
+}
+
+func (r *queryResolver) PotentialDuplicates(ctx context.Context, patientID int) ([]*models.PatientDuplicate, error) {
+	duplicates, err := r.PatientRepository.FindDuplicates(patientID)
+	if err != nil {
+		return nil, err
+	}
+
+	return duplicates, nil
+}
+
+func (r *queryResolver) PatientMerges(ctx context.Context, page models.PaginationInput, patientID *int) (*graph_models.PatientMergeConnection, error) {
+	entities, count, err := r.PatientMergeRepository.GetAll(page, patientID)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := make([]*graph_models.PatientMergeEdge, len(entities))
+
+	for i, entity := range entities {
+		e := entity
+
+		edges[i] = &graph_models.PatientMergeEdge{
+			Node: &e,
+		}
+	}
+
+	pageInfo, totalCount := GetPageInfo(entities, count, page)
+	return &graph_models.PatientMergeConnection{PageInfo: pageInfo, Edges: edges, TotalCount: totalCount}, nil
+}
@@ -0,0 +1,109 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/middleware"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+// PatientQueueIDTopic is the topic updates of a single patient queue are published to
+func PatientQueueIDTopic(patientQueueID int) string {
+	return fmt.Sprintf("patientQueue.%d", patientQueueID)
+}
+
+// GetPatientQueueWithAppointment resolves the appointments of a patient queue in queue order
+func (r *Resolver) GetPatientQueueWithAppointment(patientQueue *models.PatientQueue) (*graph_models.PatientQueueWithAppointment, error) {
+	ids, err := r.PatientQueueRepository.GetAppointmentIDs(patientQueue.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	page := models.PaginationInput{Page: 0, Size: 1000}
+
+	appointments, _, _ := r.AppointmentRepository.GetByIds(ids, page)
+	var orderedAppointments []*models.Appointment
+
+	for _, id := range ids {
+		for _, appointment := range appointments {
+			if appointment.ID == id {
+				a := appointment
+				orderedAppointments = append(orderedAppointments, &a)
+			}
+		}
+	}
+
+	return &graph_models.PatientQueueWithAppointment{
+		ID:        int(patientQueue.ID),
+		QueueName: patientQueue.QueueName,
+		QueueType: patientQueue.QueueType,
+		Queue:     orderedAppointments,
+	}, nil
+}
+
+// PublishPatientQueueUpdates sends the current state of the given patient queues
+// to patientQueueUpdated subscribers
+func (r *Resolver) PublishPatientQueueUpdates(patientQueueIDs ...int) {
+	for _, patientQueueID := range patientQueueIDs {
+		var patientQueue models.PatientQueue
+		if err := r.PatientQueueRepository.Get(&patientQueue, patientQueueID); err != nil {
+			continue
+		}
+
+		result, err := r.GetPatientQueueWithAppointment(&patientQueue)
+		if err != nil {
+			continue
+		}
+
+		r.PubSub.Publish(PatientQueueIDTopic(patientQueueID), result)
+	}
+}
+
+// PublishPatientQueueUpdatesByName is like PublishPatientQueueUpdates for queues
+// that are addressed by name
+func (r *Resolver) PublishPatientQueueUpdatesByName(queueNames ...string) {
+	for _, queueName := range queueNames {
+		var patientQueue models.PatientQueue
+		if err := r.PatientQueueRepository.GetByQueueName(&patientQueue, queueName); err != nil {
+			continue
+		}
+
+		r.PublishPatientQueueUpdates(patientQueue.ID)
+	}
+}
+
+// queueActorID returns the id of the signed in user, who is recorded as
+// having enqueued the patients a mutation adds to a queue
+func (r *Resolver) queueActorID(ctx context.Context) *int {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, gc.GetString("email")); err != nil {
+		return nil
+	}
+
+	return &user.ID
+}
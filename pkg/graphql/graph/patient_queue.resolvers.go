@@ -8,14 +8,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/tensoremr/server/pkg/graphql/graph/generated"
 	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
 	"github.com/tensoremr/server/pkg/middleware"
 	"github.com/tensoremr/server/pkg/models"
-	"gorm.io/datatypes"
 )
 
 func (r *mutationResolver) SubscribeQueue(ctx context.Context, userID int, patientQueueID int) (*models.QueueSubscription, error) {
@@ -43,19 +42,28 @@ func (r *mutationResolver) SavePatientQueue(ctx context.Context, input graph_mod
 	entity.QueueName = input.QueueName
 	entity.QueueType = input.QueueType
 
-	queue := datatypes.JSON([]byte("[" + strings.Join(input.Queue, ", ") + "]"))
-	entity.Queue = queue
+	var appointmentIDs []int
+	for _, v := range input.Queue {
+		appointmentID, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+
+		appointmentIDs = append(appointmentIDs, appointmentID)
+	}
 
 	if err := r.PatientQueueRepository.GetByQueueName(&entity, input.QueueName); err != nil {
 		if err := r.PatientQueueRepository.Save(&entity); err != nil {
 			return nil, err
 		}
-	} else {
-		if err := r.PatientQueueRepository.UpdateQueue(input.QueueName, queue); err != nil {
-			return nil, err
-		}
 	}
 
+	if err := r.PatientQueueRepository.UpdateQueue(input.QueueName, appointmentIDs, r.queueActorID(ctx)); err != nil {
+		return nil, err
+	}
+
+	r.PublishPatientQueueUpdates(entity.ID)
+
 	return &entity, nil
 }
 
@@ -66,6 +74,8 @@ func (r *mutationResolver) DeleteFromQueue(ctx context.Context, patientQueueID i
 		return nil, err
 	}
 
+	r.PublishPatientQueueUpdates(patientQueueID)
+
 	return &entity, nil
 }
 
@@ -85,7 +95,7 @@ func (r *mutationResolver) CheckOutPatient(ctx context.Context, patientQueueID i
 	appointment.AppointmentStatusID = status.ID
 	appointment.CheckedOutTime = time.Now()
 
-	if err := r.AppointmentRepository.Update(&appointment); err != nil {
+	if err := r.AppointmentRepository.WithContext(ctx).Update(&appointment); err != nil {
 		return nil, err
 	}
 
@@ -95,18 +105,23 @@ func (r *mutationResolver) CheckOutPatient(ctx context.Context, patientQueueID i
 		return nil, err
 	}
 
+	r.PublishPatientQueueUpdates(patientQueueID)
+
 	return &patientQueue, nil
 }
 
 func (r *mutationResolver) PushPatientQueue(ctx context.Context, patientQueueID int, appointmentID int, destination graph_models.Destination) (*models.PatientQueue, error) {
 	var entity models.PatientQueue
+	var destinationQueueName string
 
 	if destination.String() == "PREEXAM" {
-		if err := r.PatientQueueRepository.MoveToQueueName(patientQueueID, "Pre-Exam", appointmentID, ""); err != nil {
+		destinationQueueName = "Pre-Exam"
+		if err := r.PatientQueueRepository.MoveToQueueName(patientQueueID, destinationQueueName, appointmentID, "", r.queueActorID(ctx)); err != nil {
 			return nil, err
 		}
 	} else if destination.String() == "PREOPERATION" {
-		if err := r.PatientQueueRepository.MoveToQueueName(patientQueueID, "Pre-Operation", appointmentID, ""); err != nil {
+		destinationQueueName = "Pre-Operation"
+		if err := r.PatientQueueRepository.MoveToQueueName(patientQueueID, destinationQueueName, appointmentID, "", r.queueActorID(ctx)); err != nil {
 			return nil, err
 		}
 	} else if destination.String() == "PHYSICIAN" {
@@ -120,21 +135,27 @@ func (r *mutationResolver) PushPatientQueue(ctx context.Context, patientQueueID
 			return nil, err
 		}
 
-		if err := r.PatientQueueRepository.MoveToQueueName(patientQueueID, "Dr. "+provider.FirstName+" "+provider.LastName, appointmentID, "USER"); err != nil {
+		destinationQueueName = "Dr. " + provider.FirstName + " " + provider.LastName
+		if err := r.PatientQueueRepository.MoveToQueueName(patientQueueID, destinationQueueName, appointmentID, "USER", r.queueActorID(ctx)); err != nil {
 			return nil, err
 		}
 	}
 
+	r.PublishPatientQueueUpdates(patientQueueID)
+	r.PublishPatientQueueUpdatesByName(destinationQueueName)
+
 	return &entity, nil
 }
 
 func (r *mutationResolver) MovePatientQueue(ctx context.Context, appointmentID int, sourceQueueID int, destinationQueueID int) (*models.PatientQueue, error) {
 	var entity models.PatientQueue
 
-	if err := r.PatientQueueRepository.Move(&entity, sourceQueueID, destinationQueueID, appointmentID); err != nil {
+	if err := r.PatientQueueRepository.Move(&entity, sourceQueueID, destinationQueueID, appointmentID, r.queueActorID(ctx)); err != nil {
 		return nil, err
 	}
 
+	r.PublishPatientQueueUpdates(sourceQueueID, destinationQueueID)
+
 	return &entity, nil
 }
 
@@ -157,9 +178,7 @@ func (r *mutationResolver) CheckInPatient(ctx context.Context, appointmentID int
 	}
 
 	if visitType.Title == "Surgery" {
-		var postOpAppointent models.Appointment
-
-		if err := r.AppointmentRepository.SchedulePostOp(&postOpAppointent, appointment); err != nil {
+		if _, err := r.AppointmentRepository.SchedulePostOp(appointment); err != nil {
 			return nil, err
 		}
 	}
@@ -168,19 +187,23 @@ func (r *mutationResolver) CheckInPatient(ctx context.Context, appointmentID int
 	checkedInTime := time.Now()
 	appointment.CheckedInTime = &checkedInTime
 
-	if err := r.AppointmentRepository.Update(&appointment); err != nil {
+	if err := r.AppointmentRepository.WithContext(ctx).Update(&appointment); err != nil {
+		return nil, err
+	}
+
+	if err := r.AppointmentRepository.IssueTicket(&appointment); err != nil {
 		return nil, err
 	}
 
 	// Add to queue
 	var patientQueue models.PatientQueue
 	if destination.String() == "PREEXAM" {
-		if err := r.PatientQueueRepository.AddToQueue(&patientQueue, "Pre-Exam", appointmentID, "PREEXAM"); err != nil {
+		if err := r.PatientQueueRepository.AddToQueue(&patientQueue, "Pre-Exam", appointmentID, "PREEXAM", r.queueActorID(ctx)); err != nil {
 			return nil, err
 		}
 
 	} else if destination.String() == "PREOPERATION" {
-		if err := r.PatientQueueRepository.AddToQueue(&patientQueue, "Pre-Operation", appointmentID, "PREOPERATION"); err != nil {
+		if err := r.PatientQueueRepository.AddToQueue(&patientQueue, "Pre-Operation", appointmentID, "PREOPERATION", r.queueActorID(ctx)); err != nil {
 			return nil, err
 		}
 	} else if destination.String() == "PHYSICIAN" {
@@ -189,11 +212,15 @@ func (r *mutationResolver) CheckInPatient(ctx context.Context, appointmentID int
 			return nil, err
 		}
 
-		if err := r.PatientQueueRepository.AddToQueue(&patientQueue, "Dr. "+provider.FirstName+" "+provider.LastName, appointmentID, "USER"); err != nil {
+		if err := r.PatientQueueRepository.AddToQueue(&patientQueue, "Dr. "+provider.FirstName+" "+provider.LastName, appointmentID, "USER", r.queueActorID(ctx)); err != nil {
 			return nil, err
 		}
 	}
 
+	if patientQueue.ID != 0 {
+		r.PublishPatientQueueUpdates(patientQueue.ID)
+	}
+
 	return &appointment, nil
 }
 
@@ -201,8 +228,58 @@ func (r *mutationResolver) UpdatePatientQueue(ctx context.Context, appointmentID
 	panic(fmt.Errorf("not implemented"))
 }
 
+func (r *mutationResolver) CallNextPatient(ctx context.Context, patientQueueID int, roomID int) (*models.QueueEntry, error) {
+	var entity models.QueueEntry
+
+	if err := r.PatientQueueRepository.CallNext(&entity, patientQueueID, roomID, r.queueActorID(ctx)); err != nil {
+		return nil, err
+	}
+
+	r.PublishPatientQueueUpdates(entity.PatientQueueID)
+
+	return &entity, nil
+}
+
+func (r *mutationResolver) RecallPatient(ctx context.Context, queueEntryID int, roomID *int) (*models.QueueEntry, error) {
+	var entity models.QueueEntry
+
+	if err := r.PatientQueueRepository.Recall(&entity, queueEntryID, roomID, r.queueActorID(ctx)); err != nil {
+		return nil, err
+	}
+
+	r.PublishPatientQueueUpdates(entity.PatientQueueID)
+
+	return &entity, nil
+}
+
+func (r *mutationResolver) SkipPatient(ctx context.Context, queueEntryID int) (*models.QueueEntry, error) {
+	var entity models.QueueEntry
+
+	if err := r.PatientQueueRepository.Skip(&entity, queueEntryID, r.queueActorID(ctx)); err != nil {
+		return nil, err
+	}
+
+	r.PublishPatientQueueUpdates(entity.PatientQueueID)
+
+	return &entity, nil
+}
+
 func (r *patientQueueResolver) Queue(ctx context.Context, obj *models.PatientQueue) (string, error) {
-	return obj.Queue.String(), nil
+	ids, err := r.PatientQueueRepository.GetAppointmentIDs(obj.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if ids == nil {
+		ids = []int{}
+	}
+
+	queue, err := json.Marshal(ids)
+	if err != nil {
+		return "", err
+	}
+
+	return string(queue), nil
 }
 
 func (r *queryResolver) PatientQueues(ctx context.Context) ([]*graph_models.PatientQueueWithAppointment, error) {
@@ -259,32 +336,12 @@ func (r *queryResolver) PatientQueues(ctx context.Context) ([]*graph_models.Pati
 	var result []*graph_models.PatientQueueWithAppointment
 
 	for _, patientQueue := range patientQueues {
-		var ids []int
-
-		if err := json.Unmarshal([]byte(patientQueue.Queue.String()), &ids); err != nil {
+		patientQueueWithAppointment, err := r.GetPatientQueueWithAppointment(patientQueue)
+		if err != nil {
 			return nil, err
 		}
 
-		page := models.PaginationInput{Page: 0, Size: 1000}
-
-		appointments, _, _ := r.AppointmentRepository.GetByIds(ids, page)
-		var orderedAppointments []*models.Appointment
-
-		for _, id := range ids {
-			for _, appointment := range appointments {
-				if appointment.ID == id {
-					a := appointment
-					orderedAppointments = append(orderedAppointments, &a)
-				}
-			}
-		}
-
-		result = append(result, &graph_models.PatientQueueWithAppointment{
-			ID:        int(patientQueue.ID),
-			QueueName: patientQueue.QueueName,
-			QueueType: patientQueue.QueueType,
-			Queue:     orderedAppointments,
-		})
+		result = append(result, patientQueueWithAppointment)
 	}
 
 	return result, err
@@ -300,6 +357,84 @@ func (r *queryResolver) UserSubscriptions(ctx context.Context, userID int) (*mod
 	return &entity, nil
 }
 
+func (r *queryResolver) QueueMetrics(ctx context.Context, from time.Time, to time.Time) (*models.QueueMetrics, error) {
+	metrics, err := r.PatientQueueRepository.QueueMetrics(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+func (r *queryResolver) QueueEntries(ctx context.Context, patientQueueID int) ([]*models.QueueEntry, error) {
+	entries, err := r.PatientQueueRepository.GetEntries(patientQueueID)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (r *subscriptionResolver) PatientQueueUpdated(ctx context.Context, queueIds []int) (<-chan *graph_models.PatientQueueWithAppointment, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	// Without explicit queues, follow the queues the user subscribed to
+	if len(queueIds) == 0 {
+		var queueSubscription models.QueueSubscription
+		if err := r.QueueSubscriptionRepository.GetByUserId(&queueSubscription, user.ID); err != nil {
+			return nil, err
+		}
+
+		for _, patientQueue := range queueSubscription.Subscriptions {
+			queueIds = append(queueIds, patientQueue.ID)
+		}
+	}
+
+	if len(queueIds) == 0 {
+		return nil, errors.New("Subscribe to a patient queue to receive its updates")
+	}
+
+	var topics []string
+	for _, queueID := range queueIds {
+		topics = append(topics, PatientQueueIDTopic(queueID))
+	}
+
+	messages := r.PubSub.Subscribe(ctx, topics...)
+	patientQueues := make(chan *graph_models.PatientQueueWithAppointment, 1)
+
+	go func() {
+		defer close(patientQueues)
+
+		for message := range messages {
+			patientQueue, ok := message.(*graph_models.PatientQueueWithAppointment)
+			if !ok {
+				continue
+			}
+
+			select {
+			case patientQueues <- patientQueue:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return patientQueues, nil
+}
+
 // PatientQueue returns generated.PatientQueueResolver implementation.
 func (r *Resolver) PatientQueue() generated.PatientQueueResolver { return &patientQueueResolver{r} }
 
@@ -84,6 +84,17 @@ func (r *mutationResolver) ApprovePaymentWaiver(ctx context.Context, id int, app
 		return nil, err
 	}
 
+	message := "Payment waiver rejected"
+	if approve {
+		message = "Payment waiver approved"
+	}
+
+	r.PublishNotification(graph_models.Notification{
+		ID:      entity.ID,
+		Type:    graph_models.NotifTypePaymentWaiver,
+		Message: message,
+	}, entity.UserID)
+
 	return &entity, nil
 }
 
@@ -0,0 +1,41 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/models"
+	deepCopy "github.com/ulule/deepcopier"
+)
+
+func (r *mutationResolver) GrantPermission(ctx context.Context, input graph_models.PermissionInput) (*models.Permission, error) {
+	var entity models.Permission
+	deepCopy.Copy(&input).To(&entity)
+
+	if err := r.PermissionRepository.Grant(entity); err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+func (r *mutationResolver) RevokePermission(ctx context.Context, input graph_models.PermissionInput) (bool, error) {
+	var entity models.Permission
+	deepCopy.Copy(&input).To(&entity)
+
+	return r.PermissionRepository.Revoke(entity)
+}
+
+func (r *queryResolver) Permissions(ctx context.Context) ([]*models.Permission, error) {
+	var result []*models.Permission
+
+	for _, permission := range r.PermissionRepository.GetAll() {
+		p := permission
+		result = append(result, &p)
+	}
+
+	return result, nil
+}
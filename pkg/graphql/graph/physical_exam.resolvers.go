@@ -59,7 +59,7 @@ func (r *mutationResolver) SavePhysicalExamFinding(ctx context.Context, input gr
 	var entity models.PhysicalExamFinding
 	deepCopy.Copy(&input).To(&entity)
 
-	if err := r.PhysicalExamFindingRepository.Save(&entity); err != nil {
+	if err := r.PhysicalExamFindingRepository.WithContext(ctx).Save(&entity); err != nil {
 		return nil, err
 	}
 
@@ -74,7 +74,7 @@ func (r *mutationResolver) UpdatePhysicalExamFinding(ctx context.Context, input
 		entity.Abnormal = *input.Abnormal
 	}
 
-	if err := r.PhysicalExamFindingRepository.Update(&entity); err != nil {
+	if err := r.PhysicalExamFindingRepository.WithContext(ctx).Update(&entity); err != nil {
 		return nil, err
 	}
 
@@ -82,7 +82,7 @@ func (r *mutationResolver) UpdatePhysicalExamFinding(ctx context.Context, input
 }
 
 func (r *mutationResolver) DeletePhysicalExamFinding(ctx context.Context, id int) (bool, error) {
-	if err := r.PhysicalExamFindingRepository.Delete(id); err != nil {
+	if err := r.PhysicalExamFindingRepository.WithContext(ctx).Delete(id); err != nil {
 		return false, err
 	}
 
@@ -92,7 +92,7 @@ func (r *mutationResolver) DeletePhysicalExamFinding(ctx context.Context, id int
 func (r *mutationResolver) DeletePhysicalExamFindingExamCategory(ctx context.Context, physicalExamFindingID int, examCategoryID int) (*models.PhysicalExamFinding, error) {
 	var entity models.PhysicalExamFinding
 
-	if err := r.PhysicalExamFindingRepository.DeleteExamCategory(&entity, physicalExamFindingID, examCategoryID); err != nil {
+	if err := r.PhysicalExamFindingRepository.WithContext(ctx).DeleteExamCategory(&entity, physicalExamFindingID, examCategoryID); err != nil {
 		return nil, err
 	}
 
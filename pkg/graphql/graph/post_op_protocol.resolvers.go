@@ -0,0 +1,41 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+func (r *mutationResolver) SavePostOpProtocol(ctx context.Context, surgicalProcedureTypeID int, steps []*graph_models.PostOpProtocolStepInput) ([]*models.PostOpProtocolStep, error) {
+	var entities []models.PostOpProtocolStep
+
+	for _, step := range steps {
+		entities = append(entities, models.PostOpProtocolStep{
+			Delay:        step.Delay,
+			DelayUnit:    step.DelayUnit,
+			VisitTypeID:  step.VisitTypeID,
+			RoomID:       step.RoomID,
+			ProviderRule: step.ProviderRule,
+			UserID:       step.UserID,
+		})
+	}
+
+	if err := r.PostOpProtocolRepository.Replace(surgicalProcedureTypeID, entities); err != nil {
+		return nil, err
+	}
+
+	return r.PostOpProtocolRepository.GetBySurgicalProcedureType(surgicalProcedureTypeID)
+}
+
+func (r *queryResolver) PostOpProtocol(ctx context.Context, surgicalProcedureTypeID int) ([]*models.PostOpProtocolStep, error) {
+	steps, err := r.PostOpProtocolRepository.GetBySurgicalProcedureType(surgicalProcedureTypeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return steps, nil
+}
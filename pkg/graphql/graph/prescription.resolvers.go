@@ -68,7 +68,7 @@ func (r *mutationResolver) SaveMedicationPrescription(ctx context.Context, input
 		Status:              *input.Status,
 	}
 
-	if err := r.MedicalPrescriptionOrderRepository.SaveMedicalPrescription(&medicalPrescriptionOrder, medicalPrescription, input.PatientID); err != nil {
+	if err := r.MedicalPrescriptionOrderRepository.WithContext(ctx).SaveMedicalPrescription(&medicalPrescriptionOrder, medicalPrescription, input.PatientID); err != nil {
 		return nil, err
 	}
 
@@ -160,7 +160,7 @@ func (r *mutationResolver) SaveEyewearPrescription(ctx context.Context, input gr
 		Status:             *input.Status,
 	}
 
-	if err := r.EyewearPrescriptionOrderRepository.SaveEyewearPrescription(&eyewearPrescriptionOrder, eyewearPrescription, input.PatientID); err != nil {
+	if err := r.EyewearPrescriptionOrderRepository.WithContext(ctx).SaveEyewearPrescription(&eyewearPrescriptionOrder, eyewearPrescription, input.PatientID); err != nil {
 		return nil, err
 	}
 
@@ -211,7 +211,7 @@ func (r *mutationResolver) UpdateMedicationPrescriptionOrder(ctx context.Context
 	var entity models.MedicalPrescriptionOrder
 	deepCopy.Copy(&input).To(&entity)
 
-	if err := r.MedicalPrescriptionOrderRepository.Update(&entity); err != nil {
+	if err := r.MedicalPrescriptionOrderRepository.WithContext(ctx).Update(&entity); err != nil {
 		return nil, err
 	}
 
@@ -222,7 +222,7 @@ func (r *mutationResolver) UpdateEyewearPrescriptionOrder(ctx context.Context, i
 	var entity models.EyewearPrescriptionOrder
 	deepCopy.Copy(&input).To(&entity)
 
-	if err := r.EyewearPrescriptionOrderRepository.Update(&entity); err != nil {
+	if err := r.EyewearPrescriptionOrderRepository.WithContext(ctx).Update(&entity); err != nil {
 		return nil, err
 	}
 
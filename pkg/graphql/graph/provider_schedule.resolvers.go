@@ -0,0 +1,153 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"time"
+
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+func (r *mutationResolver) SaveProviderSchedule(ctx context.Context, input graph_models.ProviderScheduleInput) (*models.ProviderSchedule, error) {
+	entity := models.ProviderSchedule{
+		UserID:    input.UserID,
+		Weekday:   input.Weekday,
+		StartTime: input.StartTime,
+		EndTime:   input.EndTime,
+		RoomID:    input.RoomID,
+	}
+
+	for _, b := range input.Breaks {
+		entity.Breaks = append(entity.Breaks, models.ProviderScheduleBreak{StartTime: b.StartTime, EndTime: b.EndTime})
+	}
+
+	if err := r.ProviderScheduleRepository.Save(&entity); err != nil {
+		return nil, err
+	}
+
+	if err := r.ProviderScheduleRepository.Get(&entity, entity.ID); err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+func (r *mutationResolver) UpdateProviderSchedule(ctx context.Context, input graph_models.ProviderScheduleUpdateInput) (*models.ProviderSchedule, error) {
+	var entity models.ProviderSchedule
+	if err := r.ProviderScheduleRepository.Get(&entity, input.ID); err != nil {
+		return nil, err
+	}
+
+	if input.Weekday != nil {
+		entity.Weekday = *input.Weekday
+	}
+
+	if input.StartTime != nil {
+		entity.StartTime = *input.StartTime
+	}
+
+	if input.EndTime != nil {
+		entity.EndTime = *input.EndTime
+	}
+
+	if input.RoomID != nil {
+		entity.RoomID = input.RoomID
+	}
+
+	if input.Breaks != nil {
+		entity.Breaks = []models.ProviderScheduleBreak{}
+		for _, b := range input.Breaks {
+			entity.Breaks = append(entity.Breaks, models.ProviderScheduleBreak{StartTime: b.StartTime, EndTime: b.EndTime})
+		}
+	}
+
+	if err := r.ProviderScheduleRepository.Update(&entity); err != nil {
+		return nil, err
+	}
+
+	if err := r.ProviderScheduleRepository.Get(&entity, entity.ID); err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+func (r *mutationResolver) DeleteProviderSchedule(ctx context.Context, id int) (bool, error) {
+	if err := r.ProviderScheduleRepository.Delete(id); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *mutationResolver) SaveScheduleException(ctx context.Context, input graph_models.ScheduleExceptionInput) (*models.ScheduleException, error) {
+	entity := models.ScheduleException{
+		UserID:    input.UserID,
+		Type:      input.Type,
+		StartTime: input.StartTime,
+		EndTime:   input.EndTime,
+	}
+
+	if input.Reason != nil {
+		entity.Reason = *input.Reason
+	}
+
+	if err := r.ScheduleExceptionRepository.Save(&entity); err != nil {
+		return nil, err
+	}
+
+	if err := r.ScheduleExceptionRepository.Get(&entity, entity.ID); err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+func (r *mutationResolver) DeleteScheduleException(ctx context.Context, id int) (bool, error) {
+	if err := r.ScheduleExceptionRepository.Delete(id); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *queryResolver) ProviderSchedules(ctx context.Context, userID int) ([]*models.ProviderSchedule, error) {
+	schedules, err := r.ProviderScheduleRepository.GetByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (r *queryResolver) ScheduleExceptions(ctx context.Context, page models.PaginationInput, userID *int) (*graph_models.ScheduleExceptionConnection, error) {
+	entities, count, err := r.ScheduleExceptionRepository.GetAll(page, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := make([]*graph_models.ScheduleExceptionEdge, len(entities))
+
+	for i, entity := range entities {
+		e := entity
+
+		edges[i] = &graph_models.ScheduleExceptionEdge{
+			Node: &e,
+		}
+	}
+
+	pageInfo, totalCount := GetPageInfo(entities, count, page)
+	return &graph_models.ScheduleExceptionConnection{PageInfo: pageInfo, Edges: edges, TotalCount: totalCount}, nil
+}
+
+func (r *queryResolver) FindAvailableSlots(ctx context.Context, userID int, visitTypeID int, from time.Time, to time.Time, roomID *int) ([]*models.AvailableSlot, error) {
+	slots, err := r.ProviderScheduleRepository.FindAvailableSlots(userID, visitTypeID, from, to, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	return slots, nil
+}
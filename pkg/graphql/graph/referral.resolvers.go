@@ -33,17 +33,23 @@ func (r *mutationResolver) OrderReferral(ctx context.Context, input graph_models
 	}
 
 	var referral models.ReferralOrder
-	if err := r.ReferralOrderRepository.Save(&referral, input.PatientChartID, input.PatientID, input.ReferredToID, input.Type, user, input.ReceptionNote, input.Reason, input.ProviderName); err != nil {
+	if err := r.ReferralOrderRepository.WithContext(ctx).Save(&referral, input.PatientChartID, input.PatientID, input.ReferredToID, input.Type, user, input.ReceptionNote, input.Reason, input.ProviderName); err != nil {
 		return nil, err
 	}
 
+	r.PublishNotification(graph_models.Notification{
+		ID:      referral.ID,
+		Type:    graph_models.NotifTypeReferralOrder,
+		Message: "New referral order",
+	})
+
 	return &referral, nil
 }
 
 func (r *mutationResolver) ConfirmReferralOrder(ctx context.Context, input graph_models.ConfirmReferralOrderInput) (*graph_models.ConfirmReferralOrderResult, error) {
 	var entity models.ReferralOrder
 
-	if err := r.ReferralOrderRepository.ConfirmOrder(&entity, input.ReferralOrderID, input.ReferralID, input.BillingID, input.InvoiceNo, input.RoomID, input.CheckInTime); err != nil {
+	if err := r.ReferralOrderRepository.WithContext(ctx).ConfirmOrder(&entity, input.ReferralOrderID, input.ReferralID, input.BillingID, input.InvoiceNo, input.RoomID, input.CheckInTime); err != nil {
 		return nil, err
 	}
 
@@ -56,7 +62,7 @@ func (r *mutationResolver) ConfirmReferralOrder(ctx context.Context, input graph
 }
 
 func (r *mutationResolver) DeleteReferral(ctx context.Context, id int) (bool, error) {
-	if err := r.ReferralOrderRepository.Delete(id); err != nil {
+	if err := r.ReferralOrderRepository.WithContext(ctx).Delete(id); err != nil {
 		return false, err
 	}
 
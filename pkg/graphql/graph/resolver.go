@@ -25,6 +25,7 @@ package graph
 import (
 	"crypto/sha1"
 	"encoding/base64"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -33,6 +34,9 @@ import (
 
 	"github.com/casbin/casbin/v2"
 	"github.com/tensoremr/server/pkg/conf"
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/mailer"
+	"github.com/tensoremr/server/pkg/pubsub"
 	"github.com/tensoremr/server/pkg/repository"
 )
 
@@ -44,13 +48,20 @@ import (
 type Resolver struct {
 	Config                             *conf.Configuration
 	AccessControl                      *casbin.Enforcer
+	PubSub                             *pubsub.Broker
+	Mailer                             mailer.Mailer
 	AllergyRepository                  repository.AllergyRepository
 	AmendmentRepository                repository.AmendmentRepository
 	AppointmentQueueRepository         repository.AppointmentQueueRepository
+	AppointmentSeriesRepository        repository.AppointmentSeriesRepository
 	AppointmentStatusRepository        repository.AppointmentStatusRepository
 	AppointmentRepository              repository.AppointmentRepository
+	AuditLogRepository                 repository.AuditLogRepository
 	AutoRefractionRepository           repository.AutoRefractionRepository
 	BillingRepository                  repository.BillingRepository
+	ChartRevisionRepository            repository.ChartRevisionRepository
+	NoteTemplateRepository             repository.NoteTemplateRepository
+	ChartDocumentRepository            repository.ChartDocumentRepository
 	ChatDeleteRepository               repository.ChatDeleteRepository
 	ChatMemberRepository               repository.ChatMemberRepository
 	ChatMessageRepository              repository.ChatMessageRepository
@@ -102,19 +113,25 @@ type Resolver struct {
 	PatientDiagnosisRepository         repository.PatientDiagnosisRepository
 	PatientEncounterLimitRepository    repository.PatientEncounterLimitRepository
 	PatientHistoryRepository           repository.PatientHistoryRepository
+	PatientMergeRepository             repository.PatientMergeRepository
 	PatientQueueRepository             repository.PatientQueueRepository
 	PatientRepository                  repository.PatientRepository
 	PaymentWaiverRepository            repository.PaymentWaiverRepository
 	PaymentRepository                  repository.PaymentRepository
+	PermissionRepository               repository.PermissionRepository
 	PharmacyRepository                 repository.PharmacyRepository
 	PhysicalExamFindingRepository      repository.PhysicalExamFindingRepository
+	PostOpProtocolRepository           repository.PostOpProtocolRepository
+	ProviderScheduleRepository         repository.ProviderScheduleRepository
 	PupilsRepository                   repository.PupilsRepository
 	QueueDestinationRepository         repository.QueueDestinationRepository
 	QueueSubscriptionRepository        repository.QueueSubscriptionRepository
 	ReferralOrderRepository            repository.ReferralOrderRepository
+	RefreshTokenRepository             repository.RefreshTokenRepository
 	ReferralRepository                 repository.ReferralRepository
 	ReviewOfSystemRepository           repository.ReviewOfSystemRepository
 	RoomRepository                     repository.RoomRepository
+	ScheduleExceptionRepository        repository.ScheduleExceptionRepository
 	SlitLampExamRepository             repository.SlitLampExamRepository
 	SupplyRepository                   repository.SupplyRepository
 	SurgicalOrderRepository            repository.SurgicalOrderRepository
@@ -130,6 +147,28 @@ type Resolver struct {
 	VisitTypeRepository                repository.VisitTypeRepository
 	VisualAcuityRepository             repository.VisualAcuityRepository
 	VitalSignsRepository               repository.VitalSignsRepository
+	WaitlistRepository                 repository.WaitlistRepository
+}
+
+// notificationTopic is the topic for notifications sent to every user
+const notificationTopic = "notification"
+
+// userNotificationTopic is the topic for notifications sent to a single user
+func userNotificationTopic(userID int) string {
+	return fmt.Sprintf("notification.user.%d", userID)
+}
+
+// PublishNotification sends a notification to the given users, or to every
+// subscribed user when no user is given
+func (r *Resolver) PublishNotification(notification graph_models.Notification, userIDs ...int) {
+	if len(userIDs) == 0 {
+		r.PubSub.Publish(notificationTopic, &notification)
+		return
+	}
+
+	for _, userID := range userIDs {
+		r.PubSub.Publish(userNotificationTopic(userID), &notification)
+	}
 }
 
 // WriteFile ...
@@ -5,30 +5,13 @@ package graph
 
 import (
 	"context"
-	"errors"
 
 	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
-	"github.com/tensoremr/server/pkg/middleware"
 	"github.com/tensoremr/server/pkg/models"
 	deepCopy "github.com/ulule/deepcopier"
 )
 
 func (r *mutationResolver) SaveRoom(ctx context.Context, input graph_models.RoomInput) (*models.Room, error) {
-	gc, err := middleware.GinContextFromContext(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	email := gc.GetString("email")
-	if len(email) == 0 {
-		return nil, errors.New("Cannot find user")
-	}
-
-	ok, err := r.AccessControl.Enforce(email, "rooms", "write")
-	if !ok {
-		return nil, errors.New("You are not authorized to perform this action")
-	}
-
 	var room models.Room
 	deepCopy.Copy(&input).To(&room)
 
@@ -40,21 +23,6 @@ func (r *mutationResolver) SaveRoom(ctx context.Context, input graph_models.Room
 }
 
 func (r *mutationResolver) UpdateRoom(ctx context.Context, input graph_models.RoomInput, id int) (*models.Room, error) {
-	gc, err := middleware.GinContextFromContext(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	email := gc.GetString("email")
-	if len(email) == 0 {
-		return nil, errors.New("Cannot find user")
-	}
-
-	ok, err := r.AccessControl.Enforce(email, "rooms", "write")
-	if !ok {
-		return nil, errors.New("You are not authorized to perform this action")
-	}
-
 	var room models.Room
 	deepCopy.Copy(&input).To(&room)
 
@@ -66,21 +34,6 @@ func (r *mutationResolver) UpdateRoom(ctx context.Context, input graph_models.Ro
 }
 
 func (r *mutationResolver) DeleteRoom(ctx context.Context, id int) (bool, error) {
-	gc, err := middleware.GinContextFromContext(ctx)
-	if err != nil {
-		return false, err
-	}
-
-	email := gc.GetString("email")
-	if len(email) == 0 {
-		return false, errors.New("Cannot find user")
-	}
-
-	ok, err := r.AccessControl.Enforce(email, "rooms", "write")
-	if !ok {
-		return false, errors.New("You are not authorized to perform this action")
-	}
-
 	if err := r.RoomRepository.Delete(id); err != nil {
 		return false, err
 	}
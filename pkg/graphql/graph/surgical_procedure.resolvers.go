@@ -43,17 +43,23 @@ func (r *mutationResolver) OrderSurgicalProcedure(ctx context.Context, input gra
 	}
 
 	var surgicalProcedure models.SurgicalOrder
-	if err := r.SurgicalOrderRepository.SaveOpthalmologyOrder(&surgicalProcedure, input.SurgicalProcedureTypeID, input.PatientChartID, input.PatientID, input.BillingID, user, input.PerformOnEye, input.OrderNote, input.ReceptionNote); err != nil {
+	if err := r.SurgicalOrderRepository.WithContext(ctx).SaveOpthalmologyOrder(&surgicalProcedure, input.SurgicalProcedureTypeID, input.PatientChartID, input.PatientID, input.BillingID, user, input.PerformOnEye, input.OrderNote, input.ReceptionNote); err != nil {
 		return nil, err
 	}
 
+	r.PublishNotification(graph_models.Notification{
+		ID:      surgicalProcedure.ID,
+		Type:    graph_models.NotifTypeSurgicalOrder,
+		Message: "New surgical order",
+	})
+
 	return &surgicalProcedure, nil
 }
 
 func (r *mutationResolver) ConfirmSurgicalOrder(ctx context.Context, input graph_models.ConfirmSurgicalOrderInput) (*graph_models.ConfirmSurgicalOrderResult, error) {
 	var entity models.SurgicalOrder
 
-	if err := r.SurgicalOrderRepository.ConfirmOrder(&entity, input.SurgicalOrderID, input.SurgicalProcedureID, *input.InvoiceNo, input.RoomID, input.CheckInTime); err != nil {
+	if err := r.SurgicalOrderRepository.WithContext(ctx).ConfirmOrder(&entity, input.SurgicalOrderID, input.SurgicalProcedureID, *input.InvoiceNo, input.RoomID, input.CheckInTime); err != nil {
 		return nil, err
 	}
 
@@ -255,7 +261,7 @@ func (r *mutationResolver) OrderAndConfirmSurgery(ctx context.Context, input gra
 
 	appointment.AppointmentStatusID = status.ID
 
-	if err := r.AppointmentRepository.CreateNewAppointment(&appointment, &input.BillingID, &input.InvoiceNo); err != nil {
+	if err := r.AppointmentRepository.WithContext(ctx).CreateNewAppointment(&appointment, &input.BillingID, &input.InvoiceNo, false); err != nil {
 		return nil, err
 	}
 
@@ -265,7 +271,7 @@ func (r *mutationResolver) OrderAndConfirmSurgery(ctx context.Context, input gra
 	}
 
 	var surgicalOrder models.SurgicalOrder
-	if err := r.SurgicalOrderRepository.SaveOpthalmologyOrder(&surgicalOrder, input.SurgicalProcedureTypeID, patientChart.ID, appointment.PatientID, input.BillingID, user, input.PerformOnEye, input.OrderNote, ""); err != nil {
+	if err := r.SurgicalOrderRepository.WithContext(ctx).SaveOpthalmologyOrder(&surgicalOrder, input.SurgicalProcedureTypeID, patientChart.ID, appointment.PatientID, input.BillingID, user, input.PerformOnEye, input.OrderNote, ""); err != nil {
 		return nil, err
 	}
 
@@ -275,6 +281,12 @@ func (r *mutationResolver) OrderAndConfirmSurgery(ctx context.Context, input gra
 	// 	return nil, err
 	// }
 
+	r.PublishNotification(graph_models.Notification{
+		ID:      surgicalOrder.ID,
+		Type:    graph_models.NotifTypeSurgicalOrder,
+		Message: "New surgical order",
+	})
+
 	return &surgicalOrder, nil
 }
 
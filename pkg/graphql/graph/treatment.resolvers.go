@@ -43,17 +43,23 @@ func (r *mutationResolver) OrderTreatment(ctx context.Context, input graph_model
 	}
 
 	var treatment models.TreatmentOrder
-	if err := r.TreatmentOrderRepository.SaveOpthalmologyTreatment(&treatment, input.TreatmentTypeID, input.PatientChartID, input.PatientID, input.BillingID, user, input.TreatmentNote, input.OrderNote); err != nil {
+	if err := r.TreatmentOrderRepository.WithContext(ctx).SaveOpthalmologyTreatment(&treatment, input.TreatmentTypeID, input.PatientChartID, input.PatientID, input.BillingID, user, input.TreatmentNote, input.OrderNote); err != nil {
 		return nil, err
 	}
 
+	r.PublishNotification(graph_models.Notification{
+		ID:      treatment.ID,
+		Type:    graph_models.NotifTypeTreatmentOrder,
+		Message: "New treatment order",
+	})
+
 	return &treatment, nil
 }
 
 func (r *mutationResolver) ConfirmTreatmentOrder(ctx context.Context, input graph_models.ConfirmTreatmentOrderInput) (*graph_models.ConfirmTreatmentOrderResult, error) {
 	var entity models.TreatmentOrder
 
-	if err := r.TreatmentOrderRepository.ConfirmOrder(&entity, input.TreatmentOrderID, input.TreatmentID, *input.InvoiceNo, input.RoomID, input.CheckInTime); err != nil {
+	if err := r.TreatmentOrderRepository.WithContext(ctx).ConfirmOrder(&entity, input.TreatmentOrderID, input.TreatmentID, *input.InvoiceNo, input.RoomID, input.CheckInTime); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,46 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package graph
+
+import (
+	"errors"
+	"log"
+
+	"github.com/tensoremr/server/pkg/auth"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+// verifySecondFactor checks a code the signed in user entered to change their
+// two-factor settings. Wrong codes count towards the account lockout, the same
+// as at sign in, so that a stolen session can't be used to guess codes
+func (r *Resolver) verifySecondFactor(user *models.User, code string) error {
+	if user.IsLocked() {
+		return errors.New("Your account is locked")
+	}
+
+	if !auth.VerifySecondFactor(user, code) {
+		user.RecordFailedAttempt()
+		if err := r.UserRepository.UpdateLoginAttempts(user); err != nil {
+			log.Println(err)
+		}
+
+		return errors.New("Invalid two-factor code")
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/middleware"
+	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/totp"
+)
+
+func (r *mutationResolver) EnrollTotp(ctx context.Context) (*graph_models.TotpEnrollment, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		return nil, errors.New("Two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPSecretKey = secret
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = ""
+
+	if err := r.UserRepository.UpdateTwoFactor(&user); err != nil {
+		return nil, err
+	}
+
+	issuer := "Tensor EMR"
+
+	var organizationDetails models.OrganizationDetails
+	if err := r.OrganizationDetailsRepository.Get(&organizationDetails); err == nil && organizationDetails.Name != nil && len(*organizationDetails.Name) > 0 {
+		issuer = *organizationDetails.Name
+	}
+
+	return &graph_models.TotpEnrollment{
+		Secret:     secret,
+		OtpauthURI: totp.URI(issuer, user.Email, secret),
+	}, nil
+}
+
+func (r *mutationResolver) ConfirmTotp(ctx context.Context, code string) ([]string, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		return nil, errors.New("Two-factor authentication is already enabled")
+	}
+
+	if len(user.TOTPSecretKey) == 0 {
+		return nil, errors.New("Two-factor authentication enrollment has not been started")
+	}
+
+	step, ok := totp.Validate(user.TOTPSecretKey, code, time.Now())
+	if !ok {
+		return nil, errors.New("Invalid two-factor code")
+	}
+
+	codes, hashes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	user.RecoveryCodes = strings.Join(hashes, ",")
+
+	if err := r.UserRepository.UpdateTwoFactor(&user); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (r *mutationResolver) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return nil, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return nil, err
+	}
+
+	if !user.TOTPEnabled {
+		return nil, errors.New("Two-factor authentication is not enabled")
+	}
+
+	if err := r.verifySecondFactor(&user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	user.RecoveryCodes = strings.Join(hashes, ",")
+
+	if err := r.UserRepository.UpdateTwoFactor(&user); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (r *mutationResolver) DisableTotp(ctx context.Context, code string) (bool, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return false, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return false, err
+	}
+
+	if !user.TOTPEnabled {
+		return false, errors.New("Two-factor authentication is not enabled")
+	}
+
+	if err := r.verifySecondFactor(&user, code); err != nil {
+		return false, err
+	}
+
+	user.TOTPSecretKey = ""
+	user.TOTPEnabled = false
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = ""
+
+	if err := r.UserRepository.UpdateTwoFactor(&user); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *mutationResolver) ResetUserTotp(ctx context.Context, userID int) (bool, error) {
+	var user models.User
+	if err := r.UserRepository.Get(&user, userID); err != nil {
+		return false, err
+	}
+
+	user.TOTPSecretKey = ""
+	user.TOTPEnabled = false
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = ""
+
+	if err := r.UserRepository.UpdateTwoFactor(&user); err != nil {
+		return false, err
+	}
+
+	// Sessions may have been opened with the lost device
+	if err := r.RefreshTokenRepository.RevokeAllForUser(user.ID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -8,8 +8,8 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/tensoremr/server/pkg/auth"
 	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
-	"github.com/tensoremr/server/pkg/jwt"
 	"github.com/tensoremr/server/pkg/middleware"
 	"github.com/tensoremr/server/pkg/models"
 	deepCopy "github.com/ulule/deepcopier"
@@ -66,36 +66,64 @@ func (r *mutationResolver) Signup(ctx context.Context, input graph_models.UserIn
 		return nil, err
 	}
 
+	if err := r.PermissionRepository.SyncUserRoles(entity.Email, userTypes); err != nil {
+		return nil, err
+	}
+
+	if err := auth.SendConfirmation(&r.UserRepository, r.Mailer, &entity); err != nil {
+		return nil, err
+	}
+
 	return &entity, nil
 }
 
-func (r *mutationResolver) Login(ctx context.Context, input graph_models.LoginInput) (string, error) {
+func (r *mutationResolver) Login(ctx context.Context, input graph_models.LoginInput) (*graph_models.LoginResponse, error) {
 	var user models.User
 
 	// Check if user exists
 	if err := r.UserRepository.GetByEmail(&user, input.Email); err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if user.IsLocked() {
+		return nil, errors.New("Your account is locked")
 	}
 
 	// Check password validity
 	pErr := user.CheckPassword(user.Password, input.Password)
 	if pErr != nil {
-		return "", pErr
+		user.RecordFailedAttempt()
+		if err := r.UserRepository.UpdateLoginAttempts(&user); err != nil {
+			return nil, err
+		}
+
+		return nil, pErr
+	}
+
+	if !user.Active {
+		return nil, errors.New("Your account is inactive")
 	}
 
-	// Generate JWT Token
-	jwtWrapper := jwt.Wrapper{
-		SecretKey:       r.Config.JwtSecret,
-		Issuer:          r.Config.JwtIssuer,
-		ExpirationHours: 24,
+	// This mutation cannot return a two-factor challenge
+	if user.TOTPEnabled {
+		return nil, errors.New("Two-factor authentication is enabled, sign in through /login")
 	}
 
-	token, err := jwtWrapper.GenerateToken(user)
+	user.ResetAttempts()
+	if err := r.UserRepository.UpdateLoginAttempts(&user); err != nil {
+		return nil, err
+	}
+
+	tokens, err := auth.NewSession(&r.RefreshTokenRepository, user)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return token, nil
+	return &graph_models.LoginResponse{
+		Token:        tokens.Token,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int(tokens.ExpiresIn),
+	}, nil
 }
 
 func (r *mutationResolver) ResetPassword(ctx context.Context, id int) (*models.User, error) {
@@ -114,10 +142,19 @@ func (r *mutationResolver) ResetPassword(ctx context.Context, id int) (*models.U
 		return nil, err
 	}
 
+	if err := r.RefreshTokenRepository.RevokeAllForUser(entity.ID); err != nil {
+		return nil, err
+	}
+
 	return &entity, nil
 }
 
 func (r *mutationResolver) UpdateUser(ctx context.Context, input graph_models.UserUpdateInput) (*models.User, error) {
+	var existing models.User
+	if err := r.UserRepository.Get(&existing, input.ID); err != nil {
+		return nil, err
+	}
+
 	var entity models.User
 	deepCopy.Copy(&input).To(&entity)
 
@@ -162,9 +199,58 @@ func (r *mutationResolver) UpdateUser(ctx context.Context, input graph_models.Us
 		return nil, err
 	}
 
+	if existing.Email != entity.Email {
+		if err := r.PermissionRepository.RemoveUserRoles(existing.Email); err != nil {
+			return nil, err
+		}
+
+		// The new address has to be confirmed again
+		if err := auth.SendConfirmation(&r.UserRepository, r.Mailer, &entity); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.PermissionRepository.SyncUserRoles(entity.Email, userTypes); err != nil {
+		return nil, err
+	}
+
+	// Sign deactivated users out of every session
+	if existing.Active && !entity.Active {
+		if err := r.RefreshTokenRepository.RevokeAllForUser(existing.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	return &entity, nil
 }
 
+func (r *mutationResolver) ResendConfirmationEmail(ctx context.Context) (bool, error) {
+	gc, err := middleware.GinContextFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	email := gc.GetString("email")
+	if len(email) == 0 {
+		return false, errors.New("Cannot find user")
+	}
+
+	var user models.User
+	if err := r.UserRepository.GetByEmail(&user, email); err != nil {
+		return false, err
+	}
+
+	if user.Confirmed {
+		return false, errors.New("Your email address is already confirmed")
+	}
+
+	if err := auth.SendConfirmation(&r.UserRepository, r.Mailer, &user); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (r *mutationResolver) ChangePassword(ctx context.Context, input graph_models.ChangePasswordInput) (*models.User, error) {
 	gc, err := middleware.GinContextFromContext(ctx)
 	if err != nil {
@@ -215,6 +301,11 @@ func (r *mutationResolver) SaveUserType(ctx context.Context, input graph_models.
 }
 
 func (r *mutationResolver) UpdateUserType(ctx context.Context, input graph_models.UserTypeUpdateInput) (*models.UserType, error) {
+	var existing models.UserType
+	if err := r.UserTypeRepository.Get(&existing, input.ID); err != nil {
+		return nil, err
+	}
+
 	var userType models.UserType
 	deepCopy.Copy(&input).To(&userType)
 
@@ -222,6 +313,17 @@ func (r *mutationResolver) UpdateUserType(ctx context.Context, input graph_model
 		return nil, err
 	}
 
+	// User types are casbin roles, so carry their permissions over to the new title
+	if existing.Title != userType.Title {
+		if err := r.PermissionRepository.RenameRole(existing.Title, userType.Title); err != nil {
+			return nil, err
+		}
+
+		if err := r.PermissionRepository.SyncAllUserRoles(); err != nil {
+			return nil, err
+		}
+	}
+
 	return &userType, nil
 }
 
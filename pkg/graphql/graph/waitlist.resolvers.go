@@ -0,0 +1,115 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+
+	"github.com/tensoremr/server/pkg/graphql/graph/generated"
+	graph_models "github.com/tensoremr/server/pkg/graphql/graph/model"
+	"github.com/tensoremr/server/pkg/models"
+)
+
+func (r *mutationResolver) SaveWaitlistEntry(ctx context.Context, input graph_models.WaitlistEntryInput) (*models.WaitlistEntry, error) {
+	entity := models.WaitlistEntry{
+		PatientID:     input.PatientID,
+		UserID:        input.UserID,
+		VisitTypeID:   input.VisitTypeID,
+		PreferredFrom: input.PreferredFrom,
+		PreferredTo:   input.PreferredTo,
+	}
+
+	if input.Priority != nil {
+		entity.Priority = *input.Priority
+	}
+
+	if input.Note != nil {
+		entity.Note = *input.Note
+	}
+
+	if err := r.WaitlistRepository.WithContext(ctx).SaveEntry(&entity); err != nil {
+		return nil, err
+	}
+
+	if err := r.WaitlistRepository.GetEntry(&entity, entity.ID); err != nil {
+		return nil, err
+	}
+
+	return &entity, nil
+}
+
+func (r *mutationResolver) RemoveWaitlistEntry(ctx context.Context, id int) (bool, error) {
+	if err := r.WaitlistRepository.WithContext(ctx).RemoveEntry(id); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *mutationResolver) ConvertWaitlistEntry(ctx context.Context, waitlistEntryID int, waitlistOfferID int) (*models.Appointment, error) {
+	appointment, err := r.WaitlistRepository.WithContext(ctx).Convert(waitlistEntryID, waitlistOfferID)
+	if err != nil {
+		return nil, err
+	}
+
+	return appointment, nil
+}
+
+func (r *queryResolver) WaitlistEntries(ctx context.Context, page models.PaginationInput, filter *graph_models.WaitlistEntryFilter) (*graph_models.WaitlistEntryConnection, error) {
+	var f models.WaitlistEntry
+
+	if filter != nil {
+		if filter.PatientID != nil {
+			f.PatientID = *filter.PatientID
+		}
+
+		f.UserID = filter.UserID
+		f.VisitTypeID = filter.VisitTypeID
+
+		if filter.Status != nil {
+			f.Status = *filter.Status
+		}
+	}
+
+	entities, count, err := r.WaitlistRepository.GetEntries(page, &f)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := make([]*graph_models.WaitlistEntryEdge, len(entities))
+
+	for i, entity := range entities {
+		e := entity
+
+		edges[i] = &graph_models.WaitlistEntryEdge{
+			Node: &e,
+		}
+	}
+
+	pageInfo, totalCount := GetPageInfo(entities, count, page)
+	return &graph_models.WaitlistEntryConnection{PageInfo: pageInfo, Edges: edges, TotalCount: totalCount}, nil
+}
+
+func (r *queryResolver) WaitlistOffers(ctx context.Context, userID *int) ([]*models.WaitlistOffer, error) {
+	offers, err := r.WaitlistRepository.GetOpenOffers(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return offers, nil
+}
+
+func (r *waitlistOfferResolver) Candidates(ctx context.Context, obj *models.WaitlistOffer) ([]*models.WaitlistEntry, error) {
+	candidates, err := r.WaitlistRepository.GetCandidates(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// WaitlistOffer returns generated.WaitlistOfferResolver implementation.
+func (r *Resolver) WaitlistOffer() generated.WaitlistOfferResolver { return &waitlistOfferResolver{r} }
+
+type waitlistOfferResolver struct{ *Resolver }
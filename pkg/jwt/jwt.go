@@ -19,6 +19,10 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -28,22 +32,23 @@ import (
 
 // Wrapper wraps the signing key and the issuer
 type Wrapper struct {
-	SecretKey       string
-	Issuer          string
-	ExpirationHours int64
+	SecretKey         string
+	Issuer            string
+	ExpirationMinutes int64
 }
 
 // Claim adds email as a claim to the token
 type Claim struct {
-	ID       int
-	Email    string
-	Name     string
-	UserType []string
+	ID        int
+	Email     string
+	Name      string
+	UserType  []string
+	SessionID string
 	jwt.StandardClaims
 }
 
-// GenerateToken generates a jwt token
-func (j *Wrapper) GenerateToken(user models.User) (signedToken string, err error) {
+// GenerateToken generates a jwt token for a session of the user
+func (j *Wrapper) GenerateToken(user models.User, sessionID string) (signedToken string, err error) {
 	var userTypes []string
 
 	for _, e := range user.UserTypes {
@@ -51,12 +56,13 @@ func (j *Wrapper) GenerateToken(user models.User) (signedToken string, err error
 	}
 
 	claims := &Claim{
-		ID:       user.ID,
-		Email:    user.Email,
-		Name:     user.FirstName + " " + user.LastName,
-		UserType: userTypes,
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.FirstName + " " + user.LastName,
+		UserType:  userTypes,
+		SessionID: sessionID,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(j.ExpirationHours)).Unix(),
+			ExpiresAt: time.Now().Local().Add(time.Minute * time.Duration(j.ExpirationMinutes)).Unix(),
 			Issuer:    j.Issuer,
 		},
 	}
@@ -72,7 +78,7 @@ func (j *Wrapper) GenerateToken(user models.User) (signedToken string, err error
 }
 
 // ValidateToken validates the jwt token
-//ValidateToken validates the jwt token
+// ValidateToken validates the jwt token
 func (j *Wrapper) ValidateToken(signedToken string) (claims *Claim, err error) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
@@ -100,3 +106,57 @@ func (j *Wrapper) ValidateToken(signedToken string) (claims *Claim, err error) {
 	return
 
 }
+
+// GenerateRefreshToken returns a random opaque refresh token and the hash
+// it is stored under
+func GenerateRefreshToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	hash = HashRefreshToken(token)
+
+	return
+}
+
+// HashRefreshToken hashes a refresh token for storage, so that leaked
+// database rows cannot be used as tokens
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateSessionID returns a random identifier for a login session
+func GenerateSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// TwoFactorSubject is the subject of tokens that only prove the password
+// step of a two-factor sign in
+const TwoFactorSubject = "2fa"
+
+// GenerateTwoFactorToken generates a token that can only be exchanged for a
+// session by completing the second step of the sign in. It has no session,
+// so it is not accepted as an access token.
+func (j *Wrapper) GenerateTwoFactorToken(user models.User) (string, error) {
+	claims := &Claim{
+		ID:    user.ID,
+		Email: user.Email,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Local().Add(time.Minute * time.Duration(j.ExpirationMinutes)).Unix(),
+			Issuer:    j.Issuer,
+			Subject:   TwoFactorSubject,
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	return token.SignedString([]byte(j.SecretKey))
+}
@@ -0,0 +1,120 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mailer sends plain text emails
+type Mailer interface {
+	Send(to string, subject string, body string) error
+}
+
+// New returns the mailer selected by the MAIL_DRIVER environment variable.
+// "smtp" sends through SMTP_HOST; anything else writes emails to MAIL_DIR,
+// which suits offline installs.
+func New() Mailer {
+	from := os.Getenv("MAIL_FROM")
+
+	if os.Getenv("MAIL_DRIVER") == "smtp" {
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+
+	dir := os.Getenv("MAIL_DIR")
+	if len(dir) == 0 {
+		dir = "./mail"
+	}
+
+	return &FileMailer{Dir: dir, From: from}
+}
+
+// headerReplacer keeps header values on a single line
+var headerReplacer = strings.NewReplacer("\r", "", "\n", "")
+
+// message formats an email as RFC 5322 text
+func message(from string, to string, subject string, body string) []byte {
+	from, to, subject = headerReplacer.Replace(from), headerReplacer.Replace(to), headerReplacer.Replace(subject)
+
+	var b strings.Builder
+
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send ...
+func (m *SMTPMailer) Send(to string, subject string, body string) error {
+	var auth smtp.Auth
+	if len(m.Username) > 0 {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, message(m.From, to, subject, body))
+}
+
+// FileMailer writes emails to files in Dir instead of sending them
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send ...
+func (m *FileMailer) Send(to string, subject string, body string) error {
+	if err := os.MkdirAll(m.Dir, 0700); err != nil {
+		return err
+	}
+
+	fileName := filepath.Join(m.Dir, fmt.Sprintf("%d.eml", time.Now().UnixNano()))
+	if err := os.WriteFile(fileName, message(m.From, to, subject, body), 0600); err != nil {
+		return err
+	}
+
+	log.Printf("mailer: wrote email %q to %s\n", subject, fileName)
+
+	return nil
+}
@@ -21,14 +21,19 @@ package middleware
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/gin-gonic/gin"
 	"github.com/tensoremr/server/pkg/jwt"
+	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/repository"
 )
 
 // AuthMiddleware ...
@@ -56,8 +61,9 @@ type contextKey struct {
 	name string
 }
 
-// AuthMiddleware ...
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware validates the access token and rejects tokens of revoked
+// sessions and of inactive or locked users
+func AuthMiddleware(userRepository repository.UserRepository, refreshTokenRepository repository.RefreshTokenRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		clientToken := c.Request.Header.Get("Authorization")
 
@@ -77,15 +83,7 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		jwtSecret := os.Getenv("JWT_SECRET")
-		jwtIssuer := os.Getenv("JWT_ISSUER")
-
-		jwtWrapper := jwt.Wrapper{
-			SecretKey: jwtSecret,
-			Issuer:    jwtIssuer,
-		}
-
-		claims, err := jwtWrapper.ValidateToken(clientToken)
+		claims, err := authenticate(clientToken, &userRepository, &refreshTokenRepository)
 		if err != nil {
 			c.JSON(401, err.Error())
 			c.Abort()
@@ -98,6 +96,111 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// WebsocketInitFunc authenticates websocket connections using the
+// Authorization value sent in the connection_init payload, since browsers
+// cannot set headers on websocket requests
+func WebsocketInitFunc(userRepository repository.UserRepository, refreshTokenRepository repository.RefreshTokenRepository) transport.WebsocketInitFunc {
+	return func(ctx context.Context, initPayload transport.InitPayload) (context.Context, error) {
+		clientToken := initPayload.Authorization()
+		if clientToken == "" {
+			return nil, errors.New("No Authorization header provided")
+		}
+
+		extractedToken := strings.Split(clientToken, "Bearer ")
+		if len(extractedToken) != 2 {
+			return nil, errors.New("Incorrect Authorization Token Format")
+		}
+
+		claims, err := authenticate(strings.TrimSpace(extractedToken[1]), &userRepository, &refreshTokenRepository)
+		if err != nil {
+			return nil, err
+		}
+
+		gc, err := GinContextFromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		gc.Set("email", claims.Email)
+
+		return ctx, nil
+	}
+}
+
+// WebsocketCheckOrigin accepts websocket upgrades from the web client at
+// appURL and from the server's own origin. Requests without an Origin header
+// don't come from a browser, so they can't be used for cross-site hijacking
+func WebsocketCheckOrigin(appURL string) func(r *http.Request) bool {
+	allowed, _ := url.Parse(appURL)
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if len(origin) == 0 {
+			return true
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+
+		if strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+
+		return allowed != nil && strings.EqualFold(u.Scheme, allowed.Scheme) && strings.EqualFold(u.Host, allowed.Host)
+	}
+}
+
+func validateToken(clientToken string) (*jwt.Claim, error) {
+	jwtSecret := os.Getenv("JWT_SECRET")
+	jwtIssuer := os.Getenv("JWT_ISSUER")
+
+	jwtWrapper := jwt.Wrapper{
+		SecretKey: jwtSecret,
+		Issuer:    jwtIssuer,
+	}
+
+	return jwtWrapper.ValidateToken(clientToken)
+}
+
+// authenticate validates the token and checks that its session has not been
+// revoked and that its user may still sign in
+func authenticate(clientToken string, userRepository *repository.UserRepository, refreshTokenRepository *repository.RefreshTokenRepository) (*jwt.Claim, error) {
+	claims, err := validateToken(clientToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(claims.SessionID) == 0 {
+		return nil, errors.New("Session has been revoked")
+	}
+
+	active, err := refreshTokenRepository.IsSessionActive(claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !active {
+		return nil, errors.New("Session has been revoked")
+	}
+
+	var user models.User
+	if err := userRepository.GetByEmail(&user, claims.Email); err != nil {
+		return nil, errors.New("Cannot find user")
+	}
+
+	if !user.Active {
+		return nil, errors.New("Your account is inactive")
+	}
+
+	if user.IsLocked() {
+		return nil, errors.New("Your account is locked")
+	}
+
+	return claims, nil
+}
+
 // CORSMiddleware ...
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
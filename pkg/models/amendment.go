@@ -15,16 +15,44 @@
   You should have received a copy of the GNU General Public License
   along with this program.  If not, see <http://www.gnu.org/licenses/>.
 */
-
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AmendmentStatus ...
+type AmendmentStatus string
+
+// Amendment statuses ...
+const (
+	AmendmentPendingCosign AmendmentStatus = "PENDING_COSIGN"
+	AmendmentApplied       AmendmentStatus = "APPLIED"
+)
 
-// Amendment ...
+// Amendment is a change to a signed patient chart. Structured amendments name the
+// entity and field they change and keep the value before and after; amendments
+// without an entity are free-text addenda
 type Amendment struct {
 	gorm.Model
-	ID             int    `gorm:"primaryKey"`
-	PatientChartID int    `json:"patientChartId"`
-	Note           string `json:"note"`
-	Count          int64  `json:"count"`
+	ID             int             `gorm:"primaryKey"`
+	PatientChartID int             `json:"patientChartId"`
+	EntityType     *string         `json:"entityType"`
+	EntityID       *int            `json:"entityId"`
+	Field          *string         `json:"field"`
+	OldValue       *string         `json:"oldValue"`
+	NewValue       *string         `json:"newValue"`
+	Note           string          `json:"note"`
+	Reason         string          `json:"reason"`
+	AuthorID       *int            `json:"authorId"`
+	Author         *User           `json:"author"`
+	RequiresCosign bool            `json:"requiresCosign"`
+	CosignedByID   *int            `json:"cosignedById"`
+	CosignedBy     *User           `json:"cosignedBy"`
+	CosignedAt     *time.Time      `json:"cosignedAt"`
+	Status         AmendmentStatus `json:"status" gorm:"default:APPLIED"`
+	AppliedAt      *time.Time      `json:"appliedAt"`
+	Count          int64           `json:"count"`
 }
@@ -45,6 +45,7 @@ type Appointment struct {
 	Emergency           *bool             `json:"emergency"`
 	MedicalDepartment   string            `json:"medicalDepartment"`
 	Credit              bool              `json:"credit"`
+	CapacityOverride    bool              `json:"capacityOverride"`
 	Payments            []Payment         `json:"payments" gorm:"many2many:appointment_payments;"`
 	Files               []File            `json:"files" gorm:"many2many:appointment_files"`
 	UserID              int               `json:"userId"`
@@ -52,6 +53,9 @@ type Appointment struct {
 	PatientChart        PatientChart      `json:"patientChart"`
 	QueueID             int               `json:"queueId"`
 	QueueName           string            `json:"queueName"`
+	AppointmentSeriesID *int              `json:"appointmentSeriesId" gorm:"index"`
+	ReminderSentAt      *time.Time        `json:"reminderSentAt"`
+	TicketNumber        *int              `json:"ticketNumber"`
 	Document            string            `gorm:"type:tsvector"`
 	Count               int64             `json:"count"`
 }
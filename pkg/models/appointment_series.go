@@ -0,0 +1,112 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxSeriesOccurrences bounds the number of appointments a series creates
+const MaxSeriesOccurrences = 100
+
+// RecurrenceFrequency ...
+type RecurrenceFrequency string
+
+// Recurrence frequencies ...
+const (
+	DailyRecurrence  RecurrenceFrequency = "DAILY"
+	WeeklyRecurrence RecurrenceFrequency = "WEEKLY"
+)
+
+// AppointmentSeries is a recurring appointment that repeats every Interval
+// days or weeks from StartTime, either a number of times or until a date,
+// similar to an RRULE with FREQ, INTERVAL and COUNT or UNTIL
+type AppointmentSeries struct {
+	gorm.Model
+	ID                int                 `gorm:"primaryKey"`
+	PatientID         int                 `json:"patientId" gorm:"index"`
+	Patient           Patient             `json:"patient"`
+	UserID            int                 `json:"userId"`
+	RoomID            int                 `json:"roomId"`
+	VisitTypeID       int                 `json:"visitTypeId"`
+	MedicalDepartment string              `json:"medicalDepartment"`
+	Credit            bool                `json:"credit"`
+	StartTime         time.Time           `json:"startTime"`
+	Frequency         RecurrenceFrequency `json:"frequency"`
+	Interval          int                 `json:"interval"`
+	Occurrences       *int                `json:"occurrences"`
+	Until             *time.Time          `json:"until"`
+	Appointments      []Appointment       `json:"appointments"`
+	Count             int64               `json:"count"`
+}
+
+// Validate ...
+func (r *AppointmentSeries) Validate() error {
+	if r.Frequency != DailyRecurrence && r.Frequency != WeeklyRecurrence {
+		return fmt.Errorf("Unknown recurrence frequency %q", r.Frequency)
+	}
+
+	if r.Interval < 1 {
+		return errors.New("Interval must be at least 1")
+	}
+
+	if (r.Occurrences == nil) == (r.Until == nil) {
+		return errors.New("Either the number of occurrences or an until date is required")
+	}
+
+	if r.Occurrences != nil && (*r.Occurrences < 1 || *r.Occurrences > MaxSeriesOccurrences) {
+		return fmt.Errorf("Occurrences must be between 1 and %d", MaxSeriesOccurrences)
+	}
+
+	if r.Until != nil && r.Until.Before(r.StartTime) {
+		return errors.New("Until date must not be before the start time")
+	}
+
+	return nil
+}
+
+// Dates returns the check-in time of every occurrence of the series
+func (r *AppointmentSeries) Dates() []time.Time {
+	var dates []time.Time
+
+	for i := 0; i < MaxSeriesOccurrences; i++ {
+		if r.Occurrences != nil && i >= *r.Occurrences {
+			break
+		}
+
+		var date time.Time
+		if r.Frequency == WeeklyRecurrence {
+			date = r.StartTime.AddDate(0, 0, 7*r.Interval*i)
+		} else {
+			date = r.StartTime.AddDate(0, 0, r.Interval*i)
+		}
+
+		if r.Until != nil && date.After(*r.Until) {
+			break
+		}
+
+		dates = append(dates, date)
+	}
+
+	return dates
+}
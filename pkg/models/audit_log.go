@@ -0,0 +1,50 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// AuditAction ...
+type AuditAction string
+
+// Audit Actions ...
+const (
+	ReadAuditAction   AuditAction = "READ"
+	CreateAuditAction AuditAction = "CREATE"
+	UpdateAuditAction AuditAction = "UPDATE"
+	DeleteAuditAction AuditAction = "DELETE"
+)
+
+// AuditLog is an append-only record of a user reading or changing patient data
+type AuditLog struct {
+	ID            int            `gorm:"primaryKey" json:"id"`
+	UserEmail     string         `json:"userEmail" gorm:"index"`
+	OperationName string         `json:"operationName"`
+	Action        AuditAction    `json:"action"`
+	EntityType    string         `json:"entityType" gorm:"index:idx_audit_logs_entity"`
+	EntityID      int            `json:"entityId" gorm:"index:idx_audit_logs_entity"`
+	Changes       datatypes.JSON `json:"changes"`
+	ClientIP      string         `json:"clientIp"`
+	CreatedAt     time.Time      `json:"createdAt" gorm:"index"`
+	Count         int64          `json:"count"`
+}
@@ -0,0 +1,65 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package models
+
+import (
+	"path/filepath"
+
+	"gorm.io/gorm"
+)
+
+// ChartDocumentDir is where generated documents are stored. Unlike uploads,
+// which are served publicly from the files directory, documents are full of
+// patient information and are only served to signed in users
+const ChartDocumentDir = "documents"
+
+// ChartDocumentType ...
+type ChartDocumentType string
+
+// Chart document types ...
+const (
+	ChartDocumentPrescription          ChartDocumentType = "PRESCRIPTION"
+	ChartDocumentEyewearPrescription   ChartDocumentType = "EYEWEAR_PRESCRIPTION"
+	ChartDocumentSickLeave             ChartDocumentType = "SICK_LEAVE"
+	ChartDocumentMedicalRecommendation ChartDocumentType = "MEDICAL_RECOMMENDATION"
+	ChartDocumentReferral              ChartDocumentType = "REFERRAL"
+)
+
+// ChartDocument is a PDF generated from patient chart data. The file is also
+// attached to the appointment. The verification code, printed as a QR code on
+// the document, lets anyone holding a copy confirm it was issued
+type ChartDocument struct {
+	gorm.Model
+	ID               int               `gorm:"primaryKey" json:"id"`
+	Type             ChartDocumentType `json:"type"`
+	PatientChartID   int               `json:"patientChartId" gorm:"index"`
+	AppointmentID    int               `json:"appointmentId"`
+	ReferralID       *int              `json:"referralId"`
+	FileID           int               `json:"fileId"`
+	File             File              `json:"file"`
+	Checksum         string            `json:"checksum"`
+	VerificationCode string            `json:"verificationCode" gorm:"uniqueIndex"`
+	IssuedByID       int               `json:"issuedById"`
+	IssuedBy         User              `json:"issuedBy"`
+}
+
+// Path returns the location of the document's PDF on disk
+func (r *ChartDocument) Path() string {
+	return filepath.Join(ChartDocumentDir, r.File.FileName+"_"+r.File.Hash+"."+r.File.Extension)
+}
@@ -0,0 +1,40 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// ChartRevision is the full state of a patient chart record after one save.
+// Revisions are append-only and numbered per record, starting at 1
+type ChartRevision struct {
+	ID             int            `gorm:"primaryKey" json:"id"`
+	PatientChartID int            `json:"patientChartId" gorm:"index"`
+	EntityType     string         `json:"entityType" gorm:"uniqueIndex:idx_chart_revisions_revision"`
+	EntityID       int            `json:"entityId" gorm:"uniqueIndex:idx_chart_revisions_revision"`
+	Revision       int            `json:"revision" gorm:"uniqueIndex:idx_chart_revisions_revision"`
+	Data           datatypes.JSON `json:"data"`
+	Deleted        bool           `json:"deleted"`
+	UserEmail      string         `json:"userEmail"`
+	UserID         *int           `json:"userId"`
+	User           *User          `json:"user"`
+	CreatedAt      time.Time      `json:"createdAt" gorm:"index"`
+}
@@ -19,11 +19,13 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/tensoremr/server/pkg/conf"
@@ -201,6 +203,9 @@ func (m *Model) RegisterAllModels() {
 	m.Register(DiagnosticProcedureOrder{})
 	m.Register(LabOrder{})
 	m.Register(Amendment{})
+	m.Register(ChartRevision{})
+	m.Register(NoteTemplate{})
+	m.Register(ChartDocument{})
 	m.Register(OrganizationDetails{})
 	m.Register(System{})
 	m.Register(SystemSymptom{})
@@ -209,7 +214,10 @@ func (m *Model) RegisterAllModels() {
 	m.Register(ExamFinding{})
 	m.Register(PhysicalExamFinding{})
 	m.Register(PatientQueue{})
+	m.Register(QueueEntry{})
+	m.Register(QueueCall{})
 	m.Register(QueueSubscription{})
+	m.Register(TicketCounter{})
 	m.Register(OpthalmologyExam{})
 	m.Register(VitalSigns{})
 	m.Register(SurgicalOrder{})
@@ -217,6 +225,16 @@ func (m *Model) RegisterAllModels() {
 	m.Register(FollowUp{})
 	m.Register(FollowUpOrder{})
 	m.Register(ReferralOrder{})
+	m.Register(AuditLog{})
+	m.Register(RefreshToken{})
+	m.Register(PatientMerge{})
+	m.Register(ProviderSchedule{})
+	m.Register(ProviderScheduleBreak{})
+	m.Register(ScheduleException{})
+	m.Register(AppointmentSeries{})
+	m.Register(PostOpProtocolStep{})
+	m.Register(WaitlistEntry{})
+	m.Register(WaitlistOffer{})
 }
 
 func getTypeName(typ reflect.Type) string {
@@ -501,3 +519,91 @@ func (s *Model) AddSearchIndex() error {
 
 	return nil
 }
+
+// AddAuditLogTrigger makes the audit_logs table append-only by rejecting
+// updates and deletes at the database level
+func (s *Model) AddAuditLogTrigger() error {
+	d := s.DB
+
+	if err := d.Exec(`CREATE OR REPLACE FUNCTION audit_logs_append_only_trigger() RETURNS trigger AS $$
+	begin
+		raise exception 'audit logs are append-only';
+	end
+	$$ LANGUAGE plpgsql`).Error; err != nil {
+		return err
+	}
+
+	if err := d.Exec("DROP TRIGGER IF EXISTS append_only ON audit_logs").Error; err != nil {
+		return err
+	}
+
+	return d.Exec("CREATE TRIGGER append_only BEFORE UPDATE OR DELETE ON audit_logs FOR EACH ROW EXECUTE PROCEDURE audit_logs_append_only_trigger()").Error
+}
+
+// MigratePatientQueues moves the appointment ids kept in the legacy
+// patient_queues.queue JSON column into queue_entries rows and drops the
+// column. It does nothing once the column is gone
+func (s *Model) MigratePatientQueues() error {
+	if !s.Migrator().HasColumn(&PatientQueue{}, "queue") {
+		return nil
+	}
+
+	return s.Transaction(func(tx *gorm.DB) error {
+		var patientQueues []struct {
+			ID    int
+			Queue string
+		}
+
+		if err := tx.Table("patient_queues").Select("id, queue").Where("deleted_at IS NULL").Scan(&patientQueues).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		for _, patientQueue := range patientQueues {
+			var ids []int
+			if len(patientQueue.Queue) != 0 {
+				if err := json.Unmarshal([]byte(patientQueue.Queue), &ids); err != nil {
+					return err
+				}
+			}
+
+			seen := make(map[int]bool)
+			position := 0
+
+			for _, id := range ids {
+				if seen[id] {
+					continue
+				}
+
+				seen[id] = true
+
+				var appointment Appointment
+				if err := tx.Select("id, checked_in_time").Where("id = ?", id).Take(&appointment).Error; err != nil {
+					continue
+				}
+
+				enqueuedAt := now
+				if appointment.CheckedInTime != nil {
+					enqueuedAt = *appointment.CheckedInTime
+				}
+
+				position++
+
+				entry := QueueEntry{
+					PatientQueueID: patientQueue.ID,
+					AppointmentID:  id,
+					Position:       position,
+					EnqueuedAt:     enqueuedAt,
+					Status:         QueueEntryWaiting,
+				}
+
+				if err := tx.Create(&entry).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Migrator().DropColumn(&PatientQueue{}, "queue")
+	})
+}
@@ -0,0 +1,49 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+// NoteTemplateSection is the patient chart note a template is written for
+type NoteTemplateSection string
+
+// Note template sections ...
+const (
+	NoteTemplateHpi                   NoteTemplateSection = "HPI"
+	NoteTemplateChiefComplaint        NoteTemplateSection = "CHIEF_COMPLAINT"
+	NoteTemplatePhysicalExam          NoteTemplateSection = "PHYSICAL_EXAM"
+	NoteTemplateDiagnosis             NoteTemplateSection = "DIAGNOSIS"
+	NoteTemplateDifferentialDiagnosis NoteTemplateSection = "DIFFERENTIAL_DIAGNOSIS"
+	NoteTemplateSummary               NoteTemplateSection = "SUMMARY"
+	NoteTemplateMedicalRecommendation NoteTemplateSection = "MEDICAL_RECOMMENDATION"
+)
+
+// NoteTemplate is reusable text for a patient chart note. Templates without a
+// user are global and available to every provider. The body may contain
+// placeholders such as {{patientName}} that are filled in from a patient chart
+type NoteTemplate struct {
+	gorm.Model
+	ID        int                 `gorm:"primaryKey" json:"id"`
+	Title     string              `json:"title"`
+	Section   NoteTemplateSection `json:"section" gorm:"index"`
+	Specialty *string             `json:"specialty" gorm:"index"`
+	Body      string              `json:"body"`
+	UserID    *int                `json:"userId" gorm:"index"`
+	User      *User               `json:"user"`
+}
@@ -64,6 +64,8 @@ type Patient struct {
 	Documents              []File         `json:"documents" gorm:"many2many:patient_documents"`
 	PatientHistory         PatientHistory `json:"patientHistory"`
 	Appointments           []Appointment  `json:"appointments"`
+	NoShowCount            int            `json:"noShowCount"`
+	LastNoShowAt           *time.Time     `json:"lastNoShowAt"`
 	Document               string         `gorm:"type:tsvector"`
 	Count                  int64          `json:"count"`
 }
@@ -95,3 +97,11 @@ func (r *Patient) AfterUpdate(tx *gorm.DB) (err error) {
 
 	return
 }
+
+// NoShowStats summarizes how often a patient misses appointments
+type NoShowStats struct {
+	TotalAppointments int        `json:"totalAppointments"`
+	NoShows           int        `json:"noShows"`
+	NoShowRate        float64    `json:"noShowRate"`
+	LastNoShowAt      *time.Time `json:"lastNoShowAt"`
+}
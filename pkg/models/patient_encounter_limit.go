@@ -18,7 +18,11 @@
 
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // PatientEncounterLimit ...
 type PatientEncounterLimit struct {
@@ -36,3 +40,36 @@ type PatientEncounterLimit struct {
 	Overbook       int   `json:"overbook"`
 	Count          int64 `json:"count"`
 }
+
+// DailyLimit returns the number of patients the provider sees on weekday
+func (r *PatientEncounterLimit) DailyLimit(weekday time.Weekday) int {
+	switch weekday {
+	case time.Monday:
+		return r.MondayLimit
+	case time.Tuesday:
+		return r.TuesdayLimit
+	case time.Wednesday:
+		return r.WednesdayLimit
+	case time.Thursday:
+		return r.ThursdayLimit
+	case time.Friday:
+		return r.FridayLimit
+	case time.Saturday:
+		return r.SaturdayLimit
+	case time.Sunday:
+		return r.SundayLimit
+	}
+
+	return 0
+}
+
+// ProviderAvailability is the number of appointments booked with a provider
+// on a day against their encounter limit. Limit and Remaining are nil when
+// the provider has no encounter limit.
+type ProviderAvailability struct {
+	Date      time.Time `json:"date"`
+	Limit     *int      `json:"limit"`
+	Overbook  int       `json:"overbook"`
+	Booked    int       `json:"booked"`
+	Remaining *int      `json:"remaining"`
+}
@@ -0,0 +1,53 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// PatientMerge records a merge of a duplicate patient into a surviving
+// record, along with the ids of every row that was moved so that the merge
+// can be undone
+type PatientMerge struct {
+	gorm.Model
+	ID           int            `gorm:"primaryKey"`
+	SurvivorID   int            `json:"survivorId" gorm:"index"`
+	Survivor     Patient        `json:"survivor"`
+	MergedID     int            `json:"mergedId" gorm:"index"`
+	Merged       Patient        `json:"merged"`
+	MergedByID   int            `json:"mergedById"`
+	MergedBy     User           `json:"mergedBy"`
+	MovedRecords datatypes.JSON `json:"movedRecords"`
+	UndoneAt     *time.Time     `json:"undoneAt"`
+	UndoneByID   *int           `json:"undoneById"`
+	UndoneBy     *User          `json:"undoneBy"`
+	Count        int64          `json:"count"`
+}
+
+// PatientDuplicate is a patient that is likely the same person as another
+// patient, with a score from 0 to 1 and the reasons it was matched
+type PatientDuplicate struct {
+	Patient *Patient `json:"patient"`
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
@@ -19,7 +19,8 @@
 package models
 
 import (
-	"gorm.io/datatypes"
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -37,11 +38,100 @@ const (
 	PreOperation    QueueType = "PREOPERATION"
 )
 
+// DisplayQueueTypes are the queues that may be shown on a public waiting-room
+// display. Other queues are named after lab tests and procedures, which would
+// disclose why a patient is waiting
+var DisplayQueueTypes = []QueueType{UserQueue, PreExamQueue, PreOperation}
+
 // PatientQueue ...
 type PatientQueue struct {
 	gorm.Model
-	ID        int            `gorm:"primaryKey" json:"id"`
-	QueueName string         `json:"queueName" gorm:"uniqueIndex"`
-	Queue     datatypes.JSON `json:"queue"`
-	QueueType QueueType      `json:"queueType"`
+	ID        int       `gorm:"primaryKey" json:"id"`
+	QueueName string    `json:"queueName" gorm:"uniqueIndex"`
+	QueueType QueueType `json:"queueType"`
+}
+
+// QueueEntryStatus ...
+type QueueEntryStatus string
+
+// Queue entry statuses ...
+const (
+	QueueEntryWaiting   QueueEntryStatus = "WAITING"
+	QueueEntryInService QueueEntryStatus = "IN_SERVICE"
+	QueueEntrySkipped   QueueEntryStatus = "SKIPPED"
+	QueueEntryMoved     QueueEntryStatus = "MOVED"
+	QueueEntryRemoved   QueueEntryStatus = "REMOVED"
+	QueueEntryExpired   QueueEntryStatus = "EXPIRED"
+)
+
+// ActiveQueueEntryStatuses are the statuses of entries that are still in their queue
+var ActiveQueueEntryStatuses = []QueueEntryStatus{QueueEntryWaiting, QueueEntryInService, QueueEntrySkipped}
+
+// QueueEntry is an appointment's place in a patient queue. Entries are never
+// deleted; leaving a queue only changes the status and sets LeftAt so the
+// history of who waited where, and for how long, is kept
+type QueueEntry struct {
+	gorm.Model
+	ID             int              `gorm:"primaryKey" json:"id"`
+	PatientQueueID int              `json:"patientQueueId" gorm:"index:idx_queue_entries_queue_status"`
+	PatientQueue   PatientQueue     `json:"patientQueue"`
+	AppointmentID  int              `json:"appointmentId" gorm:"index"`
+	Appointment    Appointment      `json:"appointment"`
+	Position       int              `json:"position"`
+	EnqueuedAt     time.Time        `json:"enqueuedAt"`
+	EnqueuedByID   *int             `json:"enqueuedById"`
+	EnqueuedBy     *User            `json:"enqueuedBy"`
+	CalledAt       *time.Time       `json:"calledAt"`
+	RoomID         *int             `json:"roomId"`
+	Room           *Room            `json:"room"`
+	LeftAt         *time.Time       `json:"leftAt"`
+	Status         QueueEntryStatus `json:"status" gorm:"index:idx_queue_entries_queue_status"`
+}
+
+// QueueCallAction ...
+type QueueCallAction string
+
+// Queue call actions ...
+const (
+	QueueCallCall   QueueCallAction = "CALL"
+	QueueCallRecall QueueCallAction = "RECALL"
+	QueueCallSkip   QueueCallAction = "SKIP"
+)
+
+// QueueCall records a patient being called to a room, called again or skipped
+// for not showing up
+type QueueCall struct {
+	gorm.Model
+	ID             int             `gorm:"primaryKey" json:"id"`
+	QueueEntryID   int             `json:"queueEntryId" gorm:"index"`
+	PatientQueueID int             `json:"patientQueueId"`
+	AppointmentID  int             `json:"appointmentId"`
+	Action         QueueCallAction `json:"action"`
+	RoomID         *int            `json:"roomId"`
+	Room           *Room           `json:"room"`
+	UserID         *int            `json:"userId"`
+	User           *User           `json:"user"`
+}
+
+// TicketCounter hands out the sequential ticket numbers of a day
+type TicketCounter struct {
+	Day        string `gorm:"primaryKey" json:"day"`
+	LastNumber int    `json:"lastNumber"`
+}
+
+// QueueDisplay is what the screens in waiting rooms show of a patient queue.
+// Patients are only identified by their initials
+type QueueDisplay struct {
+	PatientQueueID int                 `json:"patientQueueId"`
+	QueueName      string              `json:"queueName"`
+	Entries        []QueueDisplayEntry `json:"entries"`
+}
+
+// QueueDisplayEntry ...
+type QueueDisplayEntry struct {
+	QueueNumber  int              `json:"queueNumber"`
+	TicketNumber *int             `json:"ticketNumber"`
+	Initials     string           `json:"initials"`
+	Room         string           `json:"room"`
+	Status       QueueEntryStatus `json:"status"`
 }
@@ -0,0 +1,100 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostOpDelayUnit ...
+type PostOpDelayUnit string
+
+// Post-op delay units ...
+const (
+	DayPostOpDelay   PostOpDelayUnit = "DAY"
+	WeekPostOpDelay  PostOpDelayUnit = "WEEK"
+	MonthPostOpDelay PostOpDelayUnit = "MONTH"
+)
+
+// PostOpProviderRule decides who sees the patient at a post-op visit
+type PostOpProviderRule string
+
+// Post-op provider rules ...
+const (
+	SurgeonPostOpProvider  PostOpProviderRule = "SURGEON"
+	SpecificPostOpProvider PostOpProviderRule = "SPECIFIC_PROVIDER"
+)
+
+// PostOpProtocolStep is one follow-up visit of the post-operative protocol
+// of a surgical procedure type, scheduled Delay days, weeks or months after
+// the surgery
+type PostOpProtocolStep struct {
+	gorm.Model
+	ID                      int                   `gorm:"primaryKey"`
+	SurgicalProcedureTypeID int                   `json:"surgicalProcedureTypeId" gorm:"index"`
+	SurgicalProcedureType   SurgicalProcedureType `json:"surgicalProcedureType"`
+	Delay                   int                   `json:"delay"`
+	DelayUnit               PostOpDelayUnit       `json:"delayUnit"`
+	VisitTypeID             int                   `json:"visitTypeId"`
+	VisitType               VisitType             `json:"visitType"`
+	RoomID                  int                   `json:"roomId"`
+	Room                    Room                  `json:"room"`
+	ProviderRule            PostOpProviderRule    `json:"providerRule"`
+	UserID                  *int                  `json:"userId"`
+	User                    *User                 `json:"user"`
+	Count                   int64                 `json:"count"`
+}
+
+// Validate ...
+func (r *PostOpProtocolStep) Validate() error {
+	if r.Delay < 1 {
+		return errors.New("Post-op visits must be at least one day after surgery")
+	}
+
+	if r.DelayUnit != DayPostOpDelay && r.DelayUnit != WeekPostOpDelay && r.DelayUnit != MonthPostOpDelay {
+		return errors.New("Unknown post-op delay unit")
+	}
+
+	switch r.ProviderRule {
+	case SurgeonPostOpProvider:
+	case SpecificPostOpProvider:
+		if r.UserID == nil {
+			return errors.New("A provider is required for post-op visits with a specific provider")
+		}
+	default:
+		return errors.New("Unknown post-op provider rule")
+	}
+
+	return nil
+}
+
+// ScheduledAt returns the time of the step's visit for a surgery at surgeryTime
+func (r *PostOpProtocolStep) ScheduledAt(surgeryTime time.Time) time.Time {
+	switch r.DelayUnit {
+	case WeekPostOpDelay:
+		return surgeryTime.AddDate(0, 0, 7*r.Delay)
+	case MonthPostOpDelay:
+		return surgeryTime.AddDate(0, r.Delay, 0)
+	}
+
+	return surgeryTime.AddDate(0, 0, r.Delay)
+}
@@ -0,0 +1,137 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultSlotDuration is the slot length in minutes of visit types that do
+// not set one
+const DefaultSlotDuration = 15
+
+// ProviderSchedule is a weekly working period of a provider. Weekday
+// follows time.Weekday, with 0 being Sunday, and StartTime and EndTime are
+// "15:04" clock times in the clinic's local time.
+type ProviderSchedule struct {
+	gorm.Model
+	ID        int                     `gorm:"primaryKey"`
+	UserID    int                     `json:"userId" gorm:"index"`
+	User      User                    `json:"user"`
+	Weekday   int                     `json:"weekday"`
+	StartTime string                  `json:"startTime"`
+	EndTime   string                  `json:"endTime"`
+	RoomID    *int                    `json:"roomId"`
+	Room      *Room                   `json:"room"`
+	Breaks    []ProviderScheduleBreak `json:"breaks"`
+	Count     int64                   `json:"count"`
+}
+
+// ProviderScheduleBreak is a break within a provider schedule during which
+// no appointments are booked
+type ProviderScheduleBreak struct {
+	gorm.Model
+	ID                 int    `gorm:"primaryKey"`
+	ProviderScheduleID int    `json:"providerScheduleId" gorm:"index"`
+	StartTime          string `json:"startTime"`
+	EndTime            string `json:"endTime"`
+}
+
+// Validate checks that the schedule and its breaks are well formed
+func (r *ProviderSchedule) Validate() error {
+	if r.Weekday < int(time.Sunday) || r.Weekday > int(time.Saturday) {
+		return errors.New("Weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+
+	start, end, err := ClockRange(r.StartTime, r.EndTime)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range r.Breaks {
+		breakStart, breakEnd, err := ClockRange(b.StartTime, b.EndTime)
+		if err != nil {
+			return err
+		}
+
+		if breakStart < start || breakEnd > end {
+			return fmt.Errorf("Break %s-%s is outside of the schedule", b.StartTime, b.EndTime)
+		}
+	}
+
+	return nil
+}
+
+// ClockRange parses a pair of "15:04" clock times into minutes since
+// midnight, checking that start is before end
+func ClockRange(startTime string, endTime string) (int, int, error) {
+	start, err := time.Parse("15:04", startTime)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid time %q, expected HH:MM", startTime)
+	}
+
+	end, err := time.Parse("15:04", endTime)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid time %q, expected HH:MM", endTime)
+	}
+
+	startMinutes := start.Hour()*60 + start.Minute()
+	endMinutes := end.Hour()*60 + end.Minute()
+
+	if startMinutes >= endMinutes {
+		return 0, 0, errors.New("Start time must be before end time")
+	}
+
+	return startMinutes, endMinutes, nil
+}
+
+// ScheduleExceptionType ...
+type ScheduleExceptionType string
+
+// Schedule exception types ...
+const (
+	LeaveScheduleException   ScheduleExceptionType = "LEAVE"
+	HolidayScheduleException ScheduleExceptionType = "HOLIDAY"
+)
+
+// ScheduleException blocks out a provider's schedule, or every provider's
+// schedule when UserID is nil, between StartTime and EndTime
+type ScheduleException struct {
+	gorm.Model
+	ID        int                   `gorm:"primaryKey"`
+	UserID    *int                  `json:"userId" gorm:"index"`
+	User      *User                 `json:"user"`
+	Type      ScheduleExceptionType `json:"type"`
+	StartTime time.Time             `json:"startTime" gorm:"index"`
+	EndTime   time.Time             `json:"endTime" gorm:"index"`
+	Reason    string                `json:"reason"`
+	Count     int64                 `json:"count"`
+}
+
+// AvailableSlot is a free appointment slot of a provider
+type AvailableSlot struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	UserID int       `json:"userId"`
+	RoomID *int      `json:"roomId"`
+}
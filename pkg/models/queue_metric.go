@@ -0,0 +1,97 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// DurationStats summarizes a set of durations, in minutes
+type DurationStats struct {
+	Count   int     `json:"count"`
+	Average float64 `json:"average"`
+	Median  float64 `json:"median"`
+	P90     float64 `json:"p90"`
+}
+
+// NewDurationStats computes the average, median and 90th percentile of the given
+// durations. Percentiles are interpolated between the closest ranks
+func NewDurationStats(durations []time.Duration) DurationStats {
+	stats := DurationStats{Count: len(durations)}
+	if len(durations) == 0 {
+		return stats
+	}
+
+	minutes := make([]float64, len(durations))
+	total := 0.0
+
+	for i, d := range durations {
+		minutes[i] = d.Minutes()
+		total += minutes[i]
+	}
+
+	sort.Float64s(minutes)
+
+	stats.Average = total / float64(len(minutes))
+	stats.Median = percentile(minutes, 0.5)
+	stats.P90 = percentile(minutes, 0.9)
+
+	return stats
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(rank)
+
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+
+	return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// QueueMetric is how long patients waited in a patient queue on a day.
+// WaitTime is the time between entering the queue and being called, or leaving
+// the queue for patients that were never called. ServiceTime
+// is the time the visits that passed through the queue were checked in
+// without waiting in any queue
+type QueueMetric struct {
+	Date           time.Time     `json:"date"`
+	PatientQueueID int           `json:"patientQueueId"`
+	PatientQueue   PatientQueue  `json:"patientQueue"`
+	WaitTime       DurationStats `json:"waitTime"`
+	ServiceTime    DurationStats `json:"serviceTime"`
+}
+
+// ProviderQueueMetric is how long a provider's patients waited on a day.
+// WaitTime is the total time a visit spent waiting in queues and ServiceTime the
+// rest of the time between check-in and check-out
+type ProviderQueueMetric struct {
+	Date        time.Time     `json:"date"`
+	UserID      int           `json:"userId"`
+	User        User          `json:"user"`
+	WaitTime    DurationStats `json:"waitTime"`
+	ServiceTime DurationStats `json:"serviceTime"`
+}
+
+// QueueMetrics ...
+type QueueMetrics struct {
+	Queues    []*QueueMetric         `json:"queues"`
+	Providers []*ProviderQueueMetric `json:"providers"`
+}
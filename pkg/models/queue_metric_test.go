@@ -0,0 +1,69 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewDurationStats(t *testing.T) {
+	minutes := func(values ...int) []time.Duration {
+		var durations []time.Duration
+		for _, v := range values {
+			durations = append(durations, time.Duration(v)*time.Minute)
+		}
+		return durations
+	}
+
+	cases := []struct {
+		name      string
+		durations []time.Duration
+		want      DurationStats
+	}{
+		{"empty", nil, DurationStats{}},
+		{"single", minutes(7), DurationStats{Count: 1, Average: 7, Median: 7, P90: 7}},
+		{"even count", minutes(4, 1, 3, 2), DurationStats{Count: 4, Average: 2.5, Median: 2.5, P90: 3.7}},
+		{"odd count", minutes(50, 10, 30, 20, 40), DurationStats{Count: 5, Average: 30, Median: 30, P90: 46}},
+		{"ten values", minutes(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), DurationStats{Count: 10, Average: 5.5, Median: 5.5, P90: 9.1}},
+		{"seconds", []time.Duration{30 * time.Second, 90 * time.Second}, DurationStats{Count: 2, Average: 1, Median: 1, P90: 1.4}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := NewDurationStats(c.durations)
+			if got.Count != c.want.Count || !near(got.Average, c.want.Average) || !near(got.Median, c.want.Median) || !near(got.P90, c.want.P90) {
+				t.Errorf("NewDurationStats() = %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNewDurationStatsKeepsInput(t *testing.T) {
+	durations := []time.Duration{3 * time.Minute, time.Minute, 2 * time.Minute}
+	NewDurationStats(durations)
+
+	if durations[0] != 3*time.Minute || durations[1] != time.Minute {
+		t.Errorf("NewDurationStats() reordered its input: %v", durations)
+	}
+}
+
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -51,9 +51,10 @@ type User struct {
 	ProfilePic   *File `json:"profilePic"`
 
 	// Confirm
-	ConfirmSelector string
-	ConfirmVerifier string
-	Confirmed       bool
+	ConfirmSelector    string
+	ConfirmVerifier    string
+	ConfirmTokenExpiry *time.Time
+	Confirmed          bool
 
 	// Lock
 	AttemptCount int
@@ -74,6 +75,8 @@ type User struct {
 
 	// 2fa
 	TOTPSecretKey      string
+	TOTPEnabled        bool
+	TOTPLastStep       int64
 	SMSPhoneNumber     string
 	SMSSeedPhoneNumber string
 	RecoveryCodes      string
@@ -82,6 +85,44 @@ type User struct {
 	Count    int64  `json:"count"`
 }
 
+// Account lockout policy
+const (
+	MaxLoginAttempts   = 5
+	LoginAttemptWindow = 15 * time.Minute
+	LockoutDuration    = 15 * time.Minute
+)
+
+// IsLocked returns true if the account is locked at the moment
+func (r *User) IsLocked() bool {
+	return r.Locked != nil && r.Locked.After(time.Now())
+}
+
+// RecordFailedAttempt counts a failed sign in and locks the account once
+// MaxLoginAttempts failures happen within LoginAttemptWindow
+func (r *User) RecordFailedAttempt() {
+	now := time.Now()
+
+	if r.LastAttempt == nil || now.Sub(*r.LastAttempt) > LoginAttemptWindow {
+		r.AttemptCount = 0
+	}
+
+	r.AttemptCount++
+	r.LastAttempt = &now
+
+	if r.AttemptCount >= MaxLoginAttempts {
+		locked := now.Add(LockoutDuration)
+		r.Locked = &locked
+		r.AttemptCount = 0
+	}
+}
+
+// ResetAttempts clears the failed sign in counter after a successful sign in
+func (r *User) ResetAttempts() {
+	r.AttemptCount = 0
+	r.LastAttempt = nil
+	r.Locked = nil
+}
+
 // HashPassword encrypts user password
 func (r *User) HashPassword() error {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(r.Password), 14)
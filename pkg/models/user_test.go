@@ -0,0 +1,97 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordFailedAttemptLocksAccount(t *testing.T) {
+	var user User
+
+	for i := 1; i < MaxLoginAttempts; i++ {
+		user.RecordFailedAttempt()
+		if user.IsLocked() {
+			t.Fatalf("account locked after %d failed attempts", i)
+		}
+	}
+
+	user.RecordFailedAttempt()
+	if !user.IsLocked() {
+		t.Fatalf("account not locked after %d failed attempts", MaxLoginAttempts)
+	}
+
+	if user.AttemptCount != 0 {
+		t.Errorf("AttemptCount = %d after locking, want 0", user.AttemptCount)
+	}
+
+	if remaining := time.Until(*user.Locked); remaining <= LockoutDuration-time.Minute || remaining > LockoutDuration {
+		t.Errorf("account locked for %v, want %v", remaining, LockoutDuration)
+	}
+}
+
+func TestRecordFailedAttemptOutsideWindow(t *testing.T) {
+	last := time.Now().Add(-LoginAttemptWindow - time.Minute)
+	user := User{AttemptCount: MaxLoginAttempts - 1, LastAttempt: &last}
+
+	user.RecordFailedAttempt()
+
+	if user.IsLocked() {
+		t.Errorf("account locked by attempts outside of the window")
+	}
+
+	if user.AttemptCount != 1 {
+		t.Errorf("AttemptCount = %d, want 1", user.AttemptCount)
+	}
+}
+
+func TestIsLocked(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Minute)
+
+	cases := []struct {
+		name   string
+		locked *time.Time
+		want   bool
+	}{
+		{"never locked", nil, false},
+		{"lock expired", &past, false},
+		{"locked", &future, true},
+	}
+
+	for _, c := range cases {
+		user := User{Locked: c.locked}
+		if got := user.IsLocked(); got != c.want {
+			t.Errorf("%s: IsLocked() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestResetAttempts(t *testing.T) {
+	var user User
+	for i := 0; i < MaxLoginAttempts; i++ {
+		user.RecordFailedAttempt()
+	}
+
+	user.ResetAttempts()
+
+	if user.IsLocked() || user.AttemptCount != 0 || user.LastAttempt != nil {
+		t.Errorf("ResetAttempts() left %+v", user)
+	}
+}
@@ -25,6 +25,16 @@ import (
 // VisitType ...
 type VisitType struct {
 	gorm.Model
-	ID    int    `gorm:"primaryKey"`
-	Title string `json:"title" gorm:"unique"`
+	ID           int    `gorm:"primaryKey"`
+	Title        string `json:"title" gorm:"unique"`
+	SlotDuration int    `json:"slotDuration"`
+}
+
+// SlotMinutes returns the length in minutes of an appointment of this type
+func (r *VisitType) SlotMinutes() int {
+	if r.SlotDuration <= 0 {
+		return DefaultSlotDuration
+	}
+
+	return r.SlotDuration
 }
@@ -0,0 +1,82 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WaitlistEntryStatus ...
+type WaitlistEntryStatus string
+
+// Waitlist entry statuses ...
+const (
+	WaitingWaitlistEntry WaitlistEntryStatus = "WAITING"
+	BookedWaitlistEntry  WaitlistEntryStatus = "BOOKED"
+	RemovedWaitlistEntry WaitlistEntryStatus = "REMOVED"
+)
+
+// WaitlistEntry is a patient waiting for an appointment with a provider or
+// of a visit type within a preferred date range. Entries with a higher
+// priority are offered freed slots first.
+type WaitlistEntry struct {
+	gorm.Model
+	ID            int                 `gorm:"primaryKey"`
+	PatientID     int                 `json:"patientId" gorm:"index"`
+	Patient       Patient             `json:"patient"`
+	UserID        *int                `json:"userId"`
+	User          *User               `json:"user"`
+	VisitTypeID   *int                `json:"visitTypeId"`
+	VisitType     *VisitType          `json:"visitType"`
+	PreferredFrom time.Time           `json:"preferredFrom"`
+	PreferredTo   time.Time           `json:"preferredTo"`
+	Priority      int                 `json:"priority"`
+	Note          string              `json:"note"`
+	Status        WaitlistEntryStatus `json:"status" gorm:"index"`
+	AppointmentID *int                `json:"appointmentId"`
+	Count         int64               `json:"count"`
+}
+
+// WaitlistOfferStatus ...
+type WaitlistOfferStatus string
+
+// Waitlist offer statuses ...
+const (
+	OpenWaitlistOffer   WaitlistOfferStatus = "OPEN"
+	FilledWaitlistOffer WaitlistOfferStatus = "FILLED"
+)
+
+// WaitlistOffer is a slot freed by a deleted or cancelled appointment that
+// is offered to waitlisted patients
+type WaitlistOffer struct {
+	gorm.Model
+	ID                 int                 `gorm:"primaryKey"`
+	FreedAppointmentID int                 `json:"freedAppointmentId"`
+	UserID             int                 `json:"userId" gorm:"index"`
+	User               User                `json:"user"`
+	RoomID             int                 `json:"roomId"`
+	VisitTypeID        int                 `json:"visitTypeId"`
+	VisitType          VisitType           `json:"visitType"`
+	CheckInTime        time.Time           `json:"checkInTime"`
+	Status             WaitlistOfferStatus `json:"status" gorm:"index"`
+	WaitlistEntryID    *int                `json:"waitlistEntryId"`
+	Count              int64               `json:"count"`
+}
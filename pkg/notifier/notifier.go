@@ -0,0 +1,136 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Channel is the medium a notification is sent through
+type Channel string
+
+// Channels ...
+const (
+	SMSChannel   Channel = "SMS"
+	EmailChannel Channel = "EMAIL"
+)
+
+// Notification is a message to a patient
+type Notification struct {
+	Channel Channel `json:"channel"`
+	To      string  `json:"to"`
+	Subject string  `json:"subject,omitempty"`
+	Body    string  `json:"body"`
+}
+
+// Notifier sends notifications to patients
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// New returns the notifier selected by the NOTIFIER_DRIVER environment
+// variable. "http" posts notifications as JSON to NOTIFIER_URL, for an SMS
+// or email gateway; anything else writes them to NOTIFIER_DIR.
+func New() Notifier {
+	if os.Getenv("NOTIFIER_DRIVER") == "http" {
+		return &HTTPNotifier{
+			URL:    os.Getenv("NOTIFIER_URL"),
+			Token:  os.Getenv("NOTIFIER_TOKEN"),
+			Client: &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+
+	dir := os.Getenv("NOTIFIER_DIR")
+	if len(dir) == 0 {
+		dir = "./notifications"
+	}
+
+	return &FileNotifier{Dir: dir}
+}
+
+// HTTPNotifier posts notifications to a gateway, authenticated with a bearer
+// token when Token is set
+type HTTPNotifier struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// Notify ...
+func (n *HTTPNotifier) Notify(notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+
+	res, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("notifier: gateway responded with %s", res.Status)
+	}
+
+	return nil
+}
+
+// FileNotifier writes notifications to files in Dir instead of sending them
+type FileNotifier struct {
+	Dir string
+}
+
+// Notify ...
+func (n *FileNotifier) Notify(notification Notification) error {
+	if err := os.MkdirAll(n.Dir, 0700); err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(notification, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fileName := filepath.Join(n.Dir, fmt.Sprintf("%d.json", time.Now().UnixNano()))
+	if err := os.WriteFile(fileName, body, 0600); err != nil {
+		return err
+	}
+
+	log.Printf("notifier: wrote %s notification to %s\n", notification.Channel, fileName)
+
+	return nil
+}
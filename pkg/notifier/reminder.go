@@ -0,0 +1,83 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tensoremr/server/pkg/repository"
+)
+
+// AppointmentReminders sends patients a reminder of each scheduled
+// appointment once it is less than Before away, by SMS to their phone and
+// by email when they have one
+type AppointmentReminders struct {
+	AppointmentRepository repository.AppointmentRepository
+	Notifier              Notifier
+	Before                time.Duration
+}
+
+// Send reminds patients of their upcoming appointments. Appointments that
+// fail to send are retried on the next run.
+func (r *AppointmentReminders) Send() error {
+	appointments, err := r.AppointmentRepository.FindDueReminders(time.Now().Add(r.Before))
+	if err != nil {
+		return err
+	}
+
+	for _, appointment := range appointments {
+		body := fmt.Sprintf("Dear %s, this is a reminder of your appointment on %s", appointment.Patient.FirstName, appointment.CheckInTime.Local().Format("Mon Jan 2 at 3:04 PM"))
+		if len(strings.TrimSpace(appointment.ProviderName)) > 0 {
+			body += " with " + appointment.ProviderName
+		}
+		body += ". Please let us know if you cannot attend."
+
+		var notifications []Notification
+		if phoneNo := strings.TrimSpace(appointment.Patient.PhoneNo); len(phoneNo) > 0 {
+			notifications = append(notifications, Notification{Channel: SMSChannel, To: phoneNo, Body: body})
+		}
+
+		if email := strings.TrimSpace(appointment.Patient.Email); len(email) > 0 {
+			notifications = append(notifications, Notification{Channel: EmailChannel, To: email, Subject: "Appointment reminder", Body: body})
+		}
+
+		sent := len(notifications) == 0
+		for _, notification := range notifications {
+			if err := r.Notifier.Notify(notification); err != nil {
+				log.Printf("notifier: reminder for appointment %d: %v\n", appointment.ID, err)
+				continue
+			}
+
+			sent = true
+		}
+
+		if !sent {
+			continue
+		}
+
+		if err := r.AppointmentRepository.MarkReminderSent(appointment.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriberBufferSize is the number of messages a slow subscriber can fall
+// behind before new messages are dropped for it
+const subscriberBufferSize = 16
+
+// Broker is an in-process publish/subscribe hub used to fan out events to
+// GraphQL subscriptions
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan interface{}]struct{}
+}
+
+// NewBroker ...
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[string]map[chan interface{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives every message published to any
+// of the given topics. The subscription is removed and the channel closed
+// once ctx is done.
+func (b *Broker) Subscribe(ctx context.Context, topics ...string) <-chan interface{} {
+	ch := make(chan interface{}, subscriberBufferSize)
+
+	b.mu.Lock()
+	for _, topic := range topics {
+		if b.subscribers[topic] == nil {
+			b.subscribers[topic] = make(map[chan interface{}]struct{})
+		}
+		b.subscribers[topic][ch] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		for _, topic := range topics {
+			delete(b.subscribers[topic], ch)
+			if len(b.subscribers[topic]) == 0 {
+				delete(b.subscribers, topic)
+			}
+		}
+		b.mu.Unlock()
+
+		close(ch)
+	}()
+
+	return ch
+}
+
+// Publish sends msg to every subscriber of topic without blocking. Messages
+// are dropped for subscribers whose buffer is full.
+func (b *Broker) Publish(topic string, msg interface{}) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers[topic] {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
@@ -15,12 +15,22 @@
   You should have received a copy of the GNU General Public License
   along with this program.  If not, see <http://www.gnu.org/licenses/>.
 */
-
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tensoremr/server/pkg/chartlock"
 	"github.com/tensoremr/server/pkg/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 type AmendmentRepository struct {
@@ -31,29 +41,343 @@ func ProvideAmendmentRepository(DB *gorm.DB) AmendmentRepository {
 	return AmendmentRepository{DB: DB}
 }
 
-// Create ...
+// WithContext ...
+func (r *AmendmentRepository) WithContext(ctx context.Context) *AmendmentRepository {
+	return &AmendmentRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// amendableEntities are the patient chart entities structured amendments can change
+var amendableEntities = map[string]func() interface{}{
+	"PatientChart":        func() interface{} { return &models.PatientChart{} },
+	"VitalSigns":          func() interface{} { return &models.VitalSigns{} },
+	"OpthalmologyExam":    func() interface{} { return &models.OpthalmologyExam{} },
+	"PhysicalExamFinding": func() interface{} { return &models.PhysicalExamFinding{} },
+	"ChiefComplaint":      func() interface{} { return &models.ChiefComplaint{} },
+	"PatientDiagnosis":    func() interface{} { return &models.PatientDiagnosis{} },
+	"VisualAcuity":        func() interface{} { return &models.VisualAcuity{} },
+	"AutoRefraction":      func() interface{} { return &models.AutoRefraction{} },
+	"Iop":                 func() interface{} { return &models.Iop{} },
+	"Pupils":              func() interface{} { return &models.Pupils{} },
+	"ExternalExam":        func() interface{} { return &models.ExternalExam{} },
+	"OcularMotility":      func() interface{} { return &models.OcularMotility{} },
+	"CoverTest":           func() interface{} { return &models.CoverTest{} },
+	"SlitLampExam":        func() interface{} { return &models.SlitLampExam{} },
+	"Funduscopy":          func() interface{} { return &models.Funduscopy{} },
+	"OpticDisc":           func() interface{} { return &models.OpticDisc{} },
+	"DiagnosticProcedure": func() interface{} { return &models.DiagnosticProcedure{} },
+	"Lab":                 func() interface{} { return &models.Lab{} },
+	"SurgicalProcedure":   func() interface{} { return &models.SurgicalProcedure{} },
+	"Treatment":           func() interface{} { return &models.Treatment{} },
+}
+
+// unamendableColumns tie records together or are maintained by the system
+var unamendableColumns = map[string]bool{
+	"patient_chart_id":     true,
+	"appointment_id":       true,
+	"old_patient_chart_id": true,
+	"locked":               true,
+	"locked_date":          true,
+	"locked_by_id":         true,
+	"created_at":           true,
+	"updated_at":           true,
+	"deleted_at":           true,
+	"document":             true,
+	"count":                true,
+}
+
+// Create saves an amendment. Structured amendments record the current value of the
+// field they change and are applied right away unless they require a co-signature
 func (r *AmendmentRepository) Create(m *models.Amendment) error {
-	return r.DB.Create(&m).Error
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var patientChart models.PatientChart
+		if err := tx.Where("id = ?", m.PatientChartID).Take(&patientChart).Error; err != nil {
+			return err
+		}
+
+		// Free-text addenda don't change the chart, so they only wait for a co-signature if asked to
+		if m.EntityType == nil {
+			if m.RequiresCosign {
+				m.Status = models.AmendmentPendingCosign
+			} else {
+				now := time.Now()
+				m.Status = models.AmendmentApplied
+				m.AppliedAt = &now
+			}
+
+			return tx.Create(&m).Error
+		}
+
+		if m.EntityID == nil || m.Field == nil {
+			return errors.New("Amendments of a chart entity need the entity id and field")
+		}
+
+		if len(strings.TrimSpace(m.Reason)) == 0 {
+			return errors.New("Amendments of a chart entity need a reason")
+		}
+
+		model, field, err := amendableField(tx, *m.EntityType, *m.Field)
+		if err != nil {
+			return err
+		}
+
+		if _, err := parseAmendedValue(field, m.NewValue); err != nil {
+			return err
+		}
+
+		oldValue, err := amendedEntityValue(tx, model, field, *m.EntityType, *m.EntityID, m.PatientChartID)
+		if err != nil {
+			return err
+		}
+
+		m.OldValue = oldValue
+		m.Status = models.AmendmentPendingCosign
+
+		if err := tx.Create(&m).Error; err != nil {
+			return err
+		}
+
+		if m.RequiresCosign {
+			return nil
+		}
+
+		return applyAmendment(tx, m)
+	})
+}
+
+// Cosign signs off a pending amendment as a second physician and applies it
+func (r *AmendmentRepository) Cosign(m *models.Amendment, ID int, userID int) error {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", ID).Take(&m).Error; err != nil {
+			return err
+		}
+
+		if m.Status != models.AmendmentPendingCosign || !m.RequiresCosign {
+			return errors.New("This amendment is not waiting for a co-signature")
+		}
+
+		if m.AuthorID != nil && *m.AuthorID == userID {
+			return errors.New("Amendments must be co-signed by someone other than their author")
+		}
+
+		now := time.Now()
+		m.CosignedByID = &userID
+		m.CosignedAt = &now
+
+		if err := tx.Model(&models.Amendment{}).Where("id = ?", m.ID).Updates(map[string]interface{}{"cosigned_by_id": userID, "cosigned_at": now}).Error; err != nil {
+			return err
+		}
+
+		if m.EntityType == nil {
+			return markApplied(tx, m)
+		}
+
+		return applyAmendment(tx, m)
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.Get(m, ID)
 }
 
 // Get ...
 func (r *AmendmentRepository) Get(m *models.Amendment, ID int) error {
-	return r.DB.Where("id = ?", ID).Take(&m).Error
+	return r.DB.Where("id = ?", ID).Preload("Author").Preload("CosignedBy").Take(&m).Error
 }
 
 // GetAll ...
 func (r *AmendmentRepository) GetAll(filter *models.Amendment) ([]*models.Amendment, error) {
 	var result []*models.Amendment
-	err := r.DB.Where(filter).Order("id ASC").Find(&result).Error
+	err := r.DB.Where(filter).Preload("Author").Preload("CosignedBy").Order("id ASC").Find(&result).Error
 	return result, err
 }
 
-// Update ...
+// Update changes the note of an amendment that is still waiting for a
+// co-signature. Applied amendments are part of the chart's record
 func (r *AmendmentRepository) Update(m *models.Amendment) error {
-	return r.DB.Updates(&m).Error
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var amendment models.Amendment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", m.ID).Take(&amendment).Error; err != nil {
+			return err
+		}
+
+		if amendment.Status != models.AmendmentPendingCosign {
+			return errors.New("Applied amendments cannot be changed")
+		}
+
+		return tx.Model(&models.Amendment{}).Where("id = ?", m.ID).Updates(&models.Amendment{Note: m.Note}).Error
+	})
 }
 
-// Delete ...
+// Delete withdraws an amendment that has not been applied yet
 func (r *AmendmentRepository) Delete(ID int) error {
+	var amendment models.Amendment
+	if err := r.DB.Where("id = ?", ID).Take(&amendment).Error; err != nil {
+		return err
+	}
+
+	if amendment.Status != models.AmendmentPendingCosign {
+		return errors.New("Applied amendments cannot be deleted")
+	}
+
 	return r.DB.Where("id = ?", ID).Delete(&models.Amendment{}).Error
 }
+
+// applyAmendment writes the new value of a structured amendment to its entity. It fails
+// if the field no longer holds the value the amendment was written against
+func applyAmendment(tx *gorm.DB, m *models.Amendment) error {
+	model, field, err := amendableField(tx, *m.EntityType, *m.Field)
+	if err != nil {
+		return err
+	}
+
+	current, err := amendedEntityValue(tx, model, field, *m.EntityType, *m.EntityID, m.PatientChartID)
+	if err != nil {
+		return err
+	}
+
+	if !equalValues(current, m.OldValue) {
+		return errors.New("The amended field has changed since this amendment was written")
+	}
+
+	value, err := parseAmendedValue(field, m.NewValue)
+	if err != nil {
+		return err
+	}
+
+	if err := chartlock.Bypass(tx).Model(model).Where("id = ?", *m.EntityID).Update(field.DBName, value).Error; err != nil {
+		return err
+	}
+
+	return markApplied(tx, m)
+}
+
+// markApplied records that an amendment has been applied
+func markApplied(tx *gorm.DB, m *models.Amendment) error {
+	now := time.Now()
+	m.Status = models.AmendmentApplied
+	m.AppliedAt = &now
+
+	return tx.Model(&models.Amendment{}).Where("id = ?", m.ID).Updates(map[string]interface{}{"status": m.Status, "applied_at": now}).Error
+}
+
+// amendableField returns a new value of the model named entityType and its field
+// whose json name or column is name
+func amendableField(tx *gorm.DB, entityType string, name string) (interface{}, *schema.Field, error) {
+	newModel, ok := amendableEntities[entityType]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s cannot be amended", entityType)
+	}
+
+	model := newModel()
+
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return nil, nil, err
+	}
+
+	for _, field := range stmt.Schema.Fields {
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if len(field.DBName) == 0 || (jsonName != name && field.DBName != name) {
+			continue
+		}
+
+		if field.PrimaryKey || unamendableColumns[field.DBName] {
+			break
+		}
+
+		switch field.IndirectFieldType.Kind() {
+		case reflect.String, reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return model, field, nil
+		case reflect.Struct:
+			if field.IndirectFieldType == reflect.TypeOf(time.Time{}) {
+				return model, field, nil
+			}
+		}
+
+		break
+	}
+
+	return nil, nil, fmt.Errorf("%s of %s cannot be amended", name, entityType)
+}
+
+// amendedEntityValue loads an entity of a patient chart and returns the value of field
+func amendedEntityValue(tx *gorm.DB, model interface{}, field *schema.Field, entityType string, entityID int, patientChartID int) (*string, error) {
+	if err := tx.Where("id = ?", entityID).Take(model).Error; err != nil {
+		return nil, err
+	}
+
+	v := reflect.ValueOf(model).Elem()
+
+	chartID := entityID
+	if entityType != "PatientChart" {
+		value, _ := field.Schema.LookUpField("patient_chart_id").ValueOf(v)
+		chartID = int(reflect.ValueOf(value).Int())
+	}
+
+	if chartID != patientChartID {
+		return nil, fmt.Errorf("%s %d does not belong to this patient chart", entityType, entityID)
+	}
+
+	value, zero := field.ValueOf(v)
+	if zero && field.FieldType.Kind() == reflect.Ptr {
+		return nil, nil
+	}
+
+	value = reflect.Indirect(reflect.ValueOf(value)).Interface()
+
+	var s string
+	if t, ok := value.(time.Time); ok {
+		s = t.Format(time.RFC3339)
+	} else {
+		s = fmt.Sprint(value)
+	}
+
+	return &s, nil
+}
+
+// parseAmendedValue converts the new value of an amendment to the type of field
+func parseAmendedValue(field *schema.Field, value *string) (interface{}, error) {
+	if value == nil {
+		if field.FieldType.Kind() != reflect.Ptr {
+			return nil, fmt.Errorf("%s cannot be empty", field.Name)
+		}
+
+		return nil, nil
+	}
+
+	var parsed interface{}
+	var err error
+
+	switch field.IndirectFieldType.Kind() {
+	case reflect.String:
+		parsed = *value
+	case reflect.Bool:
+		parsed, err = strconv.ParseBool(*value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err = strconv.ParseInt(*value, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err = strconv.ParseUint(*value, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		parsed, err = strconv.ParseFloat(*value, 64)
+	default:
+		parsed, err = time.Parse(time.RFC3339, *value)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid value for %s", *value, field.Name)
+	}
+
+	return parsed, nil
+}
+
+func equalValues(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
@@ -19,6 +19,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -38,14 +39,31 @@ func ProvideAppointmentRepository(DB *gorm.DB, appointmentStatusRepository Appoi
 	return AppointmentRepository{DB: DB, AppointmentStatusRepository: appointmentStatusRepository}
 }
 
+// WithContext ...
+func (r *AppointmentRepository) WithContext(ctx context.Context) *AppointmentRepository {
+	return &AppointmentRepository{DB: r.DB.WithContext(ctx), AppointmentStatusRepository: r.AppointmentStatusRepository}
+}
+
 // Save ...
 func (r *AppointmentRepository) Save(m *models.Appointment) error {
 	return r.DB.Create(&m).Error
 }
 
-// CreateNewAppointment ... Creates a new appointment along with PatientChart
-func (r *AppointmentRepository) CreateNewAppointment(m *models.Appointment, billingID *int, invoiceNo *string) error {
+// ErrProviderFullyBooked is returned when booking an appointment beyond the
+// provider's encounter limit and overbook allowance
+var ErrProviderFullyBooked = errors.New("Provider is fully booked on this day")
+
+// maxAvailabilityDays bounds the date range of provider availability queries
+const maxAvailabilityDays = 92
+
+// CreateNewAppointment ... Creates a new appointment along with PatientChart. Bookings beyond the provider's
+// encounter limit are rejected unless overrideCapacity is set, in which case the appointment is marked as an override.
+func (r *AppointmentRepository) CreateNewAppointment(m *models.Appointment, billingID *int, invoiceNo *string, overrideCapacity bool) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkProviderCapacity(tx, m, 0, overrideCapacity); err != nil {
+			return err
+		}
+
 		var sickVisit models.VisitType
 		if err := tx.Where("title = ?", "Sick Visit").Take(&sickVisit).Error; err != nil {
 			return err
@@ -106,67 +124,108 @@ func (r *AppointmentRepository) CreateNewAppointment(m *models.Appointment, bill
 	})
 }
 
-// SchedulePostOp ...
-func (r *AppointmentRepository) SchedulePostOp(m *models.Appointment, appointment models.Appointment) error {
-	return r.DB.Transaction(func(tx *gorm.DB) error {
+// SchedulePostOp schedules the post-op visits of a surgery appointment following the post-op protocols of its
+// surgical procedure types. Surgeries without a protocol get a single post-op visit the next day.
+func (r *AppointmentRepository) SchedulePostOp(surgery models.Appointment) ([]models.Appointment, error) {
+	var appointments []models.Appointment
 
-		var room models.Room
-		if err := tx.Where("title = ?", "Post-Op Room").Take(&room).Error; err != nil {
-			room.Title = "Post-Op Room"
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var status models.AppointmentStatus
+		if err := tx.Where("title = ?", "Scheduled").Take(&status).Error; err != nil {
+			status.Title = "Scheduled"
 
-			if err := tx.Create(&room).Error; err != nil {
+			if err := tx.Create(&status).Error; err != nil {
 				return err
 			}
 		}
 
-		var visitType models.VisitType
-		if err := tx.Where("title = ?", "Post-Op").Take(&visitType).Error; err != nil {
-			visitType.Title = "Post-Op"
+		var steps []models.PostOpProtocolStep
+		if err := tx.Where("surgical_procedure_type_id IN (?)", tx.Model(&models.SurgicalProcedure{}).Select("surgical_procedure_type_id").Where("patient_chart_id IN (?)", tx.Model(&models.PatientChart{}).Select("id").Where("appointment_id = ?", surgery.ID))).Order(postOpStepOrder).Find(&steps).Error; err != nil {
+			return err
+		}
 
-			if err := tx.Create(&visitType).Error; err != nil {
+		if len(steps) == 0 {
+			step, err := defaultPostOpStep(tx)
+			if err != nil {
 				return err
 			}
+
+			steps = append(steps, step)
 		}
 
-		var status models.AppointmentStatus
-		if err := tx.Where("title = ?", "Scheduled").Take(&status).Error; err != nil {
-			status.Title = "Scheduled"
+		now := time.Now()
+		scheduled := make(map[string]bool)
 
-			if err := tx.Create(&status).Error; err != nil {
+		for _, step := range steps {
+			checkInTime := step.ScheduledAt(now)
+
+			userID := surgery.UserID
+			if step.ProviderRule == models.SpecificPostOpProvider && step.UserID != nil {
+				userID = *step.UserID
+			}
+
+			// Procedures sharing a visit only need it once
+			key := fmt.Sprintf("%s-%d-%d", checkInTime.Format("2006-01-02"), step.VisitTypeID, userID)
+			if scheduled[key] {
+				continue
+			}
+			scheduled[key] = true
+
+			appointment := models.Appointment{
+				PatientID:           surgery.PatientID,
+				UserID:              userID,
+				RoomID:              step.RoomID,
+				VisitTypeID:         step.VisitTypeID,
+				CheckInTime:         checkInTime,
+				AppointmentStatusID: status.ID,
+				Credit:              surgery.Credit,
+				MedicalDepartment:   surgery.MedicalDepartment,
+			}
+
+			if err := tx.Create(&appointment).Error; err != nil {
 				return err
 			}
-		}
 
-		now := time.Now()
-		tomorrow := now.AddDate(0, 0, 1)
-
-		m.CheckInTime = tomorrow
-		m.RoomID = room.ID
-		m.VisitTypeID = visitType.ID
-		m.AppointmentStatus = status
-		m.Credit = appointment.Credit
-		m.MedicalDepartment = appointment.MedicalDepartment
-		m.PatientID = appointment.PatientID
-		m.FirstName = appointment.FirstName
-		m.LastName = appointment.LastName
-		m.PhoneNo = appointment.PhoneNo
-		m.ProviderName = appointment.ProviderName
-		m.UserID = appointment.UserID
+			if err := tx.Create(&models.PatientChart{AppointmentID: appointment.ID}).Error; err != nil {
+				return err
+			}
 
-		if err := tx.Create(&m).Error; err != nil {
-			return err
+			appointments = append(appointments, appointment)
 		}
 
-		patientChart := &models.PatientChart{
-			AppointmentID: m.ID,
+		return nil
+	})
+
+	return appointments, err
+}
+
+// defaultPostOpStep is a post-op visit the day after surgery with the surgeon in the Post-Op Room
+func defaultPostOpStep(tx *gorm.DB) (models.PostOpProtocolStep, error) {
+	var room models.Room
+	if err := tx.Where("title = ?", "Post-Op Room").Take(&room).Error; err != nil {
+		room.Title = "Post-Op Room"
+
+		if err := tx.Create(&room).Error; err != nil {
+			return models.PostOpProtocolStep{}, err
 		}
+	}
 
-		if err := tx.Create(&patientChart).Error; err != nil {
-			return err
+	var visitType models.VisitType
+	if err := tx.Where("title = ?", "Post-Op").Take(&visitType).Error; err != nil {
+		visitType.Title = "Post-Op"
+
+		if err := tx.Create(&visitType).Error; err != nil {
+			return models.PostOpProtocolStep{}, err
 		}
+	}
 
-		return nil
-	})
+	return models.PostOpProtocolStep{
+		Delay:        1,
+		DelayUnit:    models.DayPostOpDelay,
+		VisitTypeID:  visitType.ID,
+		RoomID:       room.ID,
+		ProviderRule: models.SurgeonPostOpProvider,
+	}, nil
 }
 
 // GetAll ...
@@ -573,11 +632,243 @@ func (r *AppointmentRepository) PhysicianHomeStats(userId int) (int, int, int, e
 	return len(appointments), checkedIn, checkedOut, nil
 }
 
+// FindDueReminders returns scheduled appointments between now and before whose patients have not been reminded
+func (r *AppointmentRepository) FindDueReminders(before time.Time) ([]models.Appointment, error) {
+	var result []models.Appointment
+
+	err := r.DB.Where("check_in_time > ?", time.Now()).Where("check_in_time <= ?", before).Where("reminder_sent_at IS NULL").Where("appointment_status_id IN (?)", r.DB.Model(&models.AppointmentStatus{}).Select("id").Where("title = ?", "Scheduled")).Preload("Patient").Order("check_in_time ASC").Find(&result).Error
+
+	return result, err
+}
+
+// MarkReminderSent ...
+func (r *AppointmentRepository) MarkReminderSent(ID int) error {
+	return r.DB.Model(&models.Appointment{}).Where("id = ?", ID).Update("reminder_sent_at", time.Now()).Error
+}
+
+// MarkNoShows changes scheduled appointments before the given time that were never checked in to No-Show, and
+// adds them to the no-show count of their patients. It returns the number of appointments marked.
+func (r *AppointmentRepository) MarkNoShows(before time.Time) (int, error) {
+	var marked int
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var scheduled models.AppointmentStatus
+		if err := tx.Where("title = ?", "Scheduled").Take(&scheduled).Error; err != nil {
+			return err
+		}
+
+		var noShow models.AppointmentStatus
+		if err := tx.Where("title = ?", "No-Show").Take(&noShow).Error; err != nil {
+			noShow.Title = "No-Show"
+
+			if err := tx.Create(&noShow).Error; err != nil {
+				return err
+			}
+		}
+
+		var appointments []models.Appointment
+		if err := tx.Select("id, patient_id, check_in_time").Where("appointment_status_id = ?", scheduled.ID).Where("check_in_time < ?", before).Where("checked_in_time IS NULL").Find(&appointments).Error; err != nil {
+			return err
+		}
+
+		if len(appointments) == 0 {
+			return nil
+		}
+
+		ids := make([]int, len(appointments))
+		noShows := make(map[int]int)
+		lastNoShows := make(map[int]time.Time)
+
+		for i, appointment := range appointments {
+			ids[i] = appointment.ID
+			noShows[appointment.PatientID]++
+
+			if appointment.CheckInTime.After(lastNoShows[appointment.PatientID]) {
+				lastNoShows[appointment.PatientID] = appointment.CheckInTime
+			}
+		}
+
+		if err := tx.Model(&models.Appointment{}).Where("id IN ?", ids).Update("appointment_status_id", noShow.ID).Error; err != nil {
+			return err
+		}
+
+		for patientID, count := range noShows {
+			if err := tx.Model(&models.Patient{}).Where("id = ?", patientID).Updates(map[string]interface{}{
+				"no_show_count":   gorm.Expr("no_show_count + ?", count),
+				"last_no_show_at": lastNoShows[patientID],
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		marked = len(appointments)
+
+		return nil
+	})
+
+	return marked, err
+}
+
+// NoShowStats returns how many of a patient's past appointments were no-shows
+func (r *AppointmentRepository) NoShowStats(patientID int) (*models.NoShowStats, error) {
+	var noShow models.AppointmentStatus
+	if err := r.DB.Where("title = ?", "No-Show").Take(&noShow).Error; err != nil {
+		return &models.NoShowStats{}, nil
+	}
+
+	var total int64
+	if err := r.DB.Model(&models.Appointment{}).Where("patient_id = ?", patientID).Where("check_in_time < ?", time.Now()).Where("appointment_status_id NOT IN (?)", r.DB.Model(&models.AppointmentStatus{}).Select("id").Where("title = ?", "Cancelled")).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var noShows []models.Appointment
+	if err := r.DB.Select("id, check_in_time").Where("patient_id = ?", patientID).Where("appointment_status_id = ?", noShow.ID).Order("check_in_time DESC").Find(&noShows).Error; err != nil {
+		return nil, err
+	}
+
+	stats := models.NoShowStats{
+		TotalAppointments: int(total),
+		NoShows:           len(noShows),
+	}
+
+	if total > 0 {
+		stats.NoShowRate = float64(len(noShows)) / float64(total)
+	}
+
+	if len(noShows) > 0 {
+		stats.LastNoShowAt = &noShows[0].CheckInTime
+	}
+
+	return &stats, nil
+}
+
 // Update ...
 func (r *AppointmentRepository) Update(m *models.Appointment) error {
 	return r.DB.Updates(&m).Error
 }
 
+// IssueTicket gives a checked in appointment the next ticket number of its check-in day.
+// Numbers start at 1 every day
+func (r *AppointmentRepository) IssueTicket(m *models.Appointment) error {
+	checkedIn := time.Now()
+	if m.CheckedInTime != nil {
+		checkedIn = *m.CheckedInTime
+	}
+
+	var ticketNumber int
+	if err := r.DB.Raw("INSERT INTO ticket_counters (day, last_number) VALUES (?, 1) ON CONFLICT (day) DO UPDATE SET last_number = ticket_counters.last_number + 1 RETURNING last_number", checkedIn.Format("2006-01-02")).Scan(&ticketNumber).Error; err != nil {
+		return err
+	}
+
+	m.TicketNumber = &ticketNumber
+
+	return r.DB.Model(&models.Appointment{}).Where("id = ?", m.ID).Update("ticket_number", ticketNumber).Error
+}
+
+// Reschedule updates an appointment moved to another provider or day, checking the provider's capacity
+// on the new day the same way as CreateNewAppointment
+func (r *AppointmentRepository) Reschedule(m *models.Appointment, overrideCapacity bool) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkProviderCapacity(tx, m, m.ID, overrideCapacity); err != nil {
+			return err
+		}
+
+		return tx.Updates(&m).Error
+	})
+}
+
+// ProviderAvailability returns the booked and remaining appointments of a provider for each day from from to to
+func (r *AppointmentRepository) ProviderAvailability(userID int, from time.Time, to time.Time) ([]*models.ProviderAvailability, error) {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, from.Location()).AddDate(0, 0, 1)
+
+	if !end.After(start) {
+		return nil, errors.New("End date must not be before start date")
+	}
+
+	if end.Sub(start) > maxAvailabilityDays*24*time.Hour {
+		return nil, fmt.Errorf("Date range cannot exceed %d days", maxAvailabilityDays)
+	}
+
+	return providerAvailability(r.DB, userID, start, end, 0)
+}
+
+// checkProviderCapacity rejects m if its provider is fully booked on the day of its check-in time. Emergency
+// appointments are always allowed. The appointment with id excludeID is not counted as booked.
+// It must run in the transaction that saves m: bookings of the same provider and day are serialized with
+// an advisory lock held until that transaction ends, so concurrent bookings can't both take the last slot.
+func checkProviderCapacity(tx *gorm.DB, m *models.Appointment, excludeID int, overrideCapacity bool) error {
+	if m.UserID == 0 || m.CheckInTime.IsZero() || (m.Emergency != nil && *m.Emergency) {
+		return nil
+	}
+
+	checkInTime := m.CheckInTime
+	start := time.Date(checkInTime.Year(), checkInTime.Month(), checkInTime.Day(), 0, 0, 0, 0, checkInTime.Location())
+	end := start.AddDate(0, 0, 1)
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?), ?::int)", "provider_capacity:"+start.Format("2006-01-02"), m.UserID).Error; err != nil {
+		return err
+	}
+
+	availability, err := providerAvailability(tx, m.UserID, start, end, excludeID)
+	if err != nil {
+		return err
+	}
+
+	if len(availability) == 0 || availability[0].Remaining == nil || *availability[0].Remaining > 0 {
+		return nil
+	}
+
+	if !overrideCapacity {
+		return ErrProviderFullyBooked
+	}
+
+	m.CapacityOverride = true
+
+	return nil
+}
+
+// providerAvailability counts the appointments of a provider that are not cancelled for each day from start
+// up to end, which must both be at midnight
+func providerAvailability(tx *gorm.DB, userID int, start time.Time, end time.Time, excludeID int) ([]*models.ProviderAvailability, error) {
+	var limit models.PatientEncounterLimit
+	hasLimit := tx.Where("user_id = ?", userID).Take(&limit).Error == nil
+
+	var checkInTimes []time.Time
+	if err := tx.Model(&models.Appointment{}).Where("user_id = ?", userID).Where("check_in_time >= ?", start).Where("check_in_time < ?", end).Where("id <> ?", excludeID).Where("appointment_status_id NOT IN (?)", tx.Model(&models.AppointmentStatus{}).Select("id").Where("title = ?", "Cancelled")).Pluck("check_in_time", &checkInTimes).Error; err != nil {
+		return nil, err
+	}
+
+	booked := make(map[string]int)
+	for _, checkInTime := range checkInTimes {
+		booked[checkInTime.In(start.Location()).Format("2006-01-02")]++
+	}
+
+	var result []*models.ProviderAvailability
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		availability := &models.ProviderAvailability{
+			Date:   day,
+			Booked: booked[day.Format("2006-01-02")],
+		}
+
+		if hasLimit {
+			dailyLimit := limit.DailyLimit(day.Weekday())
+			remaining := dailyLimit + limit.Overbook - availability.Booked
+			if remaining < 0 {
+				remaining = 0
+			}
+
+			availability.Limit = &dailyLimit
+			availability.Overbook = limit.Overbook
+			availability.Remaining = &remaining
+		}
+
+		result = append(result, availability)
+	}
+
+	return result, nil
+}
+
 // Delete ...
 func (r *AppointmentRepository) Delete(ID int) error {
 	return r.DB.Where("id = ?", ID).Delete(&models.Appointment{}).Error
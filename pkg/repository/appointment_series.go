@@ -0,0 +1,282 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+)
+
+type AppointmentSeriesRepository struct {
+	DB *gorm.DB
+}
+
+func ProvideAppointmentSeriesRepository(DB *gorm.DB) AppointmentSeriesRepository {
+	return AppointmentSeriesRepository{DB: DB}
+}
+
+// WithContext ...
+func (r *AppointmentSeriesRepository) WithContext(ctx context.Context) *AppointmentSeriesRepository {
+	return &AppointmentSeriesRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// Save creates the series along with an Appointment and PatientChart for each of its occurrences. Occurrences
+// beyond the provider's encounter limit are rejected unless overrideCapacity is set.
+func (r *AppointmentSeriesRepository) Save(m *models.AppointmentSeries, overrideCapacity bool) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	dates := m.Dates()
+	if len(dates) == 0 {
+		return errors.New("Appointment series has no occurrences")
+	}
+
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var status models.AppointmentStatus
+		if err := tx.Where("title = ?", "Scheduled").Take(&status).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Omit("Appointments").Create(&m).Error; err != nil {
+			return err
+		}
+
+		for _, date := range dates {
+			appointment := models.Appointment{
+				PatientID:           m.PatientID,
+				UserID:              m.UserID,
+				RoomID:              m.RoomID,
+				VisitTypeID:         m.VisitTypeID,
+				MedicalDepartment:   m.MedicalDepartment,
+				Credit:              m.Credit,
+				CheckInTime:         date,
+				AppointmentStatusID: status.ID,
+				AppointmentSeriesID: &m.ID,
+			}
+
+			if err := checkProviderCapacity(tx, &appointment, 0, overrideCapacity); err != nil {
+				return err
+			}
+
+			if err := tx.Create(&appointment).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Create(&models.PatientChart{AppointmentID: appointment.ID}).Error; err != nil {
+				return err
+			}
+
+			m.Appointments = append(m.Appointments, appointment)
+		}
+
+		return nil
+	})
+}
+
+// Get ...
+func (r *AppointmentSeriesRepository) Get(m *models.AppointmentSeries, ID int) error {
+	return r.DB.Where("id = ?", ID).Preload("Patient").Preload("Appointments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("check_in_time ASC")
+	}).Preload("Appointments.AppointmentStatus").Take(&m).Error
+}
+
+// UpdateFollowing applies changes to the scheduled occurrences of a series from appointmentID onwards. The check-in
+// times of those occurrences are shifted by the change in the check-in time of appointmentID, and zero fields of
+// changes are left as is. When earlier occurrences exist the series is split, and the updated occurrences move to
+// a new series, which is returned.
+func (r *AppointmentSeriesRepository) UpdateFollowing(appointmentID int, changes models.Appointment, overrideCapacity bool) (*models.AppointmentSeries, error) {
+	var target models.AppointmentSeries
+	var updated []models.Appointment
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		appointment, series, err := findSeriesOccurrence(tx, appointmentID)
+		if err != nil {
+			return err
+		}
+
+		var status models.AppointmentStatus
+		if err := tx.Where("title = ?", "Scheduled").Take(&status).Error; err != nil {
+			return err
+		}
+
+		var following []models.Appointment
+		if err := tx.Where("appointment_series_id = ?", series.ID).Where("check_in_time >= ?", appointment.CheckInTime).Where("appointment_status_id = ?", status.ID).Order("check_in_time ASC").Find(&following).Error; err != nil {
+			return err
+		}
+
+		if len(following) == 0 {
+			return errors.New("Appointment series has no scheduled occurrences from this appointment")
+		}
+
+		var delta time.Duration
+		if !changes.CheckInTime.IsZero() {
+			delta = changes.CheckInTime.Sub(appointment.CheckInTime)
+		}
+
+		earlier, err := truncateSeries(tx, series, appointment.CheckInTime)
+		if err != nil {
+			return err
+		}
+
+		target = *series
+		if earlier > 0 {
+			occurrences := len(following)
+			target = models.AppointmentSeries{
+				PatientID:         series.PatientID,
+				UserID:            series.UserID,
+				RoomID:            series.RoomID,
+				VisitTypeID:       series.VisitTypeID,
+				MedicalDepartment: series.MedicalDepartment,
+				Credit:            series.Credit,
+				StartTime:         appointment.CheckInTime,
+				Frequency:         series.Frequency,
+				Interval:          series.Interval,
+				Occurrences:       &occurrences,
+			}
+		}
+
+		target.StartTime = target.StartTime.Add(delta)
+
+		if changes.UserID != 0 {
+			target.UserID = changes.UserID
+		}
+
+		if changes.RoomID != 0 {
+			target.RoomID = changes.RoomID
+		}
+
+		if changes.VisitTypeID != 0 {
+			target.VisitTypeID = changes.VisitTypeID
+		}
+
+		if err := tx.Omit("Appointments", "Patient").Save(&target).Error; err != nil {
+			return err
+		}
+
+		var provider models.User
+		if err := tx.Where("id = ?", target.UserID).Take(&provider).Error; err != nil {
+			return errors.New("Cannot find provider")
+		}
+
+		for _, e := range following {
+			occurrence := e
+			occurrence.CheckInTime = occurrence.CheckInTime.Add(delta)
+			occurrence.UserID = target.UserID
+			occurrence.RoomID = target.RoomID
+			occurrence.VisitTypeID = target.VisitTypeID
+			occurrence.ProviderName = provider.FirstName + " " + provider.LastName
+			occurrence.AppointmentSeriesID = &target.ID
+
+			if err := checkProviderCapacity(tx, &occurrence, occurrence.ID, overrideCapacity); err != nil {
+				return err
+			}
+
+			if err := tx.Model(&occurrence).Select("CheckInTime", "UserID", "RoomID", "VisitTypeID", "ProviderName", "AppointmentSeriesID", "CapacityOverride").Updates(&occurrence).Error; err != nil {
+				return err
+			}
+
+			updated = append(updated, occurrence)
+		}
+
+		return nil
+	})
+
+	target.Appointments = updated
+
+	return &target, err
+}
+
+// CancelFollowing cancels the scheduled occurrences of a series from appointmentID onwards and ends the series
+// before it
+func (r *AppointmentSeriesRepository) CancelFollowing(appointmentID int) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		appointment, series, err := findSeriesOccurrence(tx, appointmentID)
+		if err != nil {
+			return err
+		}
+
+		var scheduled models.AppointmentStatus
+		if err := tx.Where("title = ?", "Scheduled").Take(&scheduled).Error; err != nil {
+			return err
+		}
+
+		var cancelled models.AppointmentStatus
+		if err := tx.Where("title = ?", "Cancelled").Take(&cancelled).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Appointment{}).Where("appointment_series_id = ?", series.ID).Where("check_in_time >= ?", appointment.CheckInTime).Where("appointment_status_id = ?", scheduled.ID).Update("appointment_status_id", cancelled.ID).Error; err != nil {
+			return err
+		}
+
+		earlier, err := truncateSeries(tx, series, appointment.CheckInTime)
+		if err != nil {
+			return err
+		}
+
+		if earlier == 0 {
+			return tx.Where("id = ?", series.ID).Delete(&models.AppointmentSeries{}).Error
+		}
+
+		return nil
+	})
+}
+
+// findSeriesOccurrence loads an appointment and the series it belongs to
+func findSeriesOccurrence(tx *gorm.DB, appointmentID int) (*models.Appointment, *models.AppointmentSeries, error) {
+	var appointment models.Appointment
+	if err := tx.Where("id = ?", appointmentID).Take(&appointment).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if appointment.AppointmentSeriesID == nil {
+		return nil, nil, errors.New("Appointment is not part of a series")
+	}
+
+	var series models.AppointmentSeries
+	if err := tx.Where("id = ?", *appointment.AppointmentSeriesID).Take(&series).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return &appointment, &series, nil
+}
+
+// truncateSeries ends a series with its last occurrence before checkInTime and returns the number of occurrences
+// left in it. The series is left unchanged when there are none.
+func truncateSeries(tx *gorm.DB, series *models.AppointmentSeries, checkInTime time.Time) (int, error) {
+	var earlier int64
+	if err := tx.Model(&models.Appointment{}).Where("appointment_series_id = ?", series.ID).Where("check_in_time < ?", checkInTime).Count(&earlier).Error; err != nil {
+		return 0, err
+	}
+
+	if earlier == 0 {
+		return 0, nil
+	}
+
+	occurrences := int(earlier)
+	series.Occurrences = &occurrences
+	series.Until = nil
+
+	return occurrences, tx.Model(series).Select("Occurrences", "Until").Updates(series).Error
+}
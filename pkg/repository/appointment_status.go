@@ -37,6 +37,7 @@ func (r *AppointmentStatusRepository) Seed() {
 	r.DB.Create(&models.AppointmentStatus{Title: "Checked-In"})
 	r.DB.Create(&models.AppointmentStatus{Title: "Checked-Out"})
 	r.DB.Create(&models.AppointmentStatus{Title: "Cancelled"})
+	r.DB.Create(&models.AppointmentStatus{Title: "No-Show"})
 }
 
 // Save ...
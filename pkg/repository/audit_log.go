@@ -0,0 +1,73 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repository
+
+import (
+	"time"
+
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+)
+
+// AuditLogRepository is append-only; audit logs are never updated or deleted
+type AuditLogRepository struct {
+	DB *gorm.DB
+}
+
+func ProvideAuditLogRepository(DB *gorm.DB) AuditLogRepository {
+	return AuditLogRepository{DB: DB}
+}
+
+// Save ...
+func (r *AuditLogRepository) Save(m *models.AuditLog) error {
+	return r.DB.Create(&m).Error
+}
+
+// BatchSave ...
+func (r *AuditLogRepository) BatchSave(logs []models.AuditLog) error {
+	return r.DB.Create(&logs).Error
+}
+
+// GetAll ...
+func (r *AuditLogRepository) GetAll(p models.PaginationInput, filter *models.AuditLog, startDate *time.Time, endDate *time.Time) ([]models.AuditLog, int64, error) {
+	var result []models.AuditLog
+
+	dbOp := r.DB.Scopes(models.Paginate(&p)).Select("*, count(*) OVER() AS count").Where(filter)
+
+	if startDate != nil {
+		dbOp.Where("created_at >= ?", *startDate)
+	}
+
+	if endDate != nil {
+		dbOp.Where("created_at <= ?", *endDate)
+	}
+
+	dbOp.Order("id DESC").Find(&result)
+
+	var count int64
+	if len(result) > 0 {
+		count = result[0].Count
+	}
+
+	if dbOp.Error != nil {
+		return result, 0, dbOp.Error
+	}
+
+	return result, count, dbOp.Error
+}
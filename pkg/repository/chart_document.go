@@ -0,0 +1,61 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repository
+
+import (
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+)
+
+type ChartDocumentRepository struct {
+	DB *gorm.DB
+}
+
+func ProvideChartDocumentRepository(DB *gorm.DB) ChartDocumentRepository {
+	return ChartDocumentRepository{DB: DB}
+}
+
+// Save creates the document with its file and attaches the file to the appointment
+func (r *ChartDocumentRepository) Save(m *models.ChartDocument) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&m).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Appointment{ID: m.AppointmentID}).Association("Files").Append(&m.File)
+	})
+}
+
+// Get ...
+func (r *ChartDocumentRepository) Get(m *models.ChartDocument, ID int) error {
+	return r.DB.Where("id = ?", ID).Preload("File").Preload("IssuedBy").Take(&m).Error
+}
+
+// GetByVerificationCode ...
+func (r *ChartDocumentRepository) GetByVerificationCode(m *models.ChartDocument, code string) error {
+	return r.DB.Where("verification_code = ?", code).Preload("File").Preload("IssuedBy").Take(&m).Error
+}
+
+// GetByPatientChart returns the documents generated from a patient chart, newest first
+func (r *ChartDocumentRepository) GetByPatientChart(patientChartID int) ([]*models.ChartDocument, error) {
+	var result []*models.ChartDocument
+	err := r.DB.Where("patient_chart_id = ?", patientChartID).Preload("File").Preload("IssuedBy").Order("id DESC").Find(&result).Error
+
+	return result, err
+}
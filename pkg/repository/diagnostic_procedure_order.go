@@ -19,13 +19,11 @@
 package repository
 
 import (
-	"encoding/json"
-	"fmt"
-	"strings"
+	"context"
 	"time"
 
+	"github.com/tensoremr/server/pkg/chartlock"
 	"github.com/tensoremr/server/pkg/models"
-	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -37,6 +35,11 @@ func ProvideDiagnosticProcedureOrderRepository(DB *gorm.DB) DiagnosticProcedureO
 	return DiagnosticProcedureOrderRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *DiagnosticProcedureOrderRepository) WithContext(ctx context.Context) *DiagnosticProcedureOrderRepository {
+	return &DiagnosticProcedureOrderRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // Save ...
 func (r *DiagnosticProcedureOrderRepository) Save(m *models.DiagnosticProcedureOrder, diagnosticProcedureTypeID int, patientChartID int, patientID int, billingID int, user models.User, orderNote string, receptionNote string) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
@@ -164,7 +167,7 @@ func (r *DiagnosticProcedureOrderRepository) GetPatientDiagnosticProcedureTitles
 
 // Confirm ...
 func (r *DiagnosticProcedureOrderRepository) Confirm(m *models.DiagnosticProcedureOrder, id int, invoiceNo string) error {
-	return r.DB.Transaction(func(tx *gorm.DB) error {
+	return chartlock.Bypass(r.DB).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Where("id = ?", id).Preload("DiagnosticProcedures.Payments").Take(&m).Error; err != nil {
 			return err
 		}
@@ -199,44 +202,10 @@ func (r *DiagnosticProcedureOrderRepository) Confirm(m *models.DiagnosticProcedu
 		for _, diagnosticProcedure := range m.DiagnosticProcedures {
 			var patientQueue models.PatientQueue
 
-			// Create new patient queue if it doesn't exists
-			if err := tx.Where("queue_name = ?", diagnosticProcedure.DiagnosticProcedureTypeTitle).Take(&patientQueue).Error; err != nil {
-				patientQueue.QueueName = diagnosticProcedure.DiagnosticProcedureTypeTitle
-				patientQueue.QueueType = models.DiagnosticQueue
-				patientQueue.Queue = datatypes.JSON([]byte("[" + fmt.Sprint(patientChart.AppointmentID) + "]"))
-
-				if err := tx.Create(&patientQueue).Error; err != nil {
-					return err
-				}
-			} else {
-				var ids []int
-				if err := json.Unmarshal([]byte(patientQueue.Queue.String()), &ids); err != nil {
-					return err
-				}
-
-				exists := false
-				for _, e := range ids {
-					if e == patientChart.AppointmentID {
-						exists = true
-					}
-				}
-
-				if exists {
-					return nil
-				}
-
-				ids = append(ids, patientChart.AppointmentID)
-
-				var appointmentIds []string
-				for _, v := range ids {
-					appointmentIds = append(appointmentIds, fmt.Sprint(v))
-				}
-
-				queue := datatypes.JSON([]byte("[" + strings.Join(appointmentIds, ", ") + "]"))
-
-				if err := tx.Where("queue_name = ?", diagnosticProcedure.DiagnosticProcedureTypeTitle).Updates(&models.PatientQueue{Queue: queue}).Error; err != nil {
-					return err
-				}
+			// Creates the patient queue if it doesn't exist
+			patientQueueRepository := PatientQueueRepository{DB: tx}
+			if err := patientQueueRepository.AddToQueue(&patientQueue, diagnosticProcedure.DiagnosticProcedureTypeTitle, patientChart.AppointmentID, string(models.DiagnosticQueue), nil); err != nil {
+				return err
 			}
 		}
 
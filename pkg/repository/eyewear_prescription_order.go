@@ -19,6 +19,7 @@
 package repository
 
 import (
+	"context"
 	"time"
 
 	"github.com/tensoremr/server/pkg/models"
@@ -33,6 +34,11 @@ func ProvideEyewearPrescriptionOrderRepository(DB *gorm.DB) EyewearPrescriptionO
 	return EyewearPrescriptionOrderRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *EyewearPrescriptionOrderRepository) WithContext(ctx context.Context) *EyewearPrescriptionOrderRepository {
+	return &EyewearPrescriptionOrderRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // SaveEyewearPrescription ...
 func (r *EyewearPrescriptionOrderRepository) SaveEyewearPrescription(m *models.EyewearPrescriptionOrder, eyewearPrescription models.EyewearPrescription, patientID int) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
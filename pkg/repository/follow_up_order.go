@@ -19,8 +19,10 @@
 package repository
 
 import (
+	"context"
 	"time"
 
+	"github.com/tensoremr/server/pkg/chartlock"
 	"github.com/tensoremr/server/pkg/models"
 	"gorm.io/gorm"
 )
@@ -33,6 +35,11 @@ func ProvideFollowUpOrderRepository(DB *gorm.DB) FollowUpOrderRepository {
 	return FollowUpOrderRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *FollowUpOrderRepository) WithContext(ctx context.Context) *FollowUpOrderRepository {
+	return &FollowUpOrderRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // Save ...
 func (r *FollowUpOrderRepository) Save(m *models.FollowUpOrder, patientChartID int, patientID int, user models.User, receptionNote string) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
@@ -110,7 +117,7 @@ func (r *FollowUpOrderRepository) GetTodaysOrderedCount() (count int) {
 
 // ConfirmOrder ...
 func (r *FollowUpOrderRepository) ConfirmOrder(m *models.FollowUpOrder, followUpOrderID int, followUpID int, billingID *int, invoiceNo *string, roomID int, checkInTime time.Time) error {
-	return r.DB.Transaction(func(tx *gorm.DB) error {
+	return chartlock.Bypass(r.DB).Transaction(func(tx *gorm.DB) error {
 		var followUp models.FollowUp
 		if err := tx.Where("id = ?", followUpID).Take(&followUp).Error; err != nil {
 			return err
@@ -19,6 +19,7 @@
 package repository
 
 import (
+	"github.com/tensoremr/server/pkg/chartlock"
 	"github.com/tensoremr/server/pkg/models"
 	"gorm.io/gorm"
 )
@@ -61,7 +62,7 @@ func (r *LabRepository) GetAll(p models.PaginationInput, filter *models.Lab) ([]
 
 // Update ...
 func (r *LabRepository) Update(m *models.Lab) error {
-	return r.DB.Updates(&m).Preload("Images").Preload("Documents").Error
+	return chartlock.Bypass(r.DB).Updates(&m).Preload("Images").Preload("Documents").Error
 }
 
 // DeleteFile ...
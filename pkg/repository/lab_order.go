@@ -19,13 +19,11 @@
 package repository
 
 import (
-	"encoding/json"
-	"fmt"
-	"strings"
+	"context"
 	"time"
 
+	"github.com/tensoremr/server/pkg/chartlock"
 	"github.com/tensoremr/server/pkg/models"
-	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -37,6 +35,11 @@ func ProvideLabOrderRepository(DB *gorm.DB) LabOrderRepository {
 	return LabOrderRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *LabOrderRepository) WithContext(ctx context.Context) *LabOrderRepository {
+	return &LabOrderRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // NewOrder ...
 func (r *LabOrderRepository) Save(m *models.LabOrder, labTypeID int, patientChartID int, patientID int, billingIds []int, user models.User, orderNote string, receptionNote string) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
@@ -122,7 +125,7 @@ func (r *LabOrderRepository) GetTodaysOrderedCount() (count int) {
 
 // Confirm ...
 func (r *LabOrderRepository) Confirm(m *models.LabOrder, id int, invoiceNo string) error {
-	return r.DB.Transaction(func(tx *gorm.DB) error {
+	return chartlock.Bypass(r.DB).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Where("id = ?", id).Preload("Labs.Payments").Take(&m).Error; err != nil {
 			return err
 		}
@@ -156,44 +159,10 @@ func (r *LabOrderRepository) Confirm(m *models.LabOrder, id int, invoiceNo strin
 		for _, lab := range m.Labs {
 			var patientQueue models.PatientQueue
 
-			// Create new patient queue if it doesn't exists
-			if err := tx.Where("queue_name = ?", lab.LabTypeTitle).Take(&patientQueue).Error; err != nil {
-				patientQueue.QueueName = lab.LabTypeTitle
-				patientQueue.QueueType = models.LabQueue
-				patientQueue.Queue = datatypes.JSON([]byte("[" + fmt.Sprint(patientChart.AppointmentID) + "]"))
-
-				if err := tx.Create(&patientQueue).Error; err != nil {
-					return err
-				}
-			} else {
-				var ids []int
-				if err := json.Unmarshal([]byte(patientQueue.Queue.String()), &ids); err != nil {
-					return err
-				}
-
-				exists := false
-				for _, e := range ids {
-					if e == patientChart.AppointmentID {
-						exists = true
-					}
-				}
-
-				if exists {
-					return nil
-				}
-
-				ids = append(ids, patientChart.AppointmentID)
-
-				var appointmentIds []string
-				for _, v := range ids {
-					appointmentIds = append(appointmentIds, fmt.Sprint(v))
-				}
-
-				queue := datatypes.JSON([]byte("[" + strings.Join(appointmentIds, ", ") + "]"))
-
-				if err := tx.Where("queue_name = ?", lab.LabTypeTitle).Updates(&models.PatientQueue{Queue: queue}).Error; err != nil {
-					return err
-				}
+			// Creates the patient queue if it doesn't exist
+			patientQueueRepository := PatientQueueRepository{DB: tx}
+			if err := patientQueueRepository.AddToQueue(&patientQueue, lab.LabTypeTitle, patientChart.AppointmentID, string(models.LabQueue), nil); err != nil {
+				return err
 			}
 		}
 
@@ -19,6 +19,7 @@
 package repository
 
 import (
+	"context"
 	"time"
 
 	"github.com/tensoremr/server/pkg/models"
@@ -33,6 +34,11 @@ func ProvideMedicalPrescriptionOrderRepository(DB *gorm.DB) MedicalPrescriptionO
 	return MedicalPrescriptionOrderRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *MedicalPrescriptionOrderRepository) WithContext(ctx context.Context) *MedicalPrescriptionOrderRepository {
+	return &MedicalPrescriptionOrderRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // SaveMedicalPrescription ...
 func (r *MedicalPrescriptionOrderRepository) SaveMedicalPrescription(m *models.MedicalPrescriptionOrder, medicalPrescription models.MedicalPrescription, patientID int) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
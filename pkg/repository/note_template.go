@@ -0,0 +1,207 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repository
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/util"
+	"gorm.io/gorm"
+)
+
+type NoteTemplateRepository struct {
+	DB *gorm.DB
+}
+
+func ProvideNoteTemplateRepository(DB *gorm.DB) NoteTemplateRepository {
+	return NoteTemplateRepository{DB: DB}
+}
+
+// notePlaceholder matches a placeholder like {{patientName}} in a template body
+var notePlaceholder = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// Save ...
+func (r *NoteTemplateRepository) Save(m *models.NoteTemplate) error {
+	return r.DB.Create(&m).Error
+}
+
+// Get ...
+func (r *NoteTemplateRepository) Get(m *models.NoteTemplate, ID int) error {
+	return r.DB.Where("id = ?", ID).Preload("User").Take(&m).Error
+}
+
+// GetForUser returns the global templates and the templates of the user,
+// optionally narrowed down to a section and specialty
+func (r *NoteTemplateRepository) GetForUser(userID int, section *models.NoteTemplateSection, specialty *string) ([]*models.NoteTemplate, error) {
+	var result []*models.NoteTemplate
+
+	dbOp := r.DB.Where("user_id IS NULL OR user_id = ?", userID)
+
+	if section != nil {
+		dbOp = dbOp.Where("section = ?", *section)
+	}
+
+	if specialty != nil {
+		dbOp = dbOp.Where("specialty ILIKE ?", *specialty)
+	}
+
+	err := dbOp.Preload("User").Order("user_id IS NULL, title ASC").Find(&result).Error
+
+	return result, err
+}
+
+// Update ...
+func (r *NoteTemplateRepository) Update(m *models.NoteTemplate) error {
+	return r.DB.Updates(&m).Error
+}
+
+// Delete ...
+func (r *NoteTemplateRepository) Delete(ID int) error {
+	return r.DB.Where("id = ?", ID).Delete(&models.NoteTemplate{}).Error
+}
+
+// Render fills in the placeholders of the template body from a patient chart.
+// Placeholders with no value in the chart are left blank and unknown
+// placeholders are kept as they are
+func (r *NoteTemplateRepository) Render(m *models.NoteTemplate, patientChartID int) (string, error) {
+	values, err := r.placeholderValues(patientChartID)
+	if err != nil {
+		return "", err
+	}
+
+	return notePlaceholder.ReplaceAllStringFunc(m.Body, func(placeholder string) string {
+		name := notePlaceholder.FindStringSubmatch(placeholder)[1]
+
+		value, ok := values[name]
+		if !ok {
+			return placeholder
+		}
+
+		return value
+	}), nil
+}
+
+// placeholderValues returns the value of every supported placeholder for a
+// patient chart. IOP and visual acuity are the latest recorded for the patient
+// up to the chart's visit
+func (r *NoteTemplateRepository) placeholderValues(patientChartID int) (map[string]string, error) {
+	var patientChart models.PatientChart
+	if err := r.DB.Where("id = ?", patientChartID).Take(&patientChart).Error; err != nil {
+		return nil, err
+	}
+
+	var appointment models.Appointment
+	if err := r.DB.Where("id = ?", patientChart.AppointmentID).Preload("Patient").Take(&appointment).Error; err != nil {
+		return nil, err
+	}
+
+	patient := appointment.Patient
+
+	values := map[string]string{
+		"patientName":      strings.TrimSpace(patient.FirstName + " " + patient.LastName),
+		"patientFirstName": patient.FirstName,
+		"patientLastName":  patient.LastName,
+		"patientGender":    patient.Gender,
+		"provider":         appointment.ProviderName,
+		"visitDate":        appointment.CheckInTime.Format("Jan 2, 2006"),
+	}
+
+	if !patient.DateOfBirth.IsZero() {
+		values["patientAge"] = strconv.Itoa(util.AgeAt(patient.DateOfBirth, appointment.CheckInTime))
+	}
+
+	var diagnoses []models.PatientDiagnosis
+	if err := r.DB.Where("patient_chart_id = ? AND differential = ?", patientChartID, false).Order("id ASC").Find(&diagnoses).Error; err != nil {
+		return nil, err
+	}
+
+	var descriptions, eyes []string
+	for _, diagnosis := range diagnoses {
+		description := diagnosis.FullDescription
+		if len(diagnosis.Location) > 0 {
+			description = fmt.Sprintf("%s (%s)", description, diagnosis.Location)
+
+			if !contains(eyes, diagnosis.Location) {
+				eyes = append(eyes, diagnosis.Location)
+			}
+		}
+
+		descriptions = append(descriptions, description)
+	}
+
+	values["diagnoses"] = strings.Join(descriptions, ", ")
+	values["eye"] = strings.Join(eyes, " and ")
+
+	var iop models.Iop
+	err := r.latestForPatient("iops", appointment, "iops.right_iop <> '' OR iops.left_iop <> ''").Take(&iop).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	values["rightIop"] = stringValue(iop.RightIop)
+	values["leftIop"] = stringValue(iop.LeftIop)
+
+	var visualAcuity models.VisualAcuity
+	err = r.latestForPatient("visual_acuities", appointment, "visual_acuities.right_distance_uncorrected <> '' OR visual_acuities.left_distance_uncorrected <> ''").Take(&visualAcuity).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	values["rightVa"] = stringValue(visualAcuity.RightDistanceUncorrected)
+	values["leftVa"] = stringValue(visualAcuity.LeftDistanceUncorrected)
+	values["rightVaCorrected"] = stringValue(visualAcuity.RightDistanceCorrected)
+	values["leftVaCorrected"] = stringValue(visualAcuity.LeftDistanceCorrected)
+
+	return values, nil
+}
+
+// latestForPatient selects the most recent row of a patient chart table for
+// the appointment's patient, up to the appointment, that matches condition
+func (r *NoteTemplateRepository) latestForPatient(table string, appointment models.Appointment, condition string) *gorm.DB {
+	return r.DB.Table(table).
+		Select(table+".*").
+		Joins("JOIN patient_charts ON patient_charts.id = "+table+".patient_chart_id").
+		Joins("JOIN appointments ON appointments.id = patient_charts.appointment_id").
+		Where("appointments.patient_id = ? AND appointments.check_in_time <= ?", appointment.PatientID, appointment.CheckInTime).
+		Where(table + ".deleted_at IS NULL").
+		Where("(" + condition + ")").
+		Order("appointments.check_in_time DESC")
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -19,6 +19,8 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/tensoremr/server/pkg/models"
 	"gorm.io/gorm"
 )
@@ -31,6 +33,11 @@ func ProvideOpthalmologyExamRepository(DB *gorm.DB) OpthalmologyExamRepository {
 	return OpthalmologyExamRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *OpthalmologyExamRepository) WithContext(ctx context.Context) *OpthalmologyExamRepository {
+	return &OpthalmologyExamRepository{DB: r.DB.WithContext(ctx)}
+}
+
 
 // Save ...
 func (r *OpthalmologyExamRepository) Save(m *models.OpthalmologyExam) error {
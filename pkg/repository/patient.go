@@ -19,10 +19,15 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/lib/pq"
 	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/util"
 	"gorm.io/gorm"
 )
 
@@ -34,6 +39,11 @@ func ProvidePatientRepository(DB *gorm.DB) PatientRepository {
 	return PatientRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *PatientRepository) WithContext(ctx context.Context) *PatientRepository {
+	return &PatientRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // Save ...
 func (r *PatientRepository) Save(m *models.Patient) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
@@ -216,6 +226,155 @@ func (r *PatientRepository) FindByPhoneNo(phoneNo string) ([]*models.Patient, er
 	return patients, nil
 }
 
+// Weights used to score potential duplicate patients
+const (
+	duplicateIDNoWeight     = 0.4
+	duplicateCardNoWeight   = 0.4
+	duplicatePhoneWeight    = 0.25
+	duplicateBirthWeight    = 0.2
+	duplicateNameWeight     = 0.4
+	duplicateNameSimilarity = 0.8
+	duplicateThreshold      = 0.5
+	duplicateLimit          = 20
+)
+
+var nonDigit = regexp.MustCompile(`\D`)
+
+// FindDuplicates returns patients that are likely the same person as the
+// patient with the given id, ordered by descending score
+func (r *PatientRepository) FindDuplicates(patientID int) ([]*models.PatientDuplicate, error) {
+	var patient models.Patient
+	if err := r.DB.Where("id = ?", patientID).Take(&patient).Error; err != nil {
+		return nil, err
+	}
+
+	firstName := strings.ToLower(strings.TrimSpace(patient.FirstName))
+	lastName := strings.ToLower(strings.TrimSpace(patient.LastName))
+
+	dbOp := r.DB.Where("id <> ?", patient.ID)
+
+	conditions := r.DB.Where("lower(left(trim(first_name), 2)) = left(?, 2) AND lower(left(trim(last_name), 2)) = left(?, 2)", firstName, lastName).
+		Or("lower(left(trim(first_name), 2)) = left(?, 2) AND lower(left(trim(last_name), 2)) = left(?, 2)", lastName, firstName)
+
+	if idNo := strings.TrimSpace(patient.IDNo); len(idNo) > 0 {
+		conditions = conditions.Or("lower(trim(id_no)) = lower(?)", idNo)
+	}
+
+	if cardNo := strings.TrimSpace(patient.CardNo); len(cardNo) > 0 {
+		conditions = conditions.Or("trim(card_no) = ?", cardNo)
+	}
+
+	if phones := patientPhones(&patient); len(phones) > 0 {
+		for _, column := range []string{"phone_no", "phone_no2", "home_phone"} {
+			conditions = conditions.Or("right(regexp_replace("+column+", '\\D', '', 'g'), 9) IN ?", phones)
+		}
+	}
+
+	if !patient.DateOfBirth.IsZero() {
+		conditions = conditions.Or("date(date_of_birth) = date(?) AND (lower(left(trim(first_name), 1)) = left(?, 1) OR lower(left(trim(last_name), 1)) = left(?, 1))", patient.DateOfBirth, firstName, lastName)
+	}
+
+	var candidates []models.Patient
+	if err := dbOp.Where(conditions).Limit(500).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	var duplicates []*models.PatientDuplicate
+	for i := range candidates {
+		duplicate := scoreDuplicate(&patient, &candidates[i])
+		if duplicate.Score >= duplicateThreshold {
+			duplicates = append(duplicates, duplicate)
+		}
+	}
+
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].Score > duplicates[j].Score
+	})
+
+	if len(duplicates) > duplicateLimit {
+		duplicates = duplicates[:duplicateLimit]
+	}
+
+	return duplicates, nil
+}
+
+// scoreDuplicate rates how likely candidate is the same person as patient
+func scoreDuplicate(patient *models.Patient, candidate *models.Patient) *models.PatientDuplicate {
+	duplicate := &models.PatientDuplicate{Patient: candidate, Reasons: []string{}}
+
+	if idNo := strings.TrimSpace(patient.IDNo); len(idNo) > 0 && strings.EqualFold(idNo, strings.TrimSpace(candidate.IDNo)) {
+		duplicate.Score += duplicateIDNoWeight
+		duplicate.Reasons = append(duplicate.Reasons, "Same ID number")
+	}
+
+	if cardNo := strings.TrimSpace(patient.CardNo); len(cardNo) > 0 && cardNo == strings.TrimSpace(candidate.CardNo) {
+		duplicate.Score += duplicateCardNoWeight
+		duplicate.Reasons = append(duplicate.Reasons, "Same card number")
+	}
+
+	candidatePhones := patientPhones(candidate)
+	samePhone := false
+	for _, phone := range patientPhones(patient) {
+		for _, candidatePhone := range candidatePhones {
+			if phone == candidatePhone {
+				samePhone = true
+			}
+		}
+	}
+
+	if samePhone {
+		duplicate.Score += duplicatePhoneWeight
+		duplicate.Reasons = append(duplicate.Reasons, "Same phone number")
+	}
+
+	if !patient.DateOfBirth.IsZero() && patient.DateOfBirth.Format("2006-01-02") == candidate.DateOfBirth.Format("2006-01-02") {
+		duplicate.Score += duplicateBirthWeight
+		duplicate.Reasons = append(duplicate.Reasons, "Same date of birth")
+	}
+
+	similarity := (util.JaroWinkler(patient.FirstName, candidate.FirstName) + util.JaroWinkler(patient.LastName, candidate.LastName)) / 2
+	swapped := (util.JaroWinkler(patient.FirstName, candidate.LastName) + util.JaroWinkler(patient.LastName, candidate.FirstName)) / 2
+	if swapped > similarity {
+		similarity = swapped
+	}
+
+	if similarity >= duplicateNameSimilarity {
+		duplicate.Score += duplicateNameWeight * similarity
+		if similarity == 1 {
+			duplicate.Reasons = append(duplicate.Reasons, "Same name")
+		} else {
+			duplicate.Reasons = append(duplicate.Reasons, "Similar name")
+		}
+	}
+
+	if duplicate.Score > 1 {
+		duplicate.Score = 1
+	}
+
+	return duplicate
+}
+
+// patientPhones returns the last nine digits of each of the patient's phone
+// numbers, which ignores differences in country code and formatting
+func patientPhones(patient *models.Patient) []string {
+	var phones []string
+
+	for _, phone := range []string{patient.PhoneNo, patient.PhoneNo2, patient.HomePhone} {
+		digits := nonDigit.ReplaceAllString(phone, "")
+		if len(digits) < 7 {
+			continue
+		}
+
+		if len(digits) > 9 {
+			digits = digits[len(digits)-9:]
+		}
+
+		phones = append(phones, digits)
+	}
+
+	return phones
+}
+
 // Update ...
 func (r *PatientRepository) Update(m *models.Patient) error {
 	err := r.DB.Updates(m).Error
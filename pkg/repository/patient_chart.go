@@ -19,6 +19,7 @@
 package repository
 
 import (
+	"context"
 	"time"
 
 	"github.com/tensoremr/server/pkg/models"
@@ -33,6 +34,11 @@ func ProvidePatientChartRepository(DB *gorm.DB) PatientChartRepository {
 	return PatientChartRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *PatientChartRepository) WithContext(ctx context.Context) *PatientChartRepository {
+	return &PatientChartRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // Save ...
 func (r *PatientChartRepository) Save(m *models.PatientChart) error {
 	return r.DB.Create(&m).Error
@@ -76,7 +82,7 @@ func (r *PatientChartRepository) SignAndLock(m *models.PatientChart, patientChar
 
 // Get ...
 func (r *PatientChartRepository) Get(m *models.PatientChart, ID int) error {
-	return r.DB.Where("id = ?", ID).Take(&m).Error
+	return r.DB.Where("id = ?", ID).Scopes(preloadAmendments).Take(&m).Error
 }
 
 // GetByAppointmentID ...
@@ -86,7 +92,14 @@ func (r *PatientChartRepository) GetByAppointmentID(m *models.PatientChart, appo
 
 // Get ...
 func (r *PatientChartRepository) GetWithDetails(m *models.PatientChart, ID int) error {
-	return r.DB.Where("id = ?", ID).Preload("ChiefComplaints.HPIComponents.HpiComponentType").Preload("MedicalPrescriptionOrder.MedicalPrescriptions").Preload("EyewearPrescriptionOrder.EyewearPrescriptions").Preload("VitalSigns").Preload("PhysicalExamFindings.ExamCategory").Preload("OpthalmologyExam").Preload("Diagnoses").Preload("LabOrder.Labs.LabType").Preload("LabOrder.Labs.RightEyeImages").Preload("LabOrder.Labs.LeftEyeImages").Preload("LabOrder.Labs.Documents").Preload("DiagnosticProcedureOrder.DiagnosticProcedures.DiagnosticProcedureType").Preload("DiagnosticProcedureOrder.DiagnosticProcedures.Images").Preload("DiagnosticProcedureOrder.DiagnosticProcedures.Documents").Preload("SurgicalProcedure.SurgicalProcedureType").Preload("Treatment.TreatmentType").Preload("Amendments").Take(&m).Error
+	return r.DB.Where("id = ?", ID).Preload("ChiefComplaints.HPIComponents.HpiComponentType").Preload("MedicalPrescriptionOrder.MedicalPrescriptions").Preload("EyewearPrescriptionOrder.EyewearPrescriptions").Preload("VitalSigns").Preload("PhysicalExamFindings.ExamCategory").Preload("OpthalmologyExam").Preload("Diagnoses").Preload("LabOrder.Labs.LabType").Preload("LabOrder.Labs.RightEyeImages").Preload("LabOrder.Labs.LeftEyeImages").Preload("LabOrder.Labs.Documents").Preload("DiagnosticProcedureOrder.DiagnosticProcedures.DiagnosticProcedureType").Preload("DiagnosticProcedureOrder.DiagnosticProcedures.Images").Preload("DiagnosticProcedureOrder.DiagnosticProcedures.Documents").Preload("SurgicalProcedure.SurgicalProcedureType").Preload("Treatment.TreatmentType").Scopes(preloadAmendments).Take(&m).Error
+}
+
+// preloadAmendments loads the amendment history of a patient chart, oldest first
+func preloadAmendments(db *gorm.DB) *gorm.DB {
+	return db.Preload("Amendments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Preload("Amendments.Author").Preload("Amendments.CosignedBy")
 }
 
 // Update ...
@@ -0,0 +1,381 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/tensoremr/server/pkg/chartlock"
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+)
+
+type PatientMergeRepository struct {
+	DB *gorm.DB
+}
+
+func ProvidePatientMergeRepository(DB *gorm.DB) PatientMergeRepository {
+	return PatientMergeRepository{DB: DB}
+}
+
+// WithContext ...
+func (r *PatientMergeRepository) WithContext(ctx context.Context) *PatientMergeRepository {
+	return &PatientMergeRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// mergedRecord is a table whose rows belong to a patient
+type mergedRecord struct {
+	Table string
+	Model interface{}
+
+	// Denormalized is set for tables that copy the patient's name and phone
+	Denormalized bool
+}
+
+// patientRecords are the tables that reference a patient through patient_id
+var patientRecords = []mergedRecord{
+	{Table: "appointments", Model: &models.Appointment{}, Denormalized: true},
+	{Table: "diagnostic_procedure_orders", Model: &models.DiagnosticProcedureOrder{}, Denormalized: true},
+	{Table: "lab_orders", Model: &models.LabOrder{}, Denormalized: true},
+	{Table: "treatment_orders", Model: &models.TreatmentOrder{}, Denormalized: true},
+	{Table: "surgical_orders", Model: &models.SurgicalOrder{}, Denormalized: true},
+	{Table: "referral_orders", Model: &models.ReferralOrder{}, Denormalized: true},
+	{Table: "follow_up_orders", Model: &models.FollowUpOrder{}, Denormalized: true},
+	{Table: "medical_prescriptions", Model: &models.MedicalPrescription{}},
+	{Table: "eyewear_prescriptions", Model: &models.EyewearPrescription{}},
+	{Table: "payment_waivers", Model: &models.PaymentWaiver{}},
+	{Table: "appointment_series", Model: &models.AppointmentSeries{}},
+	{Table: "waitlist_entries", Model: &models.WaitlistEntry{}},
+}
+
+// historyRecords are the tables that reference a patient's history through
+// patient_history_id
+var historyRecords = []mergedRecord{
+	{Table: "allergies", Model: &models.Allergy{}},
+	{Table: "family_illnesses", Model: &models.FamilyIllness{}},
+	{Table: "lifestyles", Model: &models.Lifestyle{}},
+	{Table: "past_hospitalizations", Model: &models.PastHospitalization{}},
+	{Table: "past_illnesses", Model: &models.PastIllness{}},
+	{Table: "past_injuries", Model: &models.PastInjury{}},
+	{Table: "past_opt_surgeries", Model: &models.PastOptSurgery{}},
+	{Table: "past_surgeries", Model: &models.PastSurgery{}},
+	{Table: "review_of_systems", Model: &models.ReviewOfSystem{}},
+}
+
+// movedRecords is stored with a merge to list the ids of every row that was
+// moved from the merged patient to the survivor, along with what else the
+// merge changed on the survivor
+type movedRecords struct {
+	Patient   map[string][]int `json:"patient"`
+	History   map[string][]int `json:"history"`
+	Documents []int            `json:"documents"`
+
+	// NoShowCount is the merged patient's no-shows added to the survivor's
+	NoShowCount int `json:"noShowCount"`
+
+	// CreatedHistoryID is the history the merge created for a survivor that had none
+	CreatedHistoryID *int `json:"createdHistoryId,omitempty"`
+}
+
+// Merge moves the appointments, history, documents and orders of the merged
+// patient to the survivor, deletes the merged patient and keeps a record of
+// the merge so it can be undone
+func (r *PatientMergeRepository) Merge(survivorID int, mergedID int, userID int) (*models.PatientMerge, error) {
+	if survivorID == mergedID {
+		return nil, errors.New("Cannot merge a patient with itself")
+	}
+
+	var merge models.PatientMerge
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var survivor models.Patient
+		if err := tx.Where("id = ?", survivorID).Take(&survivor).Error; err != nil {
+			return errors.New("Cannot find surviving patient")
+		}
+
+		var merged models.Patient
+		if err := tx.Where("id = ?", mergedID).Take(&merged).Error; err != nil {
+			return errors.New("Cannot find merged patient")
+		}
+
+		moved := movedRecords{
+			Patient:   map[string][]int{},
+			History:   map[string][]int{},
+			Documents: []int{},
+		}
+
+		for _, record := range patientRecords {
+			ids, err := moveRecords(tx, record, "patient_id", merged.ID, survivor.ID, &survivor)
+			if err != nil {
+				return err
+			}
+
+			if len(ids) > 0 {
+				moved.Patient[record.Table] = ids
+			}
+		}
+
+		var mergedHistory models.PatientHistory
+		err := tx.Where("patient_id = ?", merged.ID).Take(&mergedHistory).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err == nil {
+			// The survivor may not have a history yet, e.g. if it was created by an import
+			var survivorHistory models.PatientHistory
+			err := tx.Where("patient_id = ?", survivor.ID).Take(&survivorHistory).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				survivorHistory = models.PatientHistory{PatientID: survivor.ID}
+				err = tx.Create(&survivorHistory).Error
+				moved.CreatedHistoryID = &survivorHistory.ID
+			}
+
+			if err != nil {
+				return err
+			}
+
+			for _, record := range historyRecords {
+				ids, err := moveRecords(tx, record, "patient_history_id", mergedHistory.ID, survivorHistory.ID, nil)
+				if err != nil {
+					return err
+				}
+
+				if len(ids) > 0 {
+					moved.History[record.Table] = ids
+				}
+			}
+		}
+
+		if err := tx.Table("patient_documents").Where("patient_id = ?", merged.ID).Where("file_id NOT IN (?)", tx.Table("patient_documents").Select("file_id").Where("patient_id = ?", survivor.ID)).Pluck("file_id", &moved.Documents).Error; err != nil {
+			return err
+		}
+
+		if len(moved.Documents) > 0 {
+			if err := tx.Table("patient_documents").Where("patient_id = ?", merged.ID).Where("file_id IN ?", moved.Documents).Update("patient_id", survivor.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		if merged.NoShowCount > 0 {
+			if err := tx.Model(&models.Patient{}).Where("id = ?", survivor.ID).Update("no_show_count", gorm.Expr("no_show_count + ?", merged.NoShowCount)).Error; err != nil {
+				return err
+			}
+
+			moved.NoShowCount = merged.NoShowCount
+		}
+
+		if err := tx.Where("id = ?", merged.ID).Delete(&models.Patient{}).Error; err != nil {
+			return err
+		}
+
+		records, err := json.Marshal(moved)
+		if err != nil {
+			return err
+		}
+
+		merge = models.PatientMerge{
+			SurvivorID:   survivor.ID,
+			MergedID:     merged.ID,
+			MergedByID:   userID,
+			MovedRecords: records,
+		}
+
+		return tx.Create(&merge).Error
+	})
+
+	return &merge, err
+}
+
+// Undo moves the records listed in a merge back to the merged patient and
+// restores it
+func (r *PatientMergeRepository) Undo(ID int, userID int) (*models.PatientMerge, error) {
+	var merge models.PatientMerge
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", ID).Take(&merge).Error; err != nil {
+			return err
+		}
+
+		if merge.UndoneAt != nil {
+			return errors.New("Patient merge has already been undone")
+		}
+
+		var moved movedRecords
+		if err := json.Unmarshal(merge.MovedRecords, &moved); err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&models.Patient{}).Where("id = ?", merge.MergedID).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		var merged models.Patient
+		if err := tx.Where("id = ?", merge.MergedID).Take(&merged).Error; err != nil {
+			return errors.New("Cannot find merged patient")
+		}
+
+		for _, record := range patientRecords {
+			if err := restoreRecords(tx, record, "patient_id", moved.Patient[record.Table], merge.SurvivorID, merged.ID, &merged); err != nil {
+				return err
+			}
+		}
+
+		var survivorHistory models.PatientHistory
+		var mergedHistory models.PatientHistory
+		if tx.Where("patient_id = ?", merge.SurvivorID).Take(&survivorHistory).Error == nil && tx.Where("patient_id = ?", merged.ID).Take(&mergedHistory).Error == nil {
+			for _, record := range historyRecords {
+				if err := restoreRecords(tx, record, "patient_history_id", moved.History[record.Table], survivorHistory.ID, mergedHistory.ID, nil); err != nil {
+					return err
+				}
+			}
+
+			// Remove the history the merge created, unless records were added to it since
+			if moved.CreatedHistoryID != nil && *moved.CreatedHistoryID == survivorHistory.ID {
+				used, err := historyHasRecords(tx, survivorHistory.ID)
+				if err != nil {
+					return err
+				}
+
+				if !used {
+					if err := tx.Delete(&survivorHistory).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if moved.NoShowCount > 0 {
+			if err := tx.Model(&models.Patient{}).Where("id = ?", merge.SurvivorID).Update("no_show_count", gorm.Expr("GREATEST(no_show_count - ?, 0)", moved.NoShowCount)).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(moved.Documents) > 0 {
+			if err := tx.Table("patient_documents").Where("patient_id = ?", merge.SurvivorID).Where("file_id IN ?", moved.Documents).Update("patient_id", merged.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		merge.UndoneAt = &now
+		merge.UndoneByID = &userID
+
+		return tx.Model(&merge).Select("UndoneAt", "UndoneByID").Updates(&merge).Error
+	})
+
+	return &merge, err
+}
+
+// moveRecords reassigns every row of record whose column equals from, soft
+// deleted rows included, and returns the ids of the rows it moved. When
+// patient is set, denormalized name and phone columns are updated too.
+func moveRecords(tx *gorm.DB, record mergedRecord, column string, from int, to int, patient *models.Patient) ([]int, error) {
+	var ids []int
+	if err := tx.Unscoped().Model(record.Model).Where(column+" = ?", from).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	// Records of signed charts follow their patient too
+	return ids, chartlock.Bypass(tx).Unscoped().Model(record.Model).Where("id IN ?", ids).Updates(recordChanges(record, column, to, patient)).Error
+}
+
+// historyHasRecords returns true if any row of historyRecords belongs to the history
+func historyHasRecords(tx *gorm.DB, historyID int) (bool, error) {
+	for _, record := range historyRecords {
+		var count int64
+		if err := tx.Unscoped().Model(record.Model).Where("patient_history_id = ?", historyID).Count(&count).Error; err != nil {
+			return false, err
+		}
+
+		if count > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// restoreRecords moves the rows with the given ids back from the survivor,
+// skipping any row that has since been reassigned elsewhere
+func restoreRecords(tx *gorm.DB, record mergedRecord, column string, ids []int, from int, to int, patient *models.Patient) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return chartlock.Bypass(tx).Unscoped().Model(record.Model).Where("id IN ?", ids).Where(column+" = ?", from).Updates(recordChanges(record, column, to, patient)).Error
+}
+
+func recordChanges(record mergedRecord, column string, value int, patient *models.Patient) map[string]interface{} {
+	changes := map[string]interface{}{column: value}
+
+	if record.Denormalized && patient != nil {
+		changes["first_name"] = patient.FirstName
+		changes["last_name"] = patient.LastName
+		changes["phone_no"] = patient.PhoneNo
+	}
+
+	return changes
+}
+
+// Get ...
+func (r *PatientMergeRepository) Get(m *models.PatientMerge, ID int) error {
+	return r.DB.Where("id = ?", ID).Scopes(preloadPatientMerge).Take(&m).Error
+}
+
+// GetAll ...
+func (r *PatientMergeRepository) GetAll(p models.PaginationInput, patientID *int) ([]models.PatientMerge, int64, error) {
+	var result []models.PatientMerge
+
+	dbOp := r.DB.Scopes(models.Paginate(&p)).Select("*, count(*) OVER() AS count")
+
+	if patientID != nil {
+		dbOp.Where("survivor_id = ? OR merged_id = ?", *patientID, *patientID)
+	}
+
+	dbOp.Scopes(preloadPatientMerge).Order("id DESC").Find(&result)
+
+	var count int64
+	if len(result) > 0 {
+		count = result[0].Count
+	}
+
+	if dbOp.Error != nil {
+		return result, 0, dbOp.Error
+	}
+
+	return result, count, dbOp.Error
+}
+
+// preloadPatientMerge loads both patients of a merge, including the merged
+// patient after it has been deleted
+func preloadPatientMerge(db *gorm.DB) *gorm.DB {
+	unscoped := func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}
+
+	return db.Preload("Survivor", unscoped).Preload("Merged", unscoped).Preload("MergedBy").Preload("UndoneBy")
+}
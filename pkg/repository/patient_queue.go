@@ -15,18 +15,18 @@
   You should have received a copy of the GNU General Public License
   along with this program.  If not, see <http://www.gnu.org/licenses/>.
 */
-
 package repository
 
 import (
-	"encoding/json"
-	"fmt"
+	"errors"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/tensoremr/server/pkg/models"
-	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PatientQueueRepository struct {
@@ -39,13 +39,10 @@ func ProvidePatientQueueRepository(DB *gorm.DB) PatientQueueRepository {
 
 // Seed ...
 func (r *PatientQueueRepository) Seed() {
-	emptyQueue := datatypes.JSON([]byte("[]"))
-
-	r.DB.Create(&models.PatientQueue{QueueName: "Pre-Exam", Queue: emptyQueue, QueueType:  models.QueueType("PREEXAM")})
-	r.DB.Create(&models.PatientQueue{QueueName: "Pre-Operation", Queue: emptyQueue, QueueType:  models.QueueType("PREOPERATION")})
+	r.DB.Create(&models.PatientQueue{QueueName: "Pre-Exam", QueueType: models.QueueType("PREEXAM")})
+	r.DB.Create(&models.PatientQueue{QueueName: "Pre-Operation", QueueType: models.QueueType("PREOPERATION")})
 }
 
-
 // Save
 func (r *PatientQueueRepository) Save(m *models.PatientQueue) error {
 	return r.DB.Create(&m).Error
@@ -69,211 +66,189 @@ func (r *PatientQueueRepository) GetByQueueName(m *models.PatientQueue, queueNam
 	return r.DB.Where("queue_name = ?", queueName).Take(&m).Error
 }
 
-// GetByQueueName ...
-func (r *PatientQueueRepository) UpdateQueue(queueName string, queue datatypes.JSON) error {
-	return r.DB.Where("queue_name = ?", queueName).Updates(&models.PatientQueue{Queue: queue}).Error
+// GetAppointmentIDs returns the ids of the appointments in a queue, in queue order
+func (r *PatientQueueRepository) GetAppointmentIDs(patientQueueID int) ([]int, error) {
+	var ids []int
+	err := r.DB.Model(&models.QueueEntry{}).Where("patient_queue_id = ? AND status IN ?", patientQueueID, models.ActiveQueueEntryStatuses).Order("position ASC").Pluck("appointment_id", &ids).Error
+
+	return ids, err
 }
 
-// Move ...
-func (r *PatientQueueRepository) Move(m *models.PatientQueue, fromQueueID int, toQueueID int, appointmentID int) error {
-	return r.DB.Transaction(func(tx *gorm.DB) error {
-		// Get source queue
-		var sourceQueue models.PatientQueue
-		if err := tx.Where("id = ?", fromQueueID).Take(&sourceQueue).Error; err != nil {
-			return err
-		}
+// GetEntries returns the entries still in a queue, in queue order
+func (r *PatientQueueRepository) GetEntries(patientQueueID int) ([]*models.QueueEntry, error) {
+	var result []*models.QueueEntry
+	err := r.DB.Where("patient_queue_id = ? AND status IN ?", patientQueueID, models.ActiveQueueEntryStatuses).Preload("Appointment.Patient").Preload("Room").Order("position ASC").Find(&result).Error
 
-		// Get destination queue
-		var destinationQueue models.PatientQueue
-		if err := tx.Where("id = ?", toQueueID).Take(&destinationQueue).Error; err != nil {
-			return err
-		}
+	return result, err
+}
 
-		// Remove appointment id from source queue
-		var sourceIds []int
-		if err := json.Unmarshal([]byte(sourceQueue.Queue.String()), &sourceIds); err != nil {
-			return err
-		}
+// GetDisplay returns the masked waiting-room view of the given queues. Queues
+// whose type isn't one of models.DisplayQueueTypes are left out
+func (r *PatientQueueRepository) GetDisplay(patientQueueIDs []int) ([]*models.QueueDisplay, error) {
+	var patientQueues []models.PatientQueue
 
-		for i, v := range sourceIds {
-			if v == appointmentID {
-				sourceIds = remove(sourceIds, i)
-			}
-		}
+	if err := r.DB.Where("id IN ? AND queue_type IN ?", patientQueueIDs, models.DisplayQueueTypes).Order("id ASC").Find(&patientQueues).Error; err != nil {
+		return nil, err
+	}
 
-		var sourceAppointmentIds []string
-		for _, v := range sourceIds {
-			sourceAppointmentIds = append(sourceAppointmentIds, fmt.Sprint(v))
-		}
+	result := []*models.QueueDisplay{}
 
-		sourceQueue.Queue = datatypes.JSON([]byte("[" + strings.Join(sourceAppointmentIds, ", ") + "]"))
-		if err := tx.Updates(&models.PatientQueue{ID: sourceQueue.ID, Queue: sourceQueue.Queue}).Error; err != nil {
-			return err
+	for _, patientQueue := range patientQueues {
+		var entries []models.QueueEntry
+		if err := r.DB.Where("patient_queue_id = ? AND status IN ?", patientQueue.ID, models.ActiveQueueEntryStatuses).Preload("Appointment.Patient").Preload("Appointment.Room").Preload("Room").Order("position ASC").Find(&entries).Error; err != nil {
+			return nil, err
 		}
 
-		var destinationIds []int
-		if err := json.Unmarshal([]byte(destinationQueue.Queue.String()), &destinationIds); err != nil {
-			return err
+		display := models.QueueDisplay{
+			PatientQueueID: patientQueue.ID,
+			QueueName:      patientQueue.QueueName,
+			Entries:        []models.QueueDisplayEntry{},
 		}
 
-		// Skip adding if it alread exists
-		exists := false
-		for _, e := range destinationIds {
-			if e == appointmentID {
-				exists = true
+		for i, entry := range entries {
+			room := entry.Appointment.Room.Title
+			if entry.Room != nil {
+				room = entry.Room.Title
 			}
-		}
-
-		if exists {
-			return nil
-		}
 
-		var destinationAppointmentIds []string
-		for _, v := range destinationIds {
-			destinationAppointmentIds = append(destinationAppointmentIds, fmt.Sprint(v))
+			display.Entries = append(display.Entries, models.QueueDisplayEntry{
+				QueueNumber:  i + 1,
+				TicketNumber: entry.Appointment.TicketNumber,
+				Initials:     initials(entry.Appointment.Patient.FirstName, entry.Appointment.Patient.LastName),
+				Room:         room,
+				Status:       entry.Status,
+			})
 		}
 
-		destinationAppointmentIds = append(destinationAppointmentIds, fmt.Sprint(appointmentID))
-		destinationQueue.Queue = datatypes.JSON([]byte("[" + strings.Join(destinationAppointmentIds, ", ") + "]"))
-		if err := tx.Updates(&models.PatientQueue{ID: destinationQueue.ID, Queue: destinationQueue.Queue}).Error; err != nil {
-			return err
-		}
-
-		m = &destinationQueue
+		result = append(result, &display)
+	}
 
-		return nil
-	})
+	return result, nil
 }
 
-// MoveToQueueName ...
-func (r *PatientQueueRepository) MoveToQueueName(fromQueueID int, toQueueName string, appointmentID int, queueType string) error {
+// UpdateQueue replaces the entries of a queue with the given appointments, in order
+func (r *PatientQueueRepository) UpdateQueue(queueName string, appointmentIDs []int, enqueuedByID *int) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
-		// Get source queue
-		var sourceQueue models.PatientQueue
-		if err := tx.Where("id = ?", fromQueueID).Take(&sourceQueue).Error; err != nil {
+		var patientQueue models.PatientQueue
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("queue_name = ?", queueName).Take(&patientQueue).Error; err != nil {
 			return err
 		}
 
-		// Remove appointment id from source queue
-		var sourceIds []int
-		if err := json.Unmarshal([]byte(sourceQueue.Queue.String()), &sourceIds); err != nil {
+		var entries []models.QueueEntry
+		if err := tx.Where("patient_queue_id = ? AND status IN ?", patientQueue.ID, models.ActiveQueueEntryStatuses).Find(&entries).Error; err != nil {
 			return err
 		}
 
-		for i, v := range sourceIds {
-			if v == appointmentID {
-				sourceIds = remove(sourceIds, i)
-			}
+		existing := make(map[int]models.QueueEntry)
+		for _, entry := range entries {
+			existing[entry.AppointmentID] = entry
 		}
 
-		var sourceAppointmentIds []string
-		for _, v := range sourceIds {
-			sourceAppointmentIds = append(sourceAppointmentIds, fmt.Sprint(v))
-		}
+		keep := make(map[int]bool)
+		for i, appointmentID := range appointmentIDs {
+			if keep[appointmentID] {
+				continue
+			}
 
-		sourceQueue.Queue = datatypes.JSON([]byte("[" + strings.Join(sourceAppointmentIds, ", ") + "]"))
-		if err := tx.Updates(&models.PatientQueue{ID: sourceQueue.ID, Queue: sourceQueue.Queue}).Error; err != nil {
-			return err
-		}
+			keep[appointmentID] = true
 
-		// Add appointment to destination queue
-		var destinationQueue models.PatientQueue
+			if entry, ok := existing[appointmentID]; ok {
+				if err := tx.Model(&models.QueueEntry{}).Where("id = ?", entry.ID).Update("position", i+1).Error; err != nil {
+					return err
+				}
 
-		// Check if queue exists, create new one if it doesn't
-		if err := tx.Where("queue_name = ?", toQueueName).Take(&destinationQueue).Error; err != nil {
-			destinationQueue.QueueName = toQueueName
-			destinationQueue.Queue = datatypes.JSON([]byte("[" + fmt.Sprint(appointmentID) + "]"))
+				continue
+			}
 
-			if len(queueType) != 0 {
-				destinationQueue.QueueType = models.QueueType(queueType)
+			entry := models.QueueEntry{
+				PatientQueueID: patientQueue.ID,
+				AppointmentID:  appointmentID,
+				Position:       i + 1,
+				EnqueuedAt:     time.Now(),
+				EnqueuedByID:   enqueuedByID,
+				Status:         models.QueueEntryWaiting,
 			}
 
-			if err := tx.Create(&destinationQueue).Error; err != nil {
+			if err := tx.Create(&entry).Error; err != nil {
 				return err
 			}
-
-			return nil
 		}
 
-		var destinationIds []int
-		if err := json.Unmarshal([]byte(destinationQueue.Queue.String()), &destinationIds); err != nil {
-			return err
-		}
+		for _, entry := range entries {
+			if keep[entry.AppointmentID] {
+				continue
+			}
 
-		// Skip adding if it alread exists
-		exists := false
-		for _, e := range destinationIds {
-			if e == appointmentID {
-				exists = true
+			if err := tx.Model(&models.QueueEntry{}).Where("id = ?", entry.ID).Updates(map[string]interface{}{"status": models.QueueEntryRemoved, "left_at": time.Now()}).Error; err != nil {
+				return err
 			}
 		}
 
-		if exists {
-			return nil
+		return nil
+	})
+}
+
+// Move ...
+func (r *PatientQueueRepository) Move(m *models.PatientQueue, fromQueueID int, toQueueID int, appointmentID int, enqueuedByID *int) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		queues, err := lockQueues(tx, fromQueueID, toQueueID)
+		if err != nil {
+			return err
 		}
 
-		var destinationAppointmentIds []string
-		for _, v := range destinationIds {
-			destinationAppointmentIds = append(destinationAppointmentIds, fmt.Sprint(v))
+		if err := dequeue(tx, fromQueueID, appointmentID, models.QueueEntryMoved); err != nil {
+			return err
 		}
 
-		destinationAppointmentIds = append(destinationAppointmentIds, fmt.Sprint(appointmentID))
-		destinationQueue.Queue = datatypes.JSON([]byte("[" + strings.Join(destinationAppointmentIds, ", ") + "]"))
-		if err := tx.Updates(&models.PatientQueue{ID: destinationQueue.ID, Queue: destinationQueue.Queue}).Error; err != nil {
+		if err := enqueue(tx, toQueueID, appointmentID, enqueuedByID); err != nil {
 			return err
 		}
 
+		*m = queues[toQueueID]
+
 		return nil
 	})
 }
 
-// AddToQueue
-func (r *PatientQueueRepository) AddToQueue(m *models.PatientQueue, toQueueName string, appointmentID int, queueType string) error {
+// MoveToQueueName ...
+func (r *PatientQueueRepository) MoveToQueueName(fromQueueID int, toQueueName string, appointmentID int, queueType string, enqueuedByID *int) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
-
-		// Check if queue exists, create new one if it doesn't
-		if err := tx.Where("queue_name = ?", toQueueName).Take(&m).Error; err != nil {
-			m.QueueName = toQueueName
-			m.Queue = datatypes.JSON([]byte("[" + fmt.Sprint(appointmentID) + "]"))
-			if len(queueType) != 0 {
-				m.QueueType = models.QueueType(queueType)
-			}
-
-			if err := tx.Create(&m).Error; err != nil {
-				return err
-			}
-
-			return nil
+		destinationQueue, err := findOrCreateQueue(tx, toQueueName, queueType)
+		if err != nil {
+			return err
 		}
 
-		var destinationIds []int
-		if err := json.Unmarshal([]byte(m.Queue.String()), &destinationIds); err != nil {
+		if _, err := lockQueues(tx, fromQueueID, destinationQueue.ID); err != nil {
 			return err
 		}
 
-		// Skip adding if it alread exists
-		exists := false
-		for _, e := range destinationIds {
-			if e == appointmentID {
-				exists = true
-			}
+		if err := dequeue(tx, fromQueueID, appointmentID, models.QueueEntryMoved); err != nil {
+			return err
 		}
 
-		if exists {
-			return nil
+		return enqueue(tx, destinationQueue.ID, appointmentID, enqueuedByID)
+	})
+}
+
+// AddToQueue
+func (r *PatientQueueRepository) AddToQueue(m *models.PatientQueue, toQueueName string, appointmentID int, queueType string, enqueuedByID *int) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		patientQueue, err := findOrCreateQueue(tx, toQueueName, queueType)
+		if err != nil {
+			return err
 		}
 
-		var destinationAppointmentIds []string
-		for _, v := range destinationIds {
-			destinationAppointmentIds = append(destinationAppointmentIds, fmt.Sprint(v))
+		queues, err := lockQueues(tx, patientQueue.ID)
+		if err != nil {
+			return err
 		}
 
-		destinationAppointmentIds = append(destinationAppointmentIds, fmt.Sprint(appointmentID))
-		m.Queue = datatypes.JSON([]byte("[" + strings.Join(destinationAppointmentIds, ", ") + "]"))
-		if err := tx.Updates(&models.PatientQueue{ID: m.ID, Queue: m.Queue}).Error; err != nil {
+		if err := enqueue(tx, patientQueue.ID, appointmentID, enqueuedByID); err != nil {
 			return err
 		}
 
+		*m = queues[patientQueue.ID]
+
 		return nil
 	})
 }
@@ -281,90 +256,436 @@ func (r *PatientQueueRepository) AddToQueue(m *models.PatientQueue, toQueueName
 // DeleteFromQueue ...
 func (r *PatientQueueRepository) DeleteFromQueue(m *models.PatientQueue, patientQueueID int, appointmentID int) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Where("id = ?", patientQueueID).Take(&m).Error; err != nil {
+		queues, err := lockQueues(tx, patientQueueID)
+		if err != nil {
 			return err
 		}
 
-		var ids []int
-		if err := json.Unmarshal([]byte(m.Queue.String()), &ids); err != nil {
+		if err := dequeue(tx, patientQueueID, appointmentID, models.QueueEntryRemoved); err != nil {
+			return err
+		}
+
+		*m = queues[patientQueueID]
+
+		return nil
+	})
+}
+
+// GetEntry ...
+func (r *PatientQueueRepository) GetEntry(m *models.QueueEntry, id int) error {
+	return r.DB.Where("id = ?", id).Preload("Appointment.Patient").Preload("Room").Take(&m).Error
+}
+
+// CallNext calls the patient at the head of a queue to a room and puts them in service
+func (r *PatientQueueRepository) CallNext(m *models.QueueEntry, patientQueueID int, roomID int, userID *int) error {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockQueues(tx, patientQueueID); err != nil {
 			return err
 		}
 
-		for i, v := range ids {
-			if v == appointmentID {
-				ids = remove(ids, i)
+		if err := tx.Where("patient_queue_id = ? AND status = ?", patientQueueID, models.QueueEntryWaiting).Order("position ASC").Take(&m).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("No patients are waiting in this queue")
 			}
+
+			return err
 		}
 
-		var appointmentIds []string
-		for _, v := range ids {
-			appointmentIds = append(appointmentIds, fmt.Sprint(v))
+		return call(tx, m, models.QueueCallCall, &roomID, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.GetEntry(m, m.ID)
+}
+
+// Recall calls a patient that is in service or was skipped again. The patient is called
+// to roomID if it is given, or to the room of the previous call
+func (r *PatientQueueRepository) Recall(m *models.QueueEntry, queueEntryID int, roomID *int, userID *int) error {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockEntry(tx, m, queueEntryID); err != nil {
+			return err
+		}
+
+		if m.Status != models.QueueEntryInService && m.Status != models.QueueEntrySkipped {
+			return errors.New("Only patients that have been called can be recalled")
+		}
+
+		if roomID == nil {
+			roomID = m.RoomID
 		}
 
-		m.Queue = datatypes.JSON([]byte("[" + strings.Join(appointmentIds, ", ") + "]"))
-		if err := tx.Updates(&models.PatientQueue{ID: m.ID, Queue: m.Queue}).Error; err != nil {
+		return call(tx, m, models.QueueCallRecall, roomID, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.GetEntry(m, m.ID)
+}
+
+// Skip takes a called patient that did not show up out of service. They keep their
+// entry in the queue and can be recalled
+func (r *PatientQueueRepository) Skip(m *models.QueueEntry, queueEntryID int, userID *int) error {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockEntry(tx, m, queueEntryID); err != nil {
 			return err
 		}
 
-		return nil
+		if m.Status != models.QueueEntryInService {
+			return errors.New("Only patients in service can be skipped")
+		}
+
+		return call(tx, m, models.QueueCallSkip, m.RoomID, userID)
 	})
+	if err != nil {
+		return err
+	}
+
+	return r.GetEntry(m, m.ID)
 }
 
 // ClearExpired ...
 func (r *PatientQueueRepository) ClearExpired() error {
-	return r.DB.Transaction(func(tx *gorm.DB) error {
-		var patientQueues []models.PatientQueue
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
-		if err := tx.Find(&patientQueues).Error; err != nil {
-			return err
+	expired := r.DB.Model(&models.Appointment{}).Select("id").Where("checked_in_time < ?", start)
+
+	return r.DB.Model(&models.QueueEntry{}).Where("status IN ? AND appointment_id IN (?)", models.ActiveQueueEntryStatuses, expired).Updates(map[string]interface{}{"status": models.QueueEntryExpired, "left_at": now}).Error
+}
+
+// lockQueues takes row locks on the given patient queues. Locks are always
+// taken in id order so that two moves between the same queues in opposite
+// directions wait for each other instead of deadlocking
+func lockQueues(tx *gorm.DB, ids ...int) (map[int]models.PatientQueue, error) {
+	sort.Ints(ids)
+
+	var patientQueues []models.PatientQueue
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Order("id ASC").Find(&patientQueues).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[int]models.PatientQueue)
+	for _, patientQueue := range patientQueues {
+		result[patientQueue.ID] = patientQueue
+	}
+
+	for _, id := range ids {
+		if _, ok := result[id]; !ok {
+			return nil, errors.New("Cannot find patient queue")
 		}
+	}
 
-		for _, patientQueue := range patientQueues {
-			var ids []int
-			if err := json.Unmarshal([]byte(patientQueue.Queue.String()), &ids); err != nil {
-				return err
-			}
+	return result, nil
+}
 
-			now := time.Now()
-			start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+// lockEntry loads a queue entry after locking its queue
+func lockEntry(tx *gorm.DB, m *models.QueueEntry, queueEntryID int) error {
+	if err := tx.Where("id = ?", queueEntryID).Take(&m).Error; err != nil {
+		return err
+	}
 
-			var expiredAppointments []models.Appointment
+	if _, err := lockQueues(tx, m.PatientQueueID); err != nil {
+		return err
+	}
 
-			if err := tx.Model(models.Appointment{}).Select("id").Where("id IN ?", ids).Where("checked_in_time < ?", start).Find(&expiredAppointments).Error; err != nil {
-				return err
-			}
+	// Reload, the entry may have changed while waiting for the lock
+	return tx.Where("id = ?", queueEntryID).Take(&m).Error
+}
 
-			if len(expiredAppointments) != 0 {
-				for index, id := range ids {
-					for _, appointment := range expiredAppointments {
-						if appointment.ID == id {
-							ids = remove(ids, index)
-						}
-					}
-				}
-			}
+// call records a call, recall or skip of a queue entry and updates its status
+func call(tx *gorm.DB, m *models.QueueEntry, action models.QueueCallAction, roomID *int, userID *int) error {
+	queueCall := models.QueueCall{
+		QueueEntryID:   m.ID,
+		PatientQueueID: m.PatientQueueID,
+		AppointmentID:  m.AppointmentID,
+		Action:         action,
+		RoomID:         roomID,
+		UserID:         userID,
+	}
 
-			var appointmentIds []string
-			for _, v := range ids {
-				appointmentIds = append(appointmentIds, fmt.Sprint(v))
-			}
+	if err := tx.Create(&queueCall).Error; err != nil {
+		return err
+	}
 
-			queue := datatypes.JSON([]byte("[" + strings.Join(appointmentIds, ", ") + "]"))
-			patientQueue.Queue = queue
+	updates := map[string]interface{}{"room_id": roomID}
 
-			if err := tx.Updates(&patientQueue).Error; err != nil {
-				return err
-			}
+	if action == models.QueueCallSkip {
+		updates["status"] = models.QueueEntrySkipped
+	} else {
+		updates["status"] = models.QueueEntryInService
+		if m.CalledAt == nil {
+			updates["called_at"] = time.Now()
 		}
+	}
+
+	return tx.Model(&models.QueueEntry{}).Where("id = ?", m.ID).Updates(updates).Error
+}
+
+// findOrCreateQueue returns the queue with the given name, creating it if it doesn't exist
+func findOrCreateQueue(tx *gorm.DB, queueName string, queueType string) (models.PatientQueue, error) {
+	patientQueue := models.PatientQueue{QueueName: queueName}
+	if len(queueType) != 0 {
+		patientQueue.QueueType = models.QueueType(queueType)
+	}
 
+	if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "queue_name"}}, DoNothing: true}).Create(&patientQueue).Error; err != nil {
+		return patientQueue, err
+	}
+
+	err := tx.Where("queue_name = ?", queueName).Take(&patientQueue).Error
+
+	return patientQueue, err
+}
+
+// enqueue appends an appointment to the end of a locked queue, unless it is already in it
+func enqueue(tx *gorm.DB, patientQueueID int, appointmentID int, enqueuedByID *int) error {
+	var count int64
+	if err := tx.Model(&models.QueueEntry{}).Where("patient_queue_id = ? AND appointment_id = ? AND status IN ?", patientQueueID, appointmentID, models.ActiveQueueEntryStatuses).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
 		return nil
+	}
+
+	var position int
+	if err := tx.Model(&models.QueueEntry{}).Select("COALESCE(MAX(position), 0)").Where("patient_queue_id = ? AND status IN ?", patientQueueID, models.ActiveQueueEntryStatuses).Scan(&position).Error; err != nil {
+		return err
+	}
+
+	entry := models.QueueEntry{
+		PatientQueueID: patientQueueID,
+		AppointmentID:  appointmentID,
+		Position:       position + 1,
+		EnqueuedAt:     time.Now(),
+		EnqueuedByID:   enqueuedByID,
+		Status:         models.QueueEntryWaiting,
+	}
+
+	return tx.Create(&entry).Error
+}
+
+// dequeue takes an appointment out of a locked queue, recording why it left
+func dequeue(tx *gorm.DB, patientQueueID int, appointmentID int, status models.QueueEntryStatus) error {
+	return tx.Model(&models.QueueEntry{}).Where("patient_queue_id = ? AND appointment_id = ? AND status IN ?", patientQueueID, appointmentID, models.ActiveQueueEntryStatuses).Updates(map[string]interface{}{"status": status, "left_at": time.Now()}).Error
+}
+
+// QueueMetrics returns the wait and service times of every patient queue and
+// provider for each day from from to to. Only entries that left their queue
+// normally are counted; entries cleared by ClearExpired are skipped
+func (r *PatientQueueRepository) QueueMetrics(from time.Time, to time.Time) (*models.QueueMetrics, error) {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, from.Location()).AddDate(0, 0, 1)
+
+	if !end.After(start) {
+		return nil, errors.New("End date must not be before start date")
+	}
+
+	var rows []struct {
+		PatientQueueID int
+		AppointmentID  int
+		EnqueuedAt     time.Time
+		CalledAt       *time.Time
+		LeftAt         time.Time
+		UserID         int
+		CheckedInTime  *time.Time
+		CheckedOutTime time.Time
+	}
+
+	err := r.DB.Table("queue_entries").
+		Select("queue_entries.patient_queue_id, queue_entries.appointment_id, queue_entries.enqueued_at, queue_entries.called_at, queue_entries.left_at, appointments.user_id, appointments.checked_in_time, appointments.checked_out_time").
+		Joins("JOIN appointments ON appointments.id = queue_entries.appointment_id").
+		Where("queue_entries.deleted_at IS NULL").
+		Where("queue_entries.status IN ?", []models.QueueEntryStatus{models.QueueEntryMoved, models.QueueEntryRemoved}).
+		Where("queue_entries.enqueued_at >= ? AND queue_entries.enqueued_at < ?", start, end).
+		Order("queue_entries.enqueued_at ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Patients stop waiting once they are called
+	wait := func(enqueuedAt time.Time, calledAt *time.Time, leftAt time.Time) time.Duration {
+		if calledAt != nil && calledAt.Before(leftAt) {
+			return calledAt.Sub(enqueuedAt)
+		}
+
+		return leftAt.Sub(enqueuedAt)
+	}
+
+	day := func(t time.Time) time.Time {
+		t = t.In(from.Location())
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, from.Location())
+	}
+
+	type visit struct {
+		date    time.Time
+		userID  int
+		wait    time.Duration
+		service *time.Duration
+	}
+
+	// Total up the time each visit spent waiting in queues
+	visits := make(map[int]*visit)
+	for _, row := range rows {
+		v, ok := visits[row.AppointmentID]
+		if !ok {
+			v = &visit{date: day(row.EnqueuedAt), userID: row.UserID}
+			visits[row.AppointmentID] = v
+		}
+
+		v.wait += wait(row.EnqueuedAt, row.CalledAt, row.LeftAt)
+
+		if v.service == nil && row.CheckedInTime != nil && row.CheckedOutTime.After(*row.CheckedInTime) {
+			service := row.CheckedOutTime.Sub(*row.CheckedInTime)
+			v.service = &service
+		}
+	}
+
+	for _, v := range visits {
+		if v.service == nil {
+			continue
+		}
+
+		service := *v.service - v.wait
+		if service < 0 {
+			service = 0
+		}
+
+		v.service = &service
+	}
+
+	type queueKey struct {
+		date           time.Time
+		patientQueueID int
+	}
+
+	var queueKeys []queueKey
+	queueWaits := make(map[queueKey][]time.Duration)
+	queueServices := make(map[queueKey][]time.Duration)
+	queueVisits := make(map[queueKey]map[int]bool)
+
+	for _, row := range rows {
+		key := queueKey{date: day(row.EnqueuedAt), patientQueueID: row.PatientQueueID}
+		if _, ok := queueVisits[key]; !ok {
+			queueKeys = append(queueKeys, key)
+			queueVisits[key] = make(map[int]bool)
+		}
+
+		queueWaits[key] = append(queueWaits[key], wait(row.EnqueuedAt, row.CalledAt, row.LeftAt))
+
+		// Count the service time of a visit once per queue
+		if v := visits[row.AppointmentID]; !queueVisits[key][row.AppointmentID] && v.service != nil {
+			queueServices[key] = append(queueServices[key], *v.service)
+		}
+
+		queueVisits[key][row.AppointmentID] = true
+	}
+
+	type providerKey struct {
+		date   time.Time
+		userID int
+	}
+
+	var providerKeys []providerKey
+	var userIDs []int
+	providerWaits := make(map[providerKey][]time.Duration)
+	providerServices := make(map[providerKey][]time.Duration)
+
+	for _, v := range visits {
+		key := providerKey{date: v.date, userID: v.userID}
+		if _, ok := providerWaits[key]; !ok {
+			providerKeys = append(providerKeys, key)
+			userIDs = append(userIDs, v.userID)
+		}
+
+		providerWaits[key] = append(providerWaits[key], v.wait)
+		if v.service != nil {
+			providerServices[key] = append(providerServices[key], *v.service)
+		}
+	}
+
+	var patientQueues []models.PatientQueue
+	if err := r.DB.Unscoped().Find(&patientQueues).Error; err != nil {
+		return nil, err
+	}
+
+	queuesByID := make(map[int]models.PatientQueue)
+	for _, patientQueue := range patientQueues {
+		queuesByID[patientQueue.ID] = patientQueue
+	}
+
+	var users []models.User
+	if len(userIDs) > 0 {
+		if err := r.DB.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	usersByID := make(map[int]models.User)
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+
+	result := models.QueueMetrics{
+		Queues:    []*models.QueueMetric{},
+		Providers: []*models.ProviderQueueMetric{},
+	}
+
+	for _, key := range queueKeys {
+		result.Queues = append(result.Queues, &models.QueueMetric{
+			Date:           key.date,
+			PatientQueueID: key.patientQueueID,
+			PatientQueue:   queuesByID[key.patientQueueID],
+			WaitTime:       models.NewDurationStats(queueWaits[key]),
+			ServiceTime:    models.NewDurationStats(queueServices[key]),
+		})
+	}
+
+	for _, key := range providerKeys {
+		result.Providers = append(result.Providers, &models.ProviderQueueMetric{
+			Date:        key.date,
+			UserID:      key.userID,
+			User:        usersByID[key.userID],
+			WaitTime:    models.NewDurationStats(providerWaits[key]),
+			ServiceTime: models.NewDurationStats(providerServices[key]),
+		})
+	}
+
+	sort.SliceStable(result.Queues, func(i, j int) bool {
+		if !result.Queues[i].Date.Equal(result.Queues[j].Date) {
+			return result.Queues[i].Date.Before(result.Queues[j].Date)
+		}
+
+		return result.Queues[i].PatientQueueID < result.Queues[j].PatientQueueID
 	})
+
+	sort.SliceStable(result.Providers, func(i, j int) bool {
+		if !result.Providers[i].Date.Equal(result.Providers[j].Date) {
+			return result.Providers[i].Date.Before(result.Providers[j].Date)
+		}
+
+		return result.Providers[i].UserID < result.Providers[j].UserID
+	})
+
+	return &result, nil
 }
 
-func remove(slice []int, s int) []int {
-	if len(slice) > 0 && s >= len(slice) {
-		return slice[:len(slice)-1]
+// initials masks a name down to the first letter of each part, e.g. "A. B."
+func initials(names ...string) string {
+	var parts []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+
+		r, _ := utf8.DecodeRuneInString(name)
+		parts = append(parts, strings.ToUpper(string(r))+".")
 	}
 
-	return append(slice[:s], slice[s+1:]...)
+	return strings.Join(parts, " ")
 }
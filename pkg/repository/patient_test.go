@@ -0,0 +1,77 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package repository
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tensoremr/server/pkg/models"
+)
+
+func TestScoreDuplicate(t *testing.T) {
+	birth := time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)
+
+	patient := models.Patient{FirstName: "Abebe", LastName: "Kebede", PhoneNo: "+251 911 234 567", DateOfBirth: birth, IDNo: "ID-42", CardNo: "1001"}
+
+	cases := []struct {
+		name      string
+		candidate models.Patient
+		score     float64
+		reasons   []string
+	}{
+		{"unrelated", models.Patient{FirstName: "Tsion", LastName: "Haile", PhoneNo: "0922000000", IDNo: "ID-7"}, 0, []string{}},
+		{"same name", models.Patient{FirstName: "abebe", LastName: "KEBEDE"}, 0.4, []string{"Same name"}},
+		{"swapped name", models.Patient{FirstName: "Kebede", LastName: "Abebe"}, 0.4, []string{"Same name"}},
+		{"phone in another format", models.Patient{FirstName: "Tsion", LastName: "Haile", HomePhone: "0911-234567"}, 0.25, []string{"Same phone number"}},
+		{"ID number and date of birth", models.Patient{FirstName: "Tsion", LastName: "Haile", IDNo: " id-42 ", DateOfBirth: birth.Add(3 * time.Hour)}, 0.6, []string{"Same ID number", "Same date of birth"}},
+		{"everything matches", patient, 1, []string{"Same ID number", "Same card number", "Same phone number", "Same date of birth", "Same name"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			candidate := c.candidate
+			got := scoreDuplicate(&patient, &candidate)
+
+			if math.Abs(got.Score-c.score) > 1e-9 || !reflect.DeepEqual(got.Reasons, c.reasons) {
+				t.Errorf("scoreDuplicate() = %v %v, want %v %v", got.Score, got.Reasons, c.score, c.reasons)
+			}
+		})
+	}
+}
+
+func TestScoreDuplicateSimilarName(t *testing.T) {
+	patient := models.Patient{FirstName: "Martha", LastName: "Tesfaye"}
+	candidate := models.Patient{FirstName: "Marhta", LastName: "Tesfaye"}
+
+	got := scoreDuplicate(&patient, &candidate)
+
+	if !reflect.DeepEqual(got.Reasons, []string{"Similar name"}) || got.Score <= 0.8*duplicateNameWeight || got.Score >= duplicateNameWeight {
+		t.Errorf("scoreDuplicate() = %v %v, want a similar name", got.Score, got.Reasons)
+	}
+}
+
+func TestPatientPhones(t *testing.T) {
+	patient := models.Patient{PhoneNo: "+251-911-234-567", PhoneNo2: "12345", HomePhone: "011 551 2345"}
+
+	if got, want := patientPhones(&patient), []string{"911234567", "115512345"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("patientPhones() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repository
+
+import (
+	"github.com/casbin/casbin/v2"
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+)
+
+type PermissionRepository struct {
+	DB       *gorm.DB
+	Enforcer *casbin.Enforcer
+}
+
+func ProvidePermissionRepository(DB *gorm.DB, enforcer *casbin.Enforcer) PermissionRepository {
+	return PermissionRepository{DB: DB, Enforcer: enforcer}
+}
+
+// Seed adds the given policies that are not stored yet, so that policies added
+// to the defaults reach existing databases
+func (r *PermissionRepository) Seed(policies [][]string) error {
+	for _, policy := range policies {
+		params := make([]interface{}, len(policy))
+		for i, value := range policy {
+			params[i] = value
+		}
+
+		if r.Enforcer.HasPolicy(params...) {
+			continue
+		}
+
+		if _, err := r.Enforcer.AddPolicy(params...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAll ...
+func (r *PermissionRepository) GetAll() []models.Permission {
+	var result []models.Permission
+
+	for _, policy := range r.Enforcer.GetPolicy() {
+		result = append(result, models.Permission{Role: policy[0], Object: policy[1], Action: policy[2]})
+	}
+
+	return result
+}
+
+// Grant ...
+func (r *PermissionRepository) Grant(m models.Permission) error {
+	_, err := r.Enforcer.AddPolicy(m.Role, m.Object, m.Action)
+	return err
+}
+
+// Revoke ...
+func (r *PermissionRepository) Revoke(m models.Permission) (bool, error) {
+	return r.Enforcer.RemovePolicy(m.Role, m.Object, m.Action)
+}
+
+// RenameRole moves the policies of a role to a new role name
+func (r *PermissionRepository) RenameRole(role string, newRole string) error {
+	for _, policy := range r.Enforcer.GetFilteredPolicy(0, role) {
+		if _, err := r.Enforcer.RemovePolicy(policy[0], policy[1], policy[2]); err != nil {
+			return err
+		}
+
+		if _, err := r.Enforcer.AddPolicy(newRole, policy[1], policy[2]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SyncUserRoles makes the user types of a user its casbin roles
+func (r *PermissionRepository) SyncUserRoles(email string, userTypes []models.UserType) error {
+	if _, err := r.Enforcer.DeleteRolesForUser(email); err != nil {
+		return err
+	}
+
+	for _, userType := range userTypes {
+		if _, err := r.Enforcer.AddRoleForUser(email, userType.Title); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveUserRoles ...
+func (r *PermissionRepository) RemoveUserRoles(email string) error {
+	_, err := r.Enforcer.DeleteRolesForUser(email)
+	return err
+}
+
+// SyncAllUserRoles rebuilds the casbin roles of every user from their user types
+func (r *PermissionRepository) SyncAllUserRoles() error {
+	var users []models.User
+	if err := r.DB.Preload("UserTypes").Find(&users).Error; err != nil {
+		return err
+	}
+
+	if groupingPolicies := r.Enforcer.GetGroupingPolicy(); len(groupingPolicies) > 0 {
+		if _, err := r.Enforcer.RemoveGroupingPolicies(groupingPolicies); err != nil {
+			return err
+		}
+	}
+
+	for _, user := range users {
+		if err := r.SyncUserRoles(user.Email, user.UserTypes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -19,6 +19,8 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/tensoremr/server/pkg/models"
 	"gorm.io/gorm"
 )
@@ -31,6 +33,11 @@ func ProvidePhysicalExamFindingRepository(DB *gorm.DB) PhysicalExamFindingReposi
 	return PhysicalExamFindingRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *PhysicalExamFindingRepository) WithContext(ctx context.Context) *PhysicalExamFindingRepository {
+	return &PhysicalExamFindingRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // Save ...
 func (r *PhysicalExamFindingRepository) Save(m *models.PhysicalExamFinding) error {
 	return r.DB.Create(&m).Error
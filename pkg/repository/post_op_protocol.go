@@ -0,0 +1,73 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repository
+
+import (
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+)
+
+type PostOpProtocolRepository struct {
+	DB *gorm.DB
+}
+
+func ProvidePostOpProtocolRepository(DB *gorm.DB) PostOpProtocolRepository {
+	return PostOpProtocolRepository{DB: DB}
+}
+
+// GetBySurgicalProcedureType returns the steps of the post-op protocol of a surgical procedure type in the order
+// they are scheduled
+func (r *PostOpProtocolRepository) GetBySurgicalProcedureType(surgicalProcedureTypeID int) ([]*models.PostOpProtocolStep, error) {
+	var result []*models.PostOpProtocolStep
+
+	err := r.DB.Where("surgical_procedure_type_id = ?", surgicalProcedureTypeID).Preload("VisitType").Preload("Room").Preload("User").Order(postOpStepOrder).Find(&result).Error
+
+	return result, err
+}
+
+// Replace sets the steps of the post-op protocol of a surgical procedure type
+func (r *PostOpProtocolRepository) Replace(surgicalProcedureTypeID int, steps []models.PostOpProtocolStep) error {
+	for i := range steps {
+		if err := steps[i].Validate(); err != nil {
+			return err
+		}
+
+		steps[i].ID = 0
+		steps[i].SurgicalProcedureTypeID = surgicalProcedureTypeID
+	}
+
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", surgicalProcedureTypeID).Take(&models.SurgicalProcedureType{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("surgical_procedure_type_id = ?", surgicalProcedureTypeID).Delete(&models.PostOpProtocolStep{}).Error; err != nil {
+			return err
+		}
+
+		if len(steps) == 0 {
+			return nil
+		}
+
+		return tx.Create(&steps).Error
+	})
+}
+
+// postOpStepOrder sorts protocol steps by how long after surgery they are
+const postOpStepOrder = "CASE delay_unit WHEN 'MONTH' THEN delay * 30 WHEN 'WEEK' THEN delay * 7 ELSE delay END ASC"
@@ -0,0 +1,272 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+)
+
+// maxSlotSearchDays bounds the date range searched for available slots
+const maxSlotSearchDays = 31
+
+type ProviderScheduleRepository struct {
+	DB *gorm.DB
+}
+
+func ProvideProviderScheduleRepository(DB *gorm.DB) ProviderScheduleRepository {
+	return ProviderScheduleRepository{DB: DB}
+}
+
+// Save ...
+func (r *ProviderScheduleRepository) Save(m *models.ProviderSchedule) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	return r.DB.Create(&m).Error
+}
+
+// Get ...
+func (r *ProviderScheduleRepository) Get(m *models.ProviderSchedule, ID int) error {
+	return r.DB.Where("id = ?", ID).Preload("User").Preload("Room").Preload("Breaks").Take(&m).Error
+}
+
+// GetByUser ...
+func (r *ProviderScheduleRepository) GetByUser(userID int) ([]*models.ProviderSchedule, error) {
+	var result []*models.ProviderSchedule
+
+	err := r.DB.Where("user_id = ?", userID).Preload("User").Preload("Room").Preload("Breaks").Order("weekday ASC").Order("start_time ASC").Find(&result).Error
+
+	return result, err
+}
+
+// Update replaces the schedule's fields and breaks
+func (r *ProviderScheduleRepository) Update(m *models.ProviderSchedule) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&m).Select("Weekday", "StartTime", "EndTime", "RoomID").Updates(&m).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("provider_schedule_id = ?", m.ID).Delete(&models.ProviderScheduleBreak{}).Error; err != nil {
+			return err
+		}
+
+		for i := range m.Breaks {
+			m.Breaks[i].ID = 0
+			m.Breaks[i].ProviderScheduleID = m.ID
+		}
+
+		if len(m.Breaks) > 0 {
+			if err := tx.Create(&m.Breaks).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Delete ...
+func (r *ProviderScheduleRepository) Delete(ID int) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("provider_schedule_id = ?", ID).Delete(&models.ProviderScheduleBreak{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", ID).Delete(&models.ProviderSchedule{}).Error
+	})
+}
+
+// interval is a busy period that slots must not overlap
+type interval struct {
+	Start  time.Time
+	End    time.Time
+	RoomID int
+}
+
+func overlaps(intervals []interval, start time.Time, end time.Time) bool {
+	for _, i := range intervals {
+		if i.Start.Before(end) && start.Before(i.End) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindAvailableSlots returns the free slots of a provider for a visit type between from and to, in order. Slots
+// follow the provider's weekly schedules, skipping breaks, leave, holidays and existing appointments of the
+// provider. When roomID is nil the room of the schedule is used, and slots where that room is already booked
+// are skipped as well.
+func (r *ProviderScheduleRepository) FindAvailableSlots(userID int, visitTypeID int, from time.Time, to time.Time, roomID *int) ([]*models.AvailableSlot, error) {
+	if !to.After(from) {
+		return nil, errors.New("End date must be after start date")
+	}
+
+	if to.Sub(from) > maxSlotSearchDays*24*time.Hour {
+		return nil, fmt.Errorf("Date range cannot exceed %d days", maxSlotSearchDays)
+	}
+
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+
+	var visitType models.VisitType
+	if err := r.DB.Where("id = ?", visitTypeID).Take(&visitType).Error; err != nil {
+		return nil, errors.New("Cannot find visit type")
+	}
+
+	slotDuration := time.Duration(visitType.SlotMinutes()) * time.Minute
+
+	var schedules []models.ProviderSchedule
+	if err := r.DB.Where("user_id = ?", userID).Preload("Breaks").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+
+	if len(schedules) == 0 {
+		return []*models.AvailableSlot{}, nil
+	}
+
+	var exceptions []models.ScheduleException
+	if err := r.DB.Where("user_id = ? OR user_id IS NULL", userID).Where("start_time < ?", to).Where("end_time > ?", from).Find(&exceptions).Error; err != nil {
+		return nil, err
+	}
+
+	var blocked []interval
+	for _, e := range exceptions {
+		blocked = append(blocked, interval{Start: e.StartTime, End: e.EndTime})
+	}
+
+	busy, err := r.bookedIntervals(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var providerBusy, roomBusy []interval
+	for _, i := range busy {
+		if i.RoomID == -1 {
+			providerBusy = append(providerBusy, i)
+		} else {
+			roomBusy = append(roomBusy, i)
+		}
+	}
+
+	localFrom := from.In(time.Local)
+	firstDay := time.Date(localFrom.Year(), localFrom.Month(), localFrom.Day(), 0, 0, 0, 0, time.Local)
+
+	slots := []*models.AvailableSlot{}
+	for day := firstDay; day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, schedule := range schedules {
+			if schedule.Weekday != int(day.Weekday()) {
+				continue
+			}
+
+			startMinutes, endMinutes, err := models.ClockRange(schedule.StartTime, schedule.EndTime)
+			if err != nil {
+				continue
+			}
+
+			var breaks []interval
+			for _, b := range schedule.Breaks {
+				breakStart, breakEnd, err := models.ClockRange(b.StartTime, b.EndTime)
+				if err != nil {
+					continue
+				}
+
+				breaks = append(breaks, interval{Start: day.Add(time.Duration(breakStart) * time.Minute), End: day.Add(time.Duration(breakEnd) * time.Minute)})
+			}
+
+			slotRoomID := schedule.RoomID
+			if roomID != nil {
+				slotRoomID = roomID
+			}
+
+			var busyRoom []interval
+			if slotRoomID != nil {
+				for _, i := range roomBusy {
+					if i.RoomID == *slotRoomID {
+						busyRoom = append(busyRoom, i)
+					}
+				}
+			}
+
+			scheduleEnd := day.Add(time.Duration(endMinutes) * time.Minute)
+			for start := day.Add(time.Duration(startMinutes) * time.Minute); !start.Add(slotDuration).After(scheduleEnd); start = start.Add(slotDuration) {
+				end := start.Add(slotDuration)
+
+				if start.Before(from) || end.After(to) {
+					continue
+				}
+
+				if overlaps(breaks, start, end) || overlaps(blocked, start, end) || overlaps(providerBusy, start, end) || overlaps(busyRoom, start, end) {
+					continue
+				}
+
+				slots = append(slots, &models.AvailableSlot{
+					Start:  start,
+					End:    end,
+					UserID: userID,
+					RoomID: slotRoomID,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(slots, func(i, j int) bool {
+		return slots[i].Start.Before(slots[j].Start)
+	})
+
+	return slots, nil
+}
+
+// bookedIntervals returns the periods taken by appointments that are not cancelled around from and to. Intervals
+// of the provider's own appointments have a RoomID of -1, the others carry the room they occupy.
+func (r *ProviderScheduleRepository) bookedIntervals(userID int, from time.Time, to time.Time) ([]interval, error) {
+	var appointments []models.Appointment
+
+	// Look back a day so that long appointments starting before from are included
+	if err := r.DB.Select("id, user_id, room_id, check_in_time, visit_type_id").Where("check_in_time >= ?", from.AddDate(0, 0, -1)).Where("check_in_time < ?", to).Where("appointment_status_id NOT IN (?)", r.DB.Model(&models.AppointmentStatus{}).Select("id").Where("title = ?", "Cancelled")).Preload("VisitType").Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+
+	var result []interval
+	for _, a := range appointments {
+		end := a.CheckInTime.Add(time.Duration(a.VisitType.SlotMinutes()) * time.Minute)
+
+		if a.UserID == userID {
+			result = append(result, interval{Start: a.CheckInTime, End: end, RoomID: -1})
+		}
+
+		if a.RoomID != 0 {
+			result = append(result, interval{Start: a.CheckInTime, End: end, RoomID: a.RoomID})
+		}
+	}
+
+	return result, nil
+}
@@ -19,8 +19,10 @@
 package repository
 
 import (
+	"context"
 	"time"
 
+	"github.com/tensoremr/server/pkg/chartlock"
 	"github.com/tensoremr/server/pkg/models"
 	"gorm.io/gorm"
 )
@@ -33,6 +35,11 @@ func ProvideReferralOrderRepository(DB *gorm.DB) ReferralOrderRepository {
 	return ReferralOrderRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *ReferralOrderRepository) WithContext(ctx context.Context) *ReferralOrderRepository {
+	return &ReferralOrderRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // Save ...
 func (r *ReferralOrderRepository) Save(m *models.ReferralOrder, patientChartID int, patientID int, orderedToID *int, referralType models.ReferralType, user models.User, receptionNote *string, reason string, providerName *string) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
@@ -130,7 +137,7 @@ func (r *ReferralOrderRepository) GetTodaysOrderedCount() (count int) {
 
 // ConfirmOrder ...
 func (r *ReferralOrderRepository) ConfirmOrder(m *models.ReferralOrder, referralOrderID int, referralID int, billingID *int, invoiceNo *string, roomID *int, checkInTime *time.Time) error {
-	return r.DB.Transaction(func(tx *gorm.DB) error {
+	return chartlock.Bypass(r.DB).Transaction(func(tx *gorm.DB) error {
 		var referral models.Referral
 		if err := tx.Where("id = ?", referralID).Take(&referral).Error; err != nil {
 			return err
@@ -0,0 +1,87 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository struct {
+	DB *gorm.DB
+}
+
+func ProvideRefreshTokenRepository(DB *gorm.DB) RefreshTokenRepository {
+	return RefreshTokenRepository{DB: DB}
+}
+
+// Save ...
+func (r *RefreshTokenRepository) Save(m *models.RefreshToken) error {
+	return r.DB.Create(&m).Error
+}
+
+// GetByTokenHash ...
+func (r *RefreshTokenRepository) GetByTokenHash(m *models.RefreshToken, tokenHash string) error {
+	return r.DB.Where("token_hash = ?", tokenHash).Preload("User.UserTypes").Take(&m).Error
+}
+
+// Rotate revokes a refresh token and saves the token replacing it. It fails if
+// the token has already been used, which happens when it is replayed.
+func (r *RefreshTokenRepository) Rotate(old *models.RefreshToken, m *models.RefreshToken) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		result := tx.Model(&models.RefreshToken{}).Where("id = ?", old.ID).Where("revoked_at IS NULL").Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errors.New("Refresh token has already been used")
+		}
+
+		return tx.Create(&m).Error
+	})
+}
+
+// IsSessionActive returns true if the session has an unexpired refresh token
+// that has not been revoked
+func (r *RefreshTokenRepository) IsSessionActive(sessionID string) (bool, error) {
+	var count int64
+	err := r.DB.Model(&models.RefreshToken{}).Where("session_id = ?", sessionID).Where("revoked_at IS NULL").Where("expires_at > ?", time.Now()).Count(&count).Error
+	return count > 0, err
+}
+
+// RevokeSession revokes every refresh token of a session
+func (r *RefreshTokenRepository) RevokeSession(sessionID string) error {
+	return r.DB.Model(&models.RefreshToken{}).Where("session_id = ?", sessionID).Where("revoked_at IS NULL").Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUser revokes every session of a user
+func (r *RefreshTokenRepository) RevokeAllForUser(userID int) error {
+	return r.DB.Model(&models.RefreshToken{}).Where("user_id = ?", userID).Where("revoked_at IS NULL").Update("revoked_at", time.Now()).Error
+}
+
+// ClearExpired deletes expired refresh tokens
+func (r *RefreshTokenRepository) ClearExpired() error {
+	return r.DB.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.RefreshToken{}).Error
+}
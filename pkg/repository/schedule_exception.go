@@ -0,0 +1,78 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repository
+
+import (
+	"errors"
+
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+)
+
+type ScheduleExceptionRepository struct {
+	DB *gorm.DB
+}
+
+func ProvideScheduleExceptionRepository(DB *gorm.DB) ScheduleExceptionRepository {
+	return ScheduleExceptionRepository{DB: DB}
+}
+
+// Save ...
+func (r *ScheduleExceptionRepository) Save(m *models.ScheduleException) error {
+	if !m.EndTime.After(m.StartTime) {
+		return errors.New("End time must be after start time")
+	}
+
+	return r.DB.Create(&m).Error
+}
+
+// Get ...
+func (r *ScheduleExceptionRepository) Get(m *models.ScheduleException, ID int) error {
+	return r.DB.Where("id = ?", ID).Preload("User").Take(&m).Error
+}
+
+// GetAll returns the exceptions of a provider, including those that apply to every provider, or all exceptions
+// when userID is nil
+func (r *ScheduleExceptionRepository) GetAll(p models.PaginationInput, userID *int) ([]models.ScheduleException, int64, error) {
+	var result []models.ScheduleException
+
+	dbOp := r.DB.Scopes(models.Paginate(&p)).Select("*, count(*) OVER() AS count")
+
+	if userID != nil {
+		dbOp.Where("user_id = ? OR user_id IS NULL", *userID)
+	}
+
+	dbOp.Preload("User").Order("start_time DESC").Find(&result)
+
+	var count int64
+	if len(result) > 0 {
+		count = result[0].Count
+	}
+
+	if dbOp.Error != nil {
+		return result, 0, dbOp.Error
+	}
+
+	return result, count, dbOp.Error
+}
+
+// Delete ...
+func (r *ScheduleExceptionRepository) Delete(ID int) error {
+	return r.DB.Where("id = ?", ID).Delete(&models.ScheduleException{}).Error
+}
@@ -19,8 +19,10 @@
 package repository
 
 import (
+	"context"
 	"time"
 
+	"github.com/tensoremr/server/pkg/chartlock"
 	"github.com/tensoremr/server/pkg/models"
 	"gorm.io/gorm"
 )
@@ -33,6 +35,11 @@ func ProvideSurgicalOrderRepository(DB *gorm.DB) SurgicalOrderRepository {
 	return SurgicalOrderRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *SurgicalOrderRepository) WithContext(ctx context.Context) *SurgicalOrderRepository {
+	return &SurgicalOrderRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // SaveOpthalmologyOrder ...
 func (r *SurgicalOrderRepository) SaveOpthalmologyOrder(m *models.SurgicalOrder, surgicalProcedureTypeID int, patientChartID int, patientID int, billingID int, user models.User, performOnEye string, orderNote string, receptionNote string) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
@@ -141,7 +148,7 @@ func (r *SurgicalOrderRepository) GetTodaysOrderedCount() (count int) {
 
 // ConfirmOrder ...
 func (r *SurgicalOrderRepository) ConfirmOrder(m *models.SurgicalOrder, surgicalOrderID int, surgicalProcedureID int, invoiceNo string, roomID int, checkInTime time.Time) error {
-	return r.DB.Transaction(func(tx *gorm.DB) error {
+	return chartlock.Bypass(r.DB).Transaction(func(tx *gorm.DB) error {
 
 		var surgicalProcedure models.SurgicalProcedure
 		if err := tx.Where("id = ?", surgicalProcedureID).Preload("Payments").Take(&surgicalProcedure).Error; err != nil {
@@ -19,8 +19,10 @@
 package repository
 
 import (
+	"context"
 	"time"
 
+	"github.com/tensoremr/server/pkg/chartlock"
 	"github.com/tensoremr/server/pkg/models"
 	"gorm.io/gorm"
 )
@@ -33,6 +35,11 @@ func ProvideTreatmentOrderRepository(DB *gorm.DB) TreatmentOrderRepository {
 	return TreatmentOrderRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *TreatmentOrderRepository) WithContext(ctx context.Context) *TreatmentOrderRepository {
+	return &TreatmentOrderRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // SaveOpthalmologyTreatment ...
 func (r *TreatmentOrderRepository) SaveOpthalmologyTreatment(m *models.TreatmentOrder, treatmentTypeID int, patientChartID int, patientID int, billingID int, user models.User, treatmentNote string, orderNote string) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
@@ -140,7 +147,7 @@ func (r *TreatmentOrderRepository) GetTodaysOrderedCount() (count int) {
 
 // ConfirmOrder ...
 func (r *TreatmentOrderRepository) ConfirmOrder(m *models.TreatmentOrder, treatmentOrderID int, treatmentID int, invoiceNo string, roomID int, checkInTime time.Time) error {
-	return r.DB.Transaction(func(tx *gorm.DB) error {
+	return chartlock.Bypass(r.DB).Transaction(func(tx *gorm.DB) error {
 
 		var treatment models.Treatment
 		if err := tx.Where("id = ?", treatmentID).Preload("Payments").Take(&treatment).Error; err != nil {
@@ -20,7 +20,6 @@ package repository
 
 import (
 	"github.com/tensoremr/server/pkg/models"
-	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -90,11 +89,8 @@ func (r *UserRepository) Save(m *models.User, userTypes []models.UserType) error
 					return err
 				}
 
-				queue := datatypes.JSON([]byte("[" + "]"))
-
 				var patientQueue models.PatientQueue
 				patientQueue.QueueName = "Dr. " + m.FirstName + " " + m.LastName
-				patientQueue.Queue = queue
 				patientQueue.QueueType = "USER"
 
 				if err := tx.Create(&patientQueue).Error; err != nil {
@@ -249,6 +245,46 @@ func (r *UserRepository) Update(m *models.User, userTypes []models.UserType) err
 	return nil
 }
 
+// GetByOAuth2 ...
+func (r *UserRepository) GetByOAuth2(m *models.User, provider string, uid string) error {
+	return r.DB.Where("o_auth2_provider = ?", provider).Where("o_auth2_uid = ?", uid).Preload("UserTypes").Take(&m).Error
+}
+
+// UpdateOAuth2 saves the identity provider account linked to a user
+func (r *UserRepository) UpdateOAuth2(m *models.User) error {
+	return r.DB.Model(&models.User{}).Where("id = ?", m.ID).Select("OAuth2UID", "OAuth2Provider", "OAuth2AccessToken", "OAuth2RefreshToken", "OAuth2Expiry").Updates(m).Error
+}
+
+// GetByRecoverSelector ...
+func (r *UserRepository) GetByRecoverSelector(m *models.User, selector string) error {
+	return r.DB.Where("recover_selector = ?", selector).Take(&m).Error
+}
+
+// GetByConfirmSelector ...
+func (r *UserRepository) GetByConfirmSelector(m *models.User, selector string) error {
+	return r.DB.Where("confirm_selector = ?", selector).Take(&m).Error
+}
+
+// UpdateRecovery saves the password and password recovery token of a user
+func (r *UserRepository) UpdateRecovery(m *models.User) error {
+	return r.DB.Model(&models.User{}).Where("id = ?", m.ID).Select("Password", "RecoverSelector", "RecoverVerifier", "RecoverTokenExpiry").Updates(m).Error
+}
+
+// UpdateConfirmation saves the email confirmation token and status of a user
+func (r *UserRepository) UpdateConfirmation(m *models.User) error {
+	return r.DB.Model(&models.User{}).Where("id = ?", m.ID).Select("ConfirmSelector", "ConfirmVerifier", "ConfirmTokenExpiry", "Confirmed").Updates(m).Error
+}
+
+// UpdateLoginAttempts saves the failed sign in counter and lock of a user
+func (r *UserRepository) UpdateLoginAttempts(m *models.User) error {
+	return r.DB.Model(&models.User{}).Where("id = ?", m.ID).Select("AttemptCount", "LastAttempt", "Locked").Updates(m).Error
+}
+
+// UpdateTwoFactor saves the two-factor authentication settings of a user
+func (r *UserRepository) UpdateTwoFactor(m *models.User) error {
+	return r.DB.Model(&models.User{}).Where("id = ?", m.ID).Select("TOTPSecretKey", "TOTPEnabled", "TOTPLastStep", "RecoveryCodes").Updates(m).Error
+}
+
 // Ping ...
 func (r *UserRepository) Ping() error {
 	db, err := r.DB.DB()
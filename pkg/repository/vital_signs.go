@@ -19,6 +19,8 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/tensoremr/server/pkg/models"
 	"gorm.io/gorm"
 )
@@ -31,6 +33,11 @@ func ProvideVitalSignsRepository(DB *gorm.DB) VitalSignsRepository {
 	return VitalSignsRepository{DB: DB}
 }
 
+// WithContext ...
+func (r *VitalSignsRepository) WithContext(ctx context.Context) *VitalSignsRepository {
+	return &VitalSignsRepository{DB: r.DB.WithContext(ctx)}
+}
+
 // Save ...
 func (r *VitalSignsRepository) Save(m *models.VitalSigns) error {
 	return r.DB.Create(&m).Error
@@ -0,0 +1,180 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tensoremr/server/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WaitlistRepository struct {
+	DB *gorm.DB
+}
+
+func ProvideWaitlistRepository(DB *gorm.DB) WaitlistRepository {
+	return WaitlistRepository{DB: DB}
+}
+
+// WithContext ...
+func (r *WaitlistRepository) WithContext(ctx context.Context) *WaitlistRepository {
+	return &WaitlistRepository{DB: r.DB.WithContext(ctx)}
+}
+
+// SaveEntry ...
+func (r *WaitlistRepository) SaveEntry(m *models.WaitlistEntry) error {
+	if m.UserID == nil && m.VisitTypeID == nil {
+		return errors.New("A provider or visit type is required")
+	}
+
+	if m.PreferredTo.Before(m.PreferredFrom) {
+		return errors.New("Preferred end date must not be before the start date")
+	}
+
+	m.Status = models.WaitingWaitlistEntry
+
+	return r.DB.Create(&m).Error
+}
+
+// GetEntry ...
+func (r *WaitlistRepository) GetEntry(m *models.WaitlistEntry, ID int) error {
+	return r.DB.Where("id = ?", ID).Preload("Patient").Preload("User").Preload("VisitType").Take(&m).Error
+}
+
+// GetEntries ...
+func (r *WaitlistRepository) GetEntries(p models.PaginationInput, filter *models.WaitlistEntry) ([]models.WaitlistEntry, int64, error) {
+	var result []models.WaitlistEntry
+
+	dbOp := r.DB.Scopes(models.Paginate(&p)).Select("*, count(*) OVER() AS count").Where(filter).Preload("Patient").Preload("User").Preload("VisitType").Order("priority DESC").Order("id ASC").Find(&result)
+
+	var count int64
+	if len(result) > 0 {
+		count = result[0].Count
+	}
+
+	if dbOp.Error != nil {
+		return result, 0, dbOp.Error
+	}
+
+	return result, count, dbOp.Error
+}
+
+// RemoveEntry takes a patient off the waitlist
+func (r *WaitlistRepository) RemoveEntry(ID int) error {
+	return r.DB.Model(&models.WaitlistEntry{}).Where("id = ?", ID).Where("status = ?", models.WaitingWaitlistEntry).Update("status", models.RemovedWaitlistEntry).Error
+}
+
+// ReleaseSlot offers the slot of a deleted or cancelled appointment to the waitlist. Past appointments are ignored.
+func (r *WaitlistRepository) ReleaseSlot(appointment models.Appointment) error {
+	if appointment.UserID == 0 || appointment.CheckInTime.Before(time.Now()) {
+		return nil
+	}
+
+	return r.DB.Create(&models.WaitlistOffer{
+		FreedAppointmentID: appointment.ID,
+		UserID:             appointment.UserID,
+		RoomID:             appointment.RoomID,
+		VisitTypeID:        appointment.VisitTypeID,
+		CheckInTime:        appointment.CheckInTime,
+		Status:             models.OpenWaitlistOffer,
+	}).Error
+}
+
+// GetOpenOffers returns upcoming freed slots, of a provider when userID is set, in order of check-in time
+func (r *WaitlistRepository) GetOpenOffers(userID *int) ([]*models.WaitlistOffer, error) {
+	var result []*models.WaitlistOffer
+
+	dbOp := r.DB.Where("status = ?", models.OpenWaitlistOffer).Where("check_in_time > ?", time.Now())
+
+	if userID != nil {
+		dbOp.Where("user_id = ?", *userID)
+	}
+
+	err := dbOp.Preload("User").Preload("VisitType").Order("check_in_time ASC").Find(&result).Error
+
+	return result, err
+}
+
+// GetCandidates returns the waiting entries a freed slot suits, in the order the slot is offered to them: by
+// priority and then by how long they have waited
+func (r *WaitlistRepository) GetCandidates(offer *models.WaitlistOffer) ([]*models.WaitlistEntry, error) {
+	var result []*models.WaitlistEntry
+
+	err := candidates(r.DB, offer).Preload("Patient").Preload("User").Preload("VisitType").Order("priority DESC").Order("created_at ASC").Find(&result).Error
+
+	return result, err
+}
+
+// candidates selects the waiting entries whose provider, visit type and preferred dates suit offer
+func candidates(db *gorm.DB, offer *models.WaitlistOffer) *gorm.DB {
+	checkInTime := offer.CheckInTime
+	day := time.Date(checkInTime.Year(), checkInTime.Month(), checkInTime.Day(), 0, 0, 0, 0, checkInTime.Location())
+
+	return db.Where("status = ?", models.WaitingWaitlistEntry).
+		Where("user_id = ? OR user_id IS NULL", offer.UserID).
+		Where("visit_type_id = ? OR visit_type_id IS NULL", offer.VisitTypeID).
+		Where("preferred_from < ?", day.AddDate(0, 0, 1)).
+		Where("preferred_to >= ?", day)
+}
+
+// Convert books the slot of an open offer for a waitlisted patient and takes them off the waitlist
+func (r *WaitlistRepository) Convert(entryID int, offerID int) (*models.Appointment, error) {
+	var appointment models.Appointment
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var offer models.WaitlistOffer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", offerID).Take(&offer).Error; err != nil {
+			return err
+		}
+
+		if offer.Status != models.OpenWaitlistOffer || offer.CheckInTime.Before(time.Now()) {
+			return errors.New("Slot is no longer available")
+		}
+
+		var entry models.WaitlistEntry
+		if err := candidates(tx, &offer).Where("id = ?", entryID).Take(&entry).Error; err != nil {
+			return errors.New("Waitlist entry does not match this slot")
+		}
+
+		appointment = models.Appointment{
+			PatientID:   entry.PatientID,
+			UserID:      offer.UserID,
+			RoomID:      offer.RoomID,
+			VisitTypeID: offer.VisitTypeID,
+			CheckInTime: offer.CheckInTime,
+		}
+
+		appointmentRepository := AppointmentRepository{DB: tx}
+		if err := appointmentRepository.CreateNewAppointment(&appointment, nil, nil, false); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&entry).Updates(map[string]interface{}{"status": models.BookedWaitlistEntry, "appointment_id": appointment.ID}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&offer).Updates(map[string]interface{}{"status": models.FilledWaitlistOffer, "waitlist_entry_id": entry.ID}).Error
+	})
+
+	return &appointment, err
+}
@@ -24,22 +24,33 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
 	_ "net/http/pprof"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/casbin/casbin/v2"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/robfig/cron/v3"
+	"github.com/tensoremr/server/pkg/audit"
 	"github.com/tensoremr/server/pkg/auth"
+	"github.com/tensoremr/server/pkg/chartlock"
 	"github.com/tensoremr/server/pkg/conf"
 	"github.com/tensoremr/server/pkg/controller"
 	"github.com/tensoremr/server/pkg/graphql/graph"
 	"github.com/tensoremr/server/pkg/graphql/graph/generated"
+	"github.com/tensoremr/server/pkg/mailer"
 	"github.com/tensoremr/server/pkg/middleware"
 	"github.com/tensoremr/server/pkg/models"
+	"github.com/tensoremr/server/pkg/notifier"
+	"github.com/tensoremr/server/pkg/pubsub"
 	"github.com/tensoremr/server/pkg/repository"
 	"gorm.io/gorm"
 )
@@ -59,7 +70,6 @@ func NewServer() *Server {
 	server := &Server{}
 
 	server.ModelRegistry = models.NewModel()
-	server.NewEnforcer()
 
 	if err := server.ModelRegistry.OpenPostgres(); err != nil {
 		log.Fatalf("gorm: could not connect to db %q", err)
@@ -73,7 +83,24 @@ func NewServer() *Server {
 	server.ModelRegistry.AutoMigrateAll()
 	//server.ModelRegistry.AddSearchIndex()
 
+	if err := server.ModelRegistry.MigratePatientQueues(); err != nil {
+		log.Fatalf("gorm: could not migrate patient queues %q", err)
+	}
+
+	if err := server.ModelRegistry.AddAuditLogTrigger(); err != nil {
+		log.Fatalf("gorm: could not make audit logs append-only %q", err)
+	}
+
+	if err := audit.RegisterCallbacks(server.DB); err != nil {
+		log.Fatalf("gorm: could not register audit callbacks %q", err)
+	}
+
+	if err := chartlock.RegisterCallbacks(server.DB); err != nil {
+		log.Fatalf("gorm: could not register chart lock callbacks %q", err)
+	}
+
 	server.SeedData()
+	server.NewEnforcer()
 	server.RegisterJobs()
 
 	server.Gin = server.NewRouter()
@@ -93,6 +120,20 @@ func playgroundHandler() gin.HandlerFunc {
 // RegisterJobs ...
 func (s *Server) RegisterJobs() {
 	patientQueueRepository := repository.ProvidePatientQueueRepository(s.DB)
+	refreshTokenRepository := repository.ProvideRefreshTokenRepository(s.DB)
+	appointmentStatusRepository := repository.ProvideAppointmentStatusRepository(s.DB)
+	appointmentRepository := repository.ProvideAppointmentRepository(s.DB, appointmentStatusRepository)
+
+	reminderHours, err := strconv.Atoi(os.Getenv("REMINDER_HOURS"))
+	if err != nil || reminderHours <= 0 {
+		reminderHours = 24
+	}
+
+	appointmentReminders := notifier.AppointmentReminders{
+		AppointmentRepository: appointmentRepository,
+		Notifier:              notifier.New(),
+		Before:                time.Duration(reminderHours) * time.Hour,
+	}
 
 	c := cron.New()
 	c.AddFunc("@hourly", func() {
@@ -100,6 +141,25 @@ func (s *Server) RegisterJobs() {
 			fmt.Println(err)
 		}
 	})
+	c.AddFunc("@daily", func() {
+		if err := refreshTokenRepository.ClearExpired(); err != nil {
+			fmt.Println(err)
+		}
+	})
+	c.AddFunc("@every 15m", func() {
+		if err := appointmentReminders.Send(); err != nil {
+			fmt.Println(err)
+		}
+	})
+	c.AddFunc("@daily", func() {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+		ctx := audit.WithActor(context.Background(), audit.SystemActor)
+		if _, err := appointmentRepository.WithContext(ctx).MarkNoShows(today); err != nil {
+			fmt.Println(err)
+		}
+	})
 	c.Start()
 }
 
@@ -118,10 +178,15 @@ func (s *Server) NewRouter() *gin.Engine {
 	AllergyRepository := repository.ProvideAllergyRepository(s.DB)
 	AmendmentRepository := repository.ProvideAmendmentRepository(s.DB)
 	AppointmentQueueRepository := repository.ProvideAppointmentQueueRepository(s.DB)
+	AppointmentSeriesRepository := repository.ProvideAppointmentSeriesRepository(s.DB)
 	AppointmentStatusRepository := repository.ProvideAppointmentStatusRepository(s.DB)
 	AppointmentRepository := repository.ProvideAppointmentRepository(s.DB, AppointmentStatusRepository)
+	AuditLogRepository := repository.ProvideAuditLogRepository(s.DB)
 	AutoRefractionRepository := repository.ProvideAutoRefractionRepository(s.DB)
 	BillingRepository := repository.ProvideBillingRepository(s.DB)
+	ChartRevisionRepository := repository.ProvideChartRevisionRepository(s.DB)
+	NoteTemplateRepository := repository.ProvideNoteTemplateRepository(s.DB)
+	ChartDocumentRepository := repository.ProvideChartDocumentRepository(s.DB)
 	ChatDeleteRepository := repository.ProvideChatDeleteRepository(s.DB)
 	ChatMemberRepository := repository.ProvideChatMemberRepository(s.DB)
 	ChatMessageRepository := repository.ProvideChatMessageRepository(s.DB)
@@ -173,19 +238,25 @@ func (s *Server) NewRouter() *gin.Engine {
 	PatientDiagnosisRepository := repository.ProvidePatientDiagnosisRepository(s.DB)
 	PatientEncounterLimitRepository := repository.ProvidePatientEncounterLimitRepository(s.DB)
 	PatientHistoryRepository := repository.ProvidePatientHistoryRepository(s.DB)
+	PatientMergeRepository := repository.ProvidePatientMergeRepository(s.DB)
 	PatientQueueRepository := repository.ProvidePatientQueueRepository(s.DB)
 	PatientRepository := repository.ProvidePatientRepository(s.DB)
 	PaymentWaiverRepository := repository.ProvidePaymentWaiverRepository(s.DB)
 	PaymentRepository := repository.ProvidePaymentRepository(s.DB)
+	PermissionRepository := repository.ProvidePermissionRepository(s.DB, s.ACLEnforcer)
 	PharmacyRepository := repository.ProvidePharmacyRepository(s.DB)
 	PhysicalExamFindingRepository := repository.ProvidePhysicalExamFindingRepository(s.DB)
+	PostOpProtocolRepository := repository.ProvidePostOpProtocolRepository(s.DB)
+	ProviderScheduleRepository := repository.ProvideProviderScheduleRepository(s.DB)
 	PupilsRepository := repository.ProvidePupilsRepository(s.DB)
 	QueueDestinationRepository := repository.ProvideQueueDestinationRepository(s.DB)
 	QueueSubscriptionRepository := repository.ProvideQueueSubscriptionRepository(s.DB)
 	ReferralOrderRepository := repository.ProvideReferralOrderRepository(s.DB)
+	RefreshTokenRepository := repository.ProvideRefreshTokenRepository(s.DB)
 	ReferralRepository := repository.ProvideReferralRepository(s.DB)
 	ReviewOfSystemRepository := repository.ProvideReviewOfSystemRepository(s.DB)
 	RoomRepository := repository.ProvideRoomRepository(s.DB)
+	ScheduleExceptionRepository := repository.ProvideScheduleExceptionRepository(s.DB)
 	SlitLampExamRepository := repository.ProvideSlitLampExamRepository(s.DB)
 	SupplyRepository := repository.ProvideSupplyRepository(s.DB)
 	SurgicalOrderRepository := repository.ProvideSurgicalOrderRepository(s.DB)
@@ -201,17 +272,28 @@ func (s *Server) NewRouter() *gin.Engine {
 	VisitTypeRepository := repository.ProvideVisitTypeRepository(s.DB)
 	VisualAcuityRepository := repository.ProvideVisualAcuityRepository(s.DB)
 	VitalSignsRepository := repository.ProvideVitalSignsRepository(s.DB)
+	WaitlistRepository := repository.ProvideWaitlistRepository(s.DB)
 
-	h := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: &graph.Resolver{
+	PubSub := pubsub.NewBroker()
+	Mailer := mailer.New()
+
+	h := handler.New(generated.NewExecutableSchema(generated.Config{Resolvers: &graph.Resolver{
 		Config:                             s.Config,
 		AccessControl:                      s.ACLEnforcer,
+		PubSub:                             PubSub,
+		Mailer:                             Mailer,
 		AllergyRepository:                  AllergyRepository,
 		AmendmentRepository:                AmendmentRepository,
 		AppointmentQueueRepository:         AppointmentQueueRepository,
+		AppointmentSeriesRepository:        AppointmentSeriesRepository,
 		AppointmentStatusRepository:        AppointmentStatusRepository,
 		AppointmentRepository:              AppointmentRepository,
+		AuditLogRepository:                 AuditLogRepository,
 		AutoRefractionRepository:           AutoRefractionRepository,
 		BillingRepository:                  BillingRepository,
+		ChartRevisionRepository:            ChartRevisionRepository,
+		NoteTemplateRepository:             NoteTemplateRepository,
+		ChartDocumentRepository:            ChartDocumentRepository,
 		ChatDeleteRepository:               ChatDeleteRepository,
 		ChatMemberRepository:               ChatMemberRepository,
 		ChatMessageRepository:              ChatMessageRepository,
@@ -263,19 +345,25 @@ func (s *Server) NewRouter() *gin.Engine {
 		PatientDiagnosisRepository:         PatientDiagnosisRepository,
 		PatientEncounterLimitRepository:    PatientEncounterLimitRepository,
 		PatientHistoryRepository:           PatientHistoryRepository,
+		PatientMergeRepository:             PatientMergeRepository,
 		PatientQueueRepository:             PatientQueueRepository,
 		PatientRepository:                  PatientRepository,
 		PaymentWaiverRepository:            PaymentWaiverRepository,
 		PaymentRepository:                  PaymentRepository,
+		PermissionRepository:               PermissionRepository,
 		PharmacyRepository:                 PharmacyRepository,
 		PhysicalExamFindingRepository:      PhysicalExamFindingRepository,
+		PostOpProtocolRepository:           PostOpProtocolRepository,
+		ProviderScheduleRepository:         ProviderScheduleRepository,
 		PupilsRepository:                   PupilsRepository,
 		QueueDestinationRepository:         QueueDestinationRepository,
 		QueueSubscriptionRepository:        QueueSubscriptionRepository,
 		ReferralOrderRepository:            ReferralOrderRepository,
+		RefreshTokenRepository:             RefreshTokenRepository,
 		ReferralRepository:                 ReferralRepository,
 		ReviewOfSystemRepository:           ReviewOfSystemRepository,
 		RoomRepository:                     RoomRepository,
+		ScheduleExceptionRepository:        ScheduleExceptionRepository,
 		SlitLampExamRepository:             SlitLampExamRepository,
 		SupplyRepository:                   SupplyRepository,
 		SurgicalOrderRepository:            SurgicalOrderRepository,
@@ -291,8 +379,31 @@ func (s *Server) NewRouter() *gin.Engine {
 		VisitTypeRepository:                VisitTypeRepository,
 		VisualAcuityRepository:             VisualAcuityRepository,
 		VitalSignsRepository:               VitalSignsRepository,
+		WaitlistRepository:                 WaitlistRepository,
+	}, Directives: generated.DirectiveRoot{
+		HasPermission: graph.HasPermission(s.ACLEnforcer),
 	}}))
 
+	h.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: middleware.WebsocketCheckOrigin(auth.AppURL()),
+		},
+		InitFunc: middleware.WebsocketInitFunc(UserRepository, RefreshTokenRepository),
+	})
+	h.AddTransport(transport.Options{})
+	h.AddTransport(transport.POST{})
+	h.AddTransport(transport.MultipartForm{})
+
+	h.SetQueryCache(lru.New(1000))
+
+	h.Use(extension.Introspection{})
+	h.Use(extension.AutomaticPersistedQuery{
+		Cache: lru.New(100),
+	})
+	h.Use(audit.Extension{AuditLogRepository: AuditLogRepository})
+	h.SetErrorPresenter(graph.ErrorPresenter)
+
 	r := gin.Default()
 	//r.Use(cors.Default())
 	r.Use(middleware.CORSMiddleware())
@@ -302,19 +413,32 @@ func (s *Server) NewRouter() *gin.Engine {
 		c.String(200, "pong")
 	})
 
-	authApi := auth.AuthApi{UserRepository: UserRepository}
-	patientQueueApi := controller.PatientQueueApi{PatientQueueRepository: PatientQueueRepository, AppointmentRepository: AppointmentRepository}
+	authApi := auth.AuthApi{UserRepository: UserRepository, RefreshTokenRepository: RefreshTokenRepository, Mailer: Mailer, OIDC: auth.NewOIDCProvider()}
+	patientQueueApi := controller.PatientQueueApi{PatientQueueRepository: PatientQueueRepository, AppointmentRepository: AppointmentRepository, PubSub: PubSub}
 	userTypeApi := controller.UserTypeApi{UserTypeRepository: UserTypeRepository}
 	organizationDetailsApi := controller.OrganizationDetailsApi{OrganizationDetailsRepository: OrganizationDetailsRepository}
+	chartDocumentApi := controller.ChartDocumentApi{ChartDocumentRepository: ChartDocumentRepository}
 
 	r.Group("/public")
 	{
 		r.POST("/login", authApi.Login())
+		r.POST("/login/2fa", authApi.LoginTwoFactor)
 		r.POST("/legacy-login", authApi.LegacyLogin())
+		r.POST("/refresh", authApi.Refresh)
+		r.POST("/logout", authApi.Logout)
+		r.POST("/recover", authApi.Recover)
+		r.POST("/recover/reset", authApi.ResetPassword)
+		r.POST("/confirm", authApi.Confirm)
+
+		if authApi.OIDC != nil {
+			r.GET("/oidc/login", authApi.OIDCLogin)
+			r.GET("/oidc/callback", authApi.OIDCCallback)
+		}
 		r.POST("/signup", authApi.Signup)
 		r.GET("/userTypes", userTypeApi.GetUserTypes)
-		r.GET("/patientQueues", patientQueueApi.GetPatientQueues)
+		r.GET("/display/patientQueues", patientQueueApi.StreamDisplay)
 		r.GET("/organizationDetails", organizationDetailsApi.GetOrganizationDetails)
+		r.GET("/documents/verify/:code", chartDocumentApi.VerifyChartDocument)
 
 		r.Static("/files", "./files")
 
@@ -322,8 +446,14 @@ func (s *Server) NewRouter() *gin.Engine {
 		r.GET("/rxnorm-intractions", controller.GetDrugIntractions)
 	}
 
-	r.Use(middleware.AuthMiddleware())
+	// Websocket connections authenticate through the connection_init payload.
+	// Plain GET queries are not served, since this route is not behind AuthMiddleware
+	r.GET("/query", graphqlHandler(s, h))
+
+	r.Use(middleware.AuthMiddleware(UserRepository, RefreshTokenRepository))
 	r.GET("/api", playgroundHandler())
+	r.GET("/patientQueues", patientQueueApi.GetPatientQueues)
+	r.GET("/chartDocuments/:id", chartDocumentApi.GetChartDocument)
 	r.POST("/query", graphqlHandler(s, h))
 
 	return r
@@ -352,6 +482,9 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// NewEnforcer creates the casbin enforcer with policies stored in the database.
+// policy.csv only seeds the default policies on first start, and user roles
+// are derived from user types.
 func (s *Server) NewEnforcer() error {
 	var model string
 	var policy string
@@ -366,11 +499,31 @@ func (s *Server) NewEnforcer() error {
 		policy = "pkg/conf/policy.csv"
 	}
 
-	e, err := casbin.NewEnforcer(model, policy)
+	adapter, err := gormadapter.NewAdapterByDB(s.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	e, err := casbin.NewEnforcer(model, adapter)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	defaults, err := casbin.NewEnforcer(model, policy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	permissionRepository := repository.ProvidePermissionRepository(s.DB, e)
+
+	if err := permissionRepository.Seed(defaults.GetPolicy()); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := permissionRepository.SyncAllUserRoles(); err != nil {
+		log.Fatal(err)
+	}
+
 	s.ACLEnforcer = e
 	return nil
 }
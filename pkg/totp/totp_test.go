@@ -0,0 +1,134 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890"
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerate(t *testing.T) {
+	// The RFC vectors have 8 digits, codes are their last 6
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, c := range cases {
+		if got := generate([]byte("12345678901234567890"), c.unix/period); got != c.want {
+			t.Errorf("generate(%d) = %s, want %s", c.unix, got, c.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := now.Unix() / period
+
+	cases := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOk   bool
+	}{
+		{"current step", rfcSecret, "081804", step, true},
+		{"lowercase secret and padded code", strings.ToLower(rfcSecret), " 081804 ", step, true},
+		{"previous step", rfcSecret, generate([]byte("12345678901234567890"), step-1), step - 1, true},
+		{"next step", rfcSecret, generate([]byte("12345678901234567890"), step+1), step + 1, true},
+		{"outside the allowed skew", rfcSecret, generate([]byte("12345678901234567890"), step-2), 0, false},
+		{"wrong code", rfcSecret, "000000", 0, false},
+		{"short code", rfcSecret, "81804", 0, false},
+		{"invalid secret", "not base32!", "081804", 0, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gotStep, gotOk := Validate(c.secret, c.code, now)
+			if gotOk != c.wantOk || gotStep != c.wantStep {
+				t.Errorf("Validate() = (%d, %v), want (%d, %v)", gotStep, gotOk, c.wantStep, c.wantOk)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := generate(mustDecode(t, secret), time.Now().Unix()/period)
+	if _, ok := Validate(secret, code, time.Now()); !ok {
+		t.Errorf("Validate() rejected a code of a generated secret")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("GenerateRecoveryCodes() returned %d codes and %d hashes, want %d", len(codes), len(hashes), recoveryCodeCount)
+	}
+
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if seen[code] {
+			t.Errorf("duplicate recovery code %s", code)
+		}
+		seen[code] = true
+
+		if HashRecoveryCode(" "+strings.ToUpper(code)+" ") != hashes[i] {
+			t.Errorf("HashRecoveryCode() does not ignore case and spaces")
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Tensor EMR", "user@example.com", rfcSecret)
+
+	for _, want := range []string{"otpauth://totp/", "secret=" + rfcSecret, "digits=6", "period=30"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("URI() = %s, missing %s", uri, want)
+		}
+	}
+}
+
+func mustDecode(t *testing.T, secret string) []byte {
+	t.Helper()
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
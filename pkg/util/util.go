@@ -18,7 +18,10 @@
 
 package util
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // AgeAt gets the age of an entity at a certain time.
 func AgeAt(birthDate time.Time, now time.Time) int {
@@ -79,3 +82,86 @@ func UniqueNonEmptyElementsOf(s []int) []int {
 
 	return us
 }
+
+// JaroWinkler returns the Jaro-Winkler similarity of two strings, from 0 for
+// no similarity to 1 for equal strings. It is case insensitive and suits
+// short strings such as names.
+func JaroWinkler(a string, b string) float64 {
+	s1 := []rune(strings.ToLower(strings.TrimSpace(a)))
+	s2 := []rune(strings.ToLower(strings.TrimSpace(b)))
+
+	if len(s1) == 0 || len(s2) == 0 {
+		return 0
+	}
+
+	if string(s1) == string(s2) {
+		return 1
+	}
+
+	window := len(s1)
+	if len(s2) > window {
+		window = len(s2)
+	}
+	window = window/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matched1 := make([]bool, len(s1))
+	matched2 := make([]bool, len(s2))
+
+	matches := 0
+	for i := range s1 {
+		start := i - window
+		if start < 0 {
+			start = 0
+		}
+
+		end := i + window + 1
+		if end > len(s2) {
+			end = len(s2)
+		}
+
+		for j := start; j < end; j++ {
+			if matched2[j] || s1[i] != s2[j] {
+				continue
+			}
+
+			matched1[i] = true
+			matched2[j] = true
+			matches++
+			break
+		}
+	}
+
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range s1 {
+		if !matched1[i] {
+			continue
+		}
+
+		for !matched2[j] {
+			j++
+		}
+
+		if s1[i] != s2[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s1)) + m/float64(len(s2)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < len(s1) && prefix < len(s2) && prefix < 4 && s1[prefix] == s2[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
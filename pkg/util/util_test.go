@@ -0,0 +1,50 @@
+/*
+  Copyright 2021 Kidus Tiliksew
+
+  This file is part of Tensor EMR.
+
+  Tensor EMR is free software: you can redistribute it and/or modify
+  it under the terms of the version 2 of GNU General Public License as published by
+  the Free Software Foundation.
+
+  Tensor EMR is distributed in the hope that it will be useful,
+  but WITHOUT ANY WARRANTY; without even the implied warranty of
+  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+  GNU General Public License for more details.
+
+  You should have received a copy of the GNU General Public License
+  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestJaroWinkler(t *testing.T) {
+	cases := []struct {
+		a    string
+		b    string
+		want float64
+	}{
+		{"MARTHA", "MARHTA", 0.961111},
+		{"DWAYNE", "DUANE", 0.84},
+		{"DIXON", "DICKSONX", 0.813333},
+		{"JELLYFISH", "SMELLYFISH", 0.896296},
+		{"Abebe", "abebe ", 1},
+		{"Kebede", "", 0},
+		{"abc", "xyz", 0},
+	}
+
+	for _, c := range cases {
+		got := JaroWinkler(c.a, c.b)
+		if math.Abs(got-c.want) > 1e-6 {
+			t.Errorf("JaroWinkler(%q, %q) = %f, want %f", c.a, c.b, got, c.want)
+		}
+
+		if reverse := JaroWinkler(c.b, c.a); math.Abs(reverse-got) > 1e-9 {
+			t.Errorf("JaroWinkler(%q, %q) = %f, not symmetric with %f", c.b, c.a, reverse, got)
+		}
+	}
+}